@@ -58,6 +58,9 @@ let allMembers = [
     ...subMembers.map(m => ({ ...m, type: 'sub' }))
 ];
 
+// Deleted members, only used to name them on old rosters
+let formerMembers = [];
+
 // ==================== STATE ====================
 
 let state = {
@@ -69,6 +72,11 @@ let state = {
     linkedUsers: {}, // Maps player IDs to their Discord info (avatar, etc.)
     leagues: [],
     divisions: [],
+    opponents: [],
+    teams: [],
+    team: null, // Team being viewed, picked with the team cookie
+    seasons: [],
+    seasonId: '', // Season being viewed, '' for the current one
     editingGameId: null, // ID of game being edited, null if creating new
     loading: false
 };
@@ -89,7 +97,8 @@ async function checkAuth() {
                 isManager: data.isManager,
                 playerId: data.playerId,
                 email: data.email || '',
-                phone: data.phone || ''
+                phone: data.phone || '',
+                timeZone: data.timeZone || ''
             };
             state.isManager = data.isManager;
             state.currentPlayer = data.playerId || null;
@@ -150,10 +159,12 @@ async function linkPlayer(playerId) {
 async function fetchData() {
     try {
         state.loading = true;
-        const response = await fetch(`${API_BASE}/data`, { credentials: 'include' });
+        const response = await fetch(`${API_BASE}/data${seasonQuery()}`, { credentials: 'include' });
         const data = await response.json();
         state.games = data.games || [];
         state.playerPreferences = data.playerPreferences || {};
+        state.team = data.team || null;
+        renderTeamName();
 
         // Fetch linked users for avatars
         await fetchLinkedUsers();
@@ -191,9 +202,22 @@ async function fetchMembers() {
     }
 }
 
+// fetchConfirmingConflicts sends a game write. If it clashes with other
+// games, the manager is asked whether to save it anyway.
+async function fetchConfirmingConflicts(url, options) {
+    const response = await fetch(url, options);
+    if (response.status !== 409) return response;
+    const data = await response.clone().json();
+    // Stale versions are 409s too, without conflicts
+    if (!data.conflicts) return response;
+    const lines = data.conflicts.map(c => `• ${c.message}`).join('\n');
+    if (!confirm(`This clashes with other games:\n${lines}\n\nSave anyway?`)) return response;
+    return fetch(`${url}${url.includes('?') ? '&' : '?'}force=true`, options);
+}
+
 async function createGame(gameData) {
     try {
-        const response = await fetch(`${API_BASE}/games`, {
+        const response = await fetchConfirmingConflicts(`${API_BASE}/games`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'include',
@@ -211,12 +235,48 @@ async function createGame(gameData) {
     }
 }
 
-async function updateGame(gameId, gameData) {
+// createSeriesAPI creates a recurring series; the response holds the series
+// and every game it generated
+async function createSeriesAPI(seriesData) {
     try {
-        const response = await fetch(`${API_BASE}/games/${gameId}`, {
-            method: 'PUT',
+        const response = await fetch(`${API_BASE}/series`, {
+            method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'include',
+            body: JSON.stringify(seriesData)
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            throw new Error(data.error || 'Failed to create series');
+        }
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to create series:', error);
+        showError(error.message);
+        return null;
+    }
+}
+
+// scopeQuery turns a series scope ('this', 'following' or 'all') into the
+// query string game updates and deletes take
+function scopeQuery(scope) {
+    return scope ? `?scope=${scope}` : '';
+}
+
+// jsonHeaders adds If-Match when we know which version of a game we edited,
+// so the server rejects the write if someone else changed it in between
+function jsonHeaders(version) {
+    const headers = { 'Content-Type': 'application/json' };
+    if (version) headers['If-Match'] = `"${version}"`;
+    return headers;
+}
+
+async function updateGame(gameId, gameData, version, scope) {
+    try {
+        const response = await fetchConfirmingConflicts(`${API_BASE}/games/${gameId}${scopeQuery(scope)}`, {
+            method: 'PUT',
+            headers: jsonHeaders(version),
+            credentials: 'include',
             body: JSON.stringify(gameData)
         });
         if (!response.ok) {
@@ -231,9 +291,9 @@ async function updateGame(gameId, gameData) {
     }
 }
 
-async function deleteGameAPI(gameId) {
+async function deleteGameAPI(gameId, scope) {
     try {
-        const response = await fetch(`${API_BASE}/games/${gameId}`, {
+        const response = await fetch(`${API_BASE}/games/${gameId}${scopeQuery(scope)}`, {
             method: 'DELETE',
             credentials: 'include'
         });
@@ -269,11 +329,11 @@ async function setAvailabilityAPI(gameId, playerId, isAvailable) {
     }
 }
 
-async function updateRosterAPI(gameId, roster, subs = []) {
+async function updateRosterAPI(gameId, roster, subs = [], version) {
     try {
-        const response = await fetch(`${API_BASE}/games/${gameId}/roster`, {
+        const response = await fetchConfirmingConflicts(`${API_BASE}/games/${gameId}/roster`, {
             method: 'PUT',
-            headers: { 'Content-Type': 'application/json' },
+            headers: jsonHeaders(version),
             credentials: 'include',
             body: JSON.stringify({ roster, subs })
         });
@@ -345,6 +405,153 @@ async function postToDiscordAPI(gameId, mentionPlayers = false) {
     }
 }
 
+async function setResultAPI(gameId, result, version) {
+    try {
+        const response = await fetch(`${API_BASE}/games/${gameId}/result`, {
+            method: 'PUT',
+            headers: jsonHeaders(version),
+            credentials: 'include',
+            body: JSON.stringify(result)
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            throw new Error(data.error || 'Failed to save result');
+        }
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to save result:', error);
+        showError(error.message);
+        return null;
+    }
+}
+
+async function postResultToDiscordAPI(gameId) {
+    const response = await fetch(`${API_BASE}/discord/result/${gameId}`, {
+        method: 'POST',
+        credentials: 'include'
+    });
+    if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error || 'Failed to post result');
+    }
+    return true;
+}
+
+async function importStatsAPI(gameId, csv) {
+    const response = await fetch(`${API_BASE}/games/${gameId}/stats/import`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'text/csv' },
+        credentials: 'include',
+        body: csv
+    });
+    if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error || 'Failed to import stats');
+    }
+    return await response.json();
+}
+
+// importFixturesAPI previews the games in an .ics or CSV file, or with
+// commit creates them; the response lists every row of the file
+async function importFixturesAPI(file, leagueId, commit) {
+    const params = new URLSearchParams();
+    if (leagueId) params.set('league', leagueId);
+    if (commit) params.set('commit', 'true');
+    const response = await fetch(`${API_BASE}/games/import?${params}`, {
+        method: 'POST',
+        credentials: 'include',
+        body: await file.text()
+    });
+    if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error || 'Failed to import fixtures');
+    }
+    return await response.json();
+}
+
+async function fetchConflictsAPI() {
+    try {
+        const response = await fetch(`${API_BASE}/conflicts`, { credentials: 'include' });
+        if (!response.ok) return [];
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch conflicts:', error);
+        return [];
+    }
+}
+
+async function fetchGameDurationsAPI() {
+    try {
+        const response = await fetch(`${API_BASE}/durations`, { credentials: 'include' });
+        if (!response.ok) return null;
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch game durations:', error);
+        return null;
+    }
+}
+
+async function setGameDurationAPI(mode, minutes) {
+    const response = await fetch(`${API_BASE}/durations/${encodeURIComponent(mode)}`, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        credentials: 'include',
+        body: JSON.stringify({ minutes })
+    });
+    const data = await response.json();
+    if (!response.ok) throw new Error(data.error || 'Failed to set game duration');
+    return data;
+}
+
+async function fetchFeedsAPI() {
+    try {
+        const response = await fetch(`${API_BASE}/feeds`, { credentials: 'include' });
+        if (!response.ok) return [];
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch feeds:', error);
+        return [];
+    }
+}
+
+async function createFeedAPI(feed) {
+    try {
+        const response = await fetch(`${API_BASE}/feeds`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            credentials: 'include',
+            body: JSON.stringify(feed)
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            throw new Error(data.error || 'Failed to create feed');
+        }
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to create feed:', error);
+        showError(error.message);
+        return null;
+    }
+}
+
+async function revokeFeedAPI(token) {
+    try {
+        const response = await fetch(`${API_BASE}/feeds/${token}`, {
+            method: 'DELETE',
+            credentials: 'include'
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            throw new Error(data.error || 'Failed to revoke feed');
+        }
+        return true;
+    } catch (error) {
+        console.error('Failed to revoke feed:', error);
+        showError(error.message);
+        return false;
+    }
+}
+
 async function saveWebhookAPI(webhook) {
     try {
         const response = await fetch(`${API_BASE}/webhook`, {
@@ -365,11 +572,110 @@ async function saveWebhookAPI(webhook) {
     }
 }
 
+// ==================== TEAMS ====================
+
+// rememberTeam makes the server use a team for every request from now on
+function rememberTeam(team) {
+    document.cookie = `team=${encodeURIComponent(team)}; path=/; max-age=31536000; SameSite=Lax`;
+}
+
+async function fetchTeams() {
+    try {
+        const response = await fetch(`${API_BASE}/teams`, { credentials: 'include' });
+        state.teams = await response.json();
+        renderTeamSelect();
+    } catch (error) {
+        console.error('Failed to fetch teams:', error);
+    }
+}
+
+function renderTeamName() {
+    const el = document.getElementById('teamName');
+    if (el && state.team) el.textContent = state.team.name;
+}
+
+function renderTeamSelect() {
+    const select = document.getElementById('teamSelect');
+    if (!select) return;
+    // Only worth showing once there is more than one team
+    select.style.display = state.teams.length > 1 ? '' : 'none';
+    select.innerHTML = state.teams.map(team => {
+        const selected = state.team && team.id === state.team.id;
+        return `<option value="${team.id}" ${selected ? 'selected' : ''}>${team.name}</option>`;
+    }).join('');
+}
+
+function selectTeam(teamId) {
+    rememberTeam(teamId);
+    // Everything on the page belongs to the team, so start over
+    window.location.href = window.location.pathname;
+}
+
 // ==================== LEAGUES & DIVISIONS ====================
 
+// seasonQuery selects the season being viewed on list endpoints
+function seasonQuery() {
+    return state.seasonId ? `?season=${encodeURIComponent(state.seasonId)}` : '';
+}
+
+async function fetchSeasons() {
+    try {
+        const response = await fetch(`${API_BASE}/seasons`);
+        state.seasons = await response.json();
+        renderSeasonSelect();
+    } catch (error) {
+        console.error('Failed to fetch seasons:', error);
+    }
+}
+
+function renderSeasonSelect() {
+    const select = document.getElementById('seasonSelect');
+    if (!select) return;
+    select.innerHTML = state.seasons.map(season => {
+        const value = season.active ? '' : season.id;
+        const label = season.active ? `${season.name} (current)` : season.name;
+        return `<option value="${value}" ${value === state.seasonId ? 'selected' : ''}>${label}</option>`;
+    }).join('');
+}
+
+async function selectSeason(seasonId) {
+    state.seasonId = seasonId;
+    await fetchData();
+    await fetchLeagues();
+    await fetchDivisions();
+    renderAll();
+}
+
+async function startNewSeason() {
+    const input = document.getElementById('newSeasonName');
+    const name = input.value.trim();
+    if (!name) return;
+
+    const current = state.seasons.find(s => s.active);
+    if (current && !confirm(`Start "${name}"? "${current.name}" will be archived and new games will go into the new season.`)) return;
+
+    try {
+        const response = await fetch(`${API_BASE}/seasons`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            credentials: 'include',
+            body: JSON.stringify({ name })
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            throw new Error(data.error || 'Failed to start season');
+        }
+        input.value = '';
+        await fetchSeasons();
+        await selectSeason('');
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
 async function fetchLeagues() {
     try {
-        const response = await fetch(`${API_BASE}/leagues`);
+        const response = await fetch(`${API_BASE}/leagues${seasonQuery()}`);
         state.leagues = await response.json();
         populateLeagueDropdown();
         renderLeaguesList();
@@ -380,7 +686,7 @@ async function fetchLeagues() {
 
 async function fetchDivisions() {
     try {
-        const response = await fetch(`${API_BASE}/divisions`);
+        const response = await fetch(`${API_BASE}/divisions${seasonQuery()}`);
         state.divisions = await response.json();
         populateDivisionDropdown();
         renderDivisionsList();
@@ -389,28 +695,65 @@ async function fetchDivisions() {
     }
 }
 
+// populateLeagueDropdown fills the game form's league picker and those of
+// the fixture import and the exports
 function populateLeagueDropdown() {
-    const select = document.getElementById('gameLeague');
-    if (!select) return;
-    select.innerHTML = '<option value="">Select League</option>';
-    state.leagues.forEach(league => {
-        const opt = document.createElement('option');
-        opt.value = league;
-        opt.textContent = league;
-        select.appendChild(opt);
+    [['gameLeague', 'Select League'], ['fixturesLeague', 'No League'], ['exportLeague', 'All Leagues']].forEach(([id, placeholder]) => {
+        const select = document.getElementById(id);
+        if (!select) return;
+        const current = select.value;
+        select.innerHTML = `<option value="">${placeholder}</option>`;
+        state.leagues.forEach(league => {
+            const opt = document.createElement('option');
+            opt.value = league.id;
+            opt.textContent = league.name;
+            select.appendChild(opt);
+        });
+        select.value = current;
     });
 }
 
 function populateDivisionDropdown() {
     const select = document.getElementById('gameDivision');
     if (!select) return;
+    const current = select.value;
     select.innerHTML = '<option value="">Select Division</option>';
     state.divisions.forEach(div => {
         const opt = document.createElement('option');
-        opt.value = div;
-        opt.textContent = div;
+        opt.value = div.id;
+        opt.textContent = div.name;
         select.appendChild(opt);
     });
+    select.value = current;
+}
+
+// Picking a league or division fills in its default game mode and team size
+function applyLeagueDefaults() {
+    const division = state.divisions.find(d => d.id === document.getElementById('gameDivision')?.value);
+    if (division?.leagueId) {
+        document.getElementById('gameLeague').value = division.leagueId;
+    }
+    const league = state.leagues.find(l => l.id === document.getElementById('gameLeague')?.value);
+
+    const gameMode = division?.defaultGameMode || league?.defaultGameMode;
+    if (gameMode) {
+        const standardModes = ['War', 'Squads', 'Legions'];
+        const gameModeSelect = document.getElementById('gameMode');
+        const gameModeCustom = document.getElementById('gameModeCustom');
+        gameModeSelect.value = standardModes.includes(gameMode) ? gameMode : 'Other';
+        gameModeCustom.value = standardModes.includes(gameMode) ? '' : gameMode;
+        gameModeCustom.style.display = standardModes.includes(gameMode) ? 'none' : 'block';
+    }
+    const teamSize = division?.defaultTeamSize || league?.defaultTeamSize;
+    if (teamSize) {
+        document.getElementById('teamSize').value = teamSize;
+    }
+}
+
+function leagueLabel(item) {
+    const swatch = item.color ? `<span class="color-swatch" style="background:${item.color}"></span>` : '';
+    const code = item.shortCode ? ` <small>(${item.shortCode})</small>` : '';
+    return `${swatch}${item.name}${code}`;
 }
 
 function renderLeaguesList() {
@@ -418,8 +761,9 @@ function renderLeaguesList() {
     if (!container) return;
     container.innerHTML = state.leagues.map(league => `
         <div class="item-row">
-            <span>${league}</span>
-            <button class="btn-remove" onclick="removeLeague('${league}')" title="Remove">×</button>
+            <span>${leagueLabel(league)}</span>
+            <button class="btn-remove btn-edit" onclick="renameLeague('${league.id}')" title="Rename">✎</button>
+            <button class="btn-remove" onclick="removeLeague('${league.id}')" title="Remove">×</button>
         </div>
     `).join('') || '<p class="no-items">No leagues added yet</p>';
 }
@@ -427,12 +771,62 @@ function renderLeaguesList() {
 function renderDivisionsList() {
     const container = document.getElementById('divisionsList');
     if (!container) return;
-    container.innerHTML = state.divisions.map(div => `
+    container.innerHTML = state.divisions.map(div => {
+        const league = state.leagues.find(l => l.id === div.leagueId);
+        return `
         <div class="item-row">
-            <span>${div}</span>
-            <button class="btn-remove" onclick="removeDivision('${div}')" title="Remove">×</button>
+            <span>${leagueLabel(div)}${league ? ` <small>– ${league.name}</small>` : ''}</span>
+            <button class="btn-remove btn-edit" onclick="renameDivision('${div.id}')" title="Rename">✎</button>
+            <button class="btn-remove" onclick="removeDivision('${div.id}')" title="Remove">×</button>
         </div>
-    `).join('') || '<p class="no-items">No divisions added yet</p>';
+    `;
+    }).join('') || '<p class="no-items">No divisions added yet</p>';
+}
+
+// Leagues and divisions share these helpers; kind is 'leagues' or 'divisions'
+async function saveLeagueItem(kind, id, fields) {
+    const response = await fetch(`${API_BASE}/${kind}${id ? `/${id}` : ''}`, {
+        method: id ? 'PUT' : 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        credentials: 'include',
+        body: JSON.stringify(fields)
+    });
+    const data = await response.json();
+    if (!response.ok) throw new Error(data.error || `Failed to save ${kind}`);
+    return data;
+}
+
+// removeLeagueItem deletes a league or division. If games still use it, the
+// manager is asked which one to move them to instead.
+async function removeLeagueItem(kind, id) {
+    const items = state[kind];
+    const item = items.find(i => i.id === id);
+    if (!item || !confirm(`Remove "${item.name}"?`)) return false;
+
+    let url = `${API_BASE}/${kind}/${id}`;
+    let response = await fetch(url, { method: 'DELETE', credentials: 'include' });
+    if (response.status === 409) {
+        const others = items.filter(i => i.id !== id).map(i => i.name);
+        const name = prompt(`"${item.name}" is used by games. Move them to which one?\n${others.join(', ')}`);
+        const replacement = items.find(i => i.id !== id && i.name.toLowerCase() === (name || '').trim().toLowerCase());
+        if (!replacement) return false;
+        url += `?replaceWith=${encodeURIComponent(replacement.id)}`;
+        response = await fetch(url, { method: 'DELETE', credentials: 'include' });
+    }
+    if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error || `Failed to remove ${item.name}`);
+    }
+    return true;
+}
+
+// refreshLeagues reloads leagues, divisions and games, whose names change
+// along with them
+async function refreshLeagues() {
+    await fetchLeagues();
+    await fetchDivisions();
+    await fetchData();
+    renderAll();
 }
 
 async function addLeague() {
@@ -441,37 +835,30 @@ async function addLeague() {
     if (!name) return;
 
     try {
-        const response = await fetch(`${API_BASE}/leagues`, {
-            method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
-            credentials: 'include',
-            body: JSON.stringify({ name })
-        });
-        if (!response.ok) {
-            const data = await response.json();
-            throw new Error(data.error || 'Failed to add league');
-        }
-        state.leagues = await response.json();
+        await saveLeagueItem('leagues', null, { name, seasonId: state.seasonId });
         input.value = '';
-        populateLeagueDropdown();
-        renderLeaguesList();
+        await fetchLeagues();
     } catch (error) {
         showError(error.message);
     }
 }
 
-async function removeLeague(name) {
-    if (!confirm(`Remove league "${name}"?`)) return;
+async function renameLeague(id) {
+    const league = state.leagues.find(l => l.id === id);
+    const name = prompt('League name:', league?.name || '');
+    if (!league || !name || name.trim() === league.name) return;
 
     try {
-        const response = await fetch(`${API_BASE}/leagues/${encodeURIComponent(name)}`, {
-            method: 'DELETE',
-            credentials: 'include'
-        });
-        if (!response.ok) throw new Error('Failed to remove league');
-        state.leagues = await response.json();
-        populateLeagueDropdown();
-        renderLeaguesList();
+        await saveLeagueItem('leagues', id, { name: name.trim() });
+        await refreshLeagues();
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+async function removeLeague(id) {
+    try {
+        if (await removeLeagueItem('leagues', id)) await refreshLeagues();
     } catch (error) {
         showError(error.message);
     }
@@ -483,40 +870,122 @@ async function addDivision() {
     if (!name) return;
 
     try {
-        const response = await fetch(`${API_BASE}/divisions`, {
-            method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
-            credentials: 'include',
-            body: JSON.stringify({ name })
-        });
-        if (!response.ok) {
-            const data = await response.json();
-            throw new Error(data.error || 'Failed to add division');
-        }
-        state.divisions = await response.json();
+        await saveLeagueItem('divisions', null, { name, seasonId: state.seasonId });
         input.value = '';
-        populateDivisionDropdown();
-        renderDivisionsList();
+        await fetchDivisions();
     } catch (error) {
         showError(error.message);
     }
 }
 
-async function removeDivision(name) {
-    if (!confirm(`Remove division "${name}"?`)) return;
+async function renameDivision(id) {
+    const division = state.divisions.find(d => d.id === id);
+    const name = prompt('Division name:', division?.name || '');
+    if (!division || !name || name.trim() === division.name) return;
 
     try {
-        const response = await fetch(`${API_BASE}/divisions/${encodeURIComponent(name)}`, {
-            method: 'DELETE',
-            credentials: 'include'
+        await saveLeagueItem('divisions', id, { name: name.trim() });
+        await refreshLeagues();
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+async function removeDivision(id) {
+    try {
+        if (await removeLeagueItem('divisions', id)) await refreshLeagues();
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+// ==================== OPPONENTS ====================
+
+async function fetchOpponents() {
+    try {
+        const response = await fetch(`${API_BASE}/opponents`);
+        state.opponents = await response.json();
+        populateOpponentList();
+    } catch (error) {
+        console.error('Failed to fetch opponents:', error);
+    }
+}
+
+// populateOpponentList suggests known opponents and their aliases in the
+// game form; the server matches whatever is typed to the right opponent
+function populateOpponentList() {
+    const list = document.getElementById('opponentList');
+    if (!list) return;
+    list.innerHTML = '';
+    state.opponents.forEach(opponent => {
+        [opponent.name, ...opponent.aliases].forEach(name => {
+            const option = document.createElement('option');
+            option.value = name;
+            if (name !== opponent.name) option.label = `${name} (${opponent.name})`;
+            list.appendChild(option);
         });
-        if (!response.ok) throw new Error('Failed to remove division');
-        state.divisions = await response.json();
-        populateDivisionDropdown();
-        renderDivisionsList();
+    });
+}
+
+function opponentLink(game) {
+    if (!game.opponentId) return game.opponent;
+    return `<a href="#" class="opponent-link" onclick="showOpponentHistory('${game.opponentId}'); return false;">${game.opponent}</a>`;
+}
+
+async function showOpponentHistory(opponentId) {
+    let history;
+    try {
+        const response = await fetch(`${API_BASE}/opponents/${opponentId}`);
+        if (!response.ok) throw new Error('Failed to load opponent');
+        history = await response.json();
     } catch (error) {
         showError(error.message);
+        return;
     }
+
+    const opponent = history.opponent;
+    const details = [opponent.tag, opponent.region, opponent.discord && `Contact: ${opponent.discord}`]
+        .filter(Boolean).join(' • ');
+    const rows = history.games
+        .slice()
+        .sort((a, b) => new Date(b.startsAt) - new Date(a.startsAt))
+        .map(game => `
+            <li>
+                <strong>${formatDate(game)}</strong> at ${formatTime(game)}
+                • ${game.gameMode || 'War'}${game.league ? ` • ${game.league}` : ''}
+            </li>
+        `).join('');
+
+    let modal = document.getElementById('opponentModal');
+    if (!modal) {
+        modal = document.createElement('div');
+        modal.id = 'opponentModal';
+        modal.className = 'modal';
+        document.body.appendChild(modal);
+    }
+
+    modal.innerHTML = `
+        <div class="modal-content opponent-modal">
+            <h3>${opponent.logo ? `<img class="opponent-logo" src="${opponent.logo}" alt="">` : ''}${opponent.name}</h3>
+            ${details ? `<p class="modal-help">${details}</p>` : ''}
+            ${opponent.aliases.length ? `<p class="modal-help">Also known as ${opponent.aliases.join(', ')}</p>` : ''}
+            ${opponent.notes ? `<p>${opponent.notes}</p>` : ''}
+            <p><strong>${history.played}</strong> played • <strong>${history.upcoming}</strong> upcoming</p>
+            ${rows ? `<ul class="opponent-history">${rows}</ul>` : '<p class="modal-help">No games against them yet.</p>'}
+            <div class="modal-buttons">
+                <button class="btn btn-secondary" onclick="closeOpponentModal()">Close</button>
+            </div>
+        </div>
+    `;
+    modal.classList.add('active');
+    modal.onclick = (e) => {
+        if (e.target === modal) closeOpponentModal();
+    };
+}
+
+function closeOpponentModal() {
+    const modal = document.getElementById('opponentModal');
+    if (modal) modal.classList.remove('active');
 }
 
 // ==================== UI FUNCTIONS ====================
@@ -625,7 +1094,7 @@ function showError(message) {
 }
 
 function getMemberName(id) {
-    const member = allMembers.find(m => m.id === id);
+    const member = allMembers.find(m => m.id === id) || formerMembers.find(m => m.id === id);
     return member ? member.name : id;
 }
 
@@ -634,18 +1103,26 @@ function getMemberType(id) {
     return member?.type || 'active';
 }
 
-function formatDate(dateStr) {
-    const date = new Date(dateStr + 'T00:00:00');
+// formatDate and formatTime show a game's start in the viewer's time zone:
+// the one the server rendered for them, or else the browser's
+function formatDate(game) {
     const options = { weekday: 'long', month: 'short', day: 'numeric' };
-    return date.toLocaleDateString('en-US', options);
+    if (game.viewer) {
+        return new Date(game.viewer.date + 'T00:00:00').toLocaleDateString('en-US', options);
+    }
+    return new Date(game.startsAt).toLocaleDateString('en-US', options);
 }
 
-function formatTime(timeStr) {
-    const [hours, minutes] = timeStr.split(':');
-    const hour = parseInt(hours);
-    const ampm = hour >= 12 ? 'PM' : 'AM';
-    const hour12 = hour % 12 || 12;
-    return `${hour12}:${minutes} ${ampm} ET`;
+function formatTime(game) {
+    if (game.viewer) {
+        const [hours, minutes] = game.viewer.time.split(':');
+        const hour = parseInt(hours);
+        const ampm = hour >= 12 ? 'PM' : 'AM';
+        const hour12 = hour % 12 || 12;
+        const zone = game.viewer.label.split(' ').pop();
+        return `${hour12}:${minutes} ${ampm} ${zone}`;
+    }
+    return new Date(game.startsAt).toLocaleTimeString('en-US', { hour: 'numeric', minute: '2-digit', timeZoneName: 'short' });
 }
 
 // ==================== RENDER FUNCTIONS ====================
@@ -746,7 +1223,7 @@ function renderGameCard(game) {
     }).join('');
 
     // Calculate countdown
-    const countdown = getGameCountdown(game.date, game.time);
+    const countdown = getGameCountdown(game);
     const countdownClass = countdown.urgent ? 'urgent' : '';
 
     // Game mode badge (always show)
@@ -760,9 +1237,9 @@ function renderGameCard(game) {
         <div class="game-card ${isOnRoster ? 'on-roster' : ''}" data-game-id="${game.id}">
             <div class="game-header-centered">
                 <div class="game-datetime">
-                    <span class="game-date">${formatDate(game.date)}</span>
+                    <span class="game-date">${formatDate(game)}</span>
                     <span class="datetime-separator">•</span>
-                    <span class="game-time">${formatTime(game.time)}</span>
+                    <span class="game-time">${formatTime(game)}</span>
                 </div>
                 <div class="game-countdown ${countdownClass}">
                     <span class="countdown-label">Gametime in:</span>
@@ -770,7 +1247,7 @@ function renderGameCard(game) {
                 </div>
             </div>
             <div class="game-mode-badge">${gameMode}</div>
-            <div class="game-opponent">vs ${game.opponent}</div>
+            <div class="game-opponent">vs ${opponentLink(game)}</div>
 
             ${rosterPlayers ? `
                 <div class="final-roster-section">
@@ -993,12 +1470,16 @@ function renderManageGames() {
     container.innerHTML = state.games.map(game => `
         <div class="manage-game-card">
             <div class="game-info">
-                <strong>${formatDate(game.date)}</strong> at ${formatTime(game.time)}
+                <strong>${formatDate(game)}</strong> at ${formatTime(game)}
                 <br>vs ${game.opponent}
                 ${game.league ? `<span class="game-tag">${game.league}${game.division ? ` - ${game.division}` : ''}</span>` : ''}
+                ${game.result ? `<span class="game-tag result-tag result-${game.result.outcome}">${formatResult(game.result)}</span>` : ''}
                 ${game.notes ? `<br><em>${game.notes}</em>` : ''}
             </div>
             <div class="game-actions">
+                ${new Date(game.startsAt) <= new Date() ? `<button class="btn btn-secondary btn-small" onclick="recordResult('${game.id}')">Result</button>` : ''}
+                ${game.result ? `<button class="btn btn-discord-small btn-small" onclick="postResultToDiscord('${game.id}')">Post Result</button>` : ''}
+                ${new Date(game.startsAt) <= new Date() ? `<button class="btn btn-secondary btn-small" onclick="importStats('${game.id}')">Import Stats</button>` : ''}
                 <button class="btn btn-secondary btn-small" onclick="editGame('${game.id}')">Edit</button>
                 <button class="btn btn-danger btn-small" onclick="deleteGame('${game.id}')">Delete</button>
             </div>
@@ -1021,17 +1502,34 @@ async function setAvailability(gameId, isAvailable) {
         if (game) {
             game.available = result.available;
             game.unavailable = result.unavailable;
+            game.version = result.version;
             renderGames();
         }
     }
 }
 
+// askSeriesScope asks which games of a series a change applies to. It
+// returns '' for games outside a series and null if the manager cancels.
+function askSeriesScope(game, action) {
+    if (!game?.seriesId) return '';
+    const answer = prompt(`This game is part of a series. ${action}:\n1 = this game only\n2 = this and following games\n3 = all upcoming games`, '1');
+    if (answer === null) return null;
+    return { '1': 'this', '2': 'following', '3': 'all' }[answer.trim()] || null;
+}
+
 async function deleteGame(gameId) {
-    if (!confirm('Are you sure you want to delete this game?')) return;
+    const game = state.games.find(g => g.id === gameId);
+    const scope = askSeriesScope(game, 'Delete');
+    if (scope === null) return;
+    if (!scope && !confirm('Are you sure you want to delete this game?')) return;
 
-    const success = await deleteGameAPI(gameId);
+    const success = await deleteGameAPI(gameId, scope);
     if (success) {
-        state.games = state.games.filter(g => g.id !== gameId);
+        if (scope && scope !== 'this') {
+            await fetchData();
+        } else {
+            state.games = state.games.filter(g => g.id !== gameId);
+        }
         renderAll();
     }
 }
@@ -1046,8 +1544,8 @@ function editGame(gameId) {
     document.getElementById('gameDate').value = game.date;
     document.getElementById('gameTime').value = game.time;
     document.getElementById('opponent').value = game.opponent;
-    document.getElementById('gameLeague').value = game.league || '';
-    document.getElementById('gameDivision').value = game.division || '';
+    document.getElementById('gameLeague').value = game.leagueId || '';
+    document.getElementById('gameDivision').value = game.divisionId || '';
     document.getElementById('gameNotes').value = game.notes || '';
 
     // Handle game mode
@@ -1109,6 +1607,12 @@ function updateFormMode(isEditing) {
     if (cancelBtn) {
         cancelBtn.style.display = isEditing ? 'inline-block' : 'none';
     }
+
+    // Edits can't turn a game into a series
+    const repeatRow = document.getElementById('repeatRow');
+    if (repeatRow) {
+        repeatRow.style.display = isEditing ? 'none' : '';
+    }
 }
 
 function handleGameLinkParam(gameId) {
@@ -1150,7 +1654,7 @@ function showQuickAvailabilityModal(game) {
     if (!isLoggedIn) {
         modalTitle = '🎮 Game Alert';
         modalContent = `
-            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game.date)}</strong> at <strong>${formatTime(game.time)}</strong></p>
+            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game)}</strong> at <strong>${formatTime(game)}</strong></p>
             <p class="modal-help">Log in with Discord to mark your availability.</p>
             <div class="modal-buttons">
                 <button class="btn btn-discord" onclick="loginForGame('${game.id}')">
@@ -1163,7 +1667,7 @@ function showQuickAvailabilityModal(game) {
     } else if (isOnRoster) {
         modalTitle = '📋 You\'re on the Roster!';
         modalContent = `
-            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game.date)}</strong> at <strong>${formatTime(game.time)}</strong></p>
+            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game)}</strong> at <strong>${formatTime(game)}</strong></p>
             <p class="modal-help">Can't make it? Request a sub to cover for you.</p>
             <div class="modal-buttons">
                 <button class="btn btn-available" onclick="closeQuickModal()">I'll Be There! ✓</button>
@@ -1173,7 +1677,7 @@ function showQuickAvailabilityModal(game) {
     } else if (!hasResponded) {
         modalTitle = '🎮 Can You Play?';
         modalContent = `
-            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game.date)}</strong> at <strong>${formatTime(game.time)}</strong></p>
+            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game)}</strong> at <strong>${formatTime(game)}</strong></p>
             <p class="modal-help">Let us know if you're available for this game.</p>
             <div class="modal-buttons">
                 <button class="btn btn-available" onclick="closeQuickModal(); setAvailability('${game.id}', true)">I Can Play ✓</button>
@@ -1185,7 +1689,7 @@ function showQuickAvailabilityModal(game) {
         const isAvailable = game.available?.includes(state.currentPlayer);
         modalTitle = '✅ Response Recorded';
         modalContent = `
-            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game.date)}</strong> at <strong>${formatTime(game.time)}</strong></p>
+            <p><strong>${game.opponent}</strong> on <strong>${formatDate(game)}</strong> at <strong>${formatTime(game)}</strong></p>
             <p class="modal-help">You marked: <strong>${isAvailable ? 'Available' : 'Unavailable'}</strong></p>
             <p class="modal-help">Want to change your response?</p>
             <div class="modal-buttons">
@@ -1242,6 +1746,7 @@ async function withdrawFromRoster(gameId) {
             game.withdrawals = result.withdrawals;
             game.available = result.available;
             game.unavailable = result.unavailable;
+            game.version = result.version;
             renderGames();
         }
         alert('Managers have been notified to find a sub for you.');
@@ -1257,6 +1762,73 @@ async function postToDiscord(gameId, mentionPlayers = false) {
     }
 }
 
+// formatResult shows a result as e.g. "W 3-1" or "L (forfeit)"
+function formatResult(result) {
+    const letter = { win: 'W', loss: 'L', draw: 'D' }[result.outcome] || '?';
+    if (result.forfeit) return `${letter} (forfeit)`;
+    return `${letter} ${result.ourScore}-${result.theirScore}`;
+}
+
+// recordResult asks for the score as "ours-theirs", or "ff" / "ff us" for a
+// forfeit by them or us
+async function recordResult(gameId) {
+    const game = state.games.find(g => g.id === gameId);
+    if (!game) return;
+
+    const current = game.result ? (game.result.forfeit ? (game.result.forfeit === 'us' ? 'ff us' : 'ff') : `${game.result.ourScore}-${game.result.theirScore}`) : '';
+    const input = prompt(`Result vs ${game.opponent} (our score-their score, "ff" if they forfeited, "ff us" if we did):`, current);
+    if (input === null) return;
+
+    const value = input.trim().toLowerCase();
+    let result;
+    if (value === 'ff' || value === 'ff them') {
+        result = { forfeit: 'them' };
+    } else if (value === 'ff us') {
+        result = { forfeit: 'us' };
+    } else {
+        const match = value.match(/^(\d+)\s*[-:]\s*(\d+)$/);
+        if (!match) {
+            showError('Enter the score like 3-1');
+            return;
+        }
+        result = { ourScore: parseInt(match[1], 10), theirScore: parseInt(match[2], 10) };
+    }
+
+    const updated = await setResultAPI(gameId, result, game.version);
+    if (updated) {
+        Object.assign(game, updated);
+        renderAll();
+    }
+}
+
+async function postResultToDiscord(gameId) {
+    try {
+        await postResultToDiscordAPI(gameId);
+        alert('Result posted to Discord!');
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+// importStats uploads a CSV stat sheet for a finished game: a "player"
+// column plus one column per stat, e.g. kills,deaths,objectives,mvp
+function importStats(gameId) {
+    const input = document.createElement('input');
+    input.type = 'file';
+    input.accept = '.csv,text/csv';
+    input.onchange = async () => {
+        const file = input.files[0];
+        if (!file) return;
+        try {
+            const sheet = await importStatsAPI(gameId, await file.text());
+            alert(`Imported stats for ${sheet.lines.length} player(s)`);
+        } catch (error) {
+            showError(error.message);
+        }
+    };
+    input.click();
+}
+
 async function announceGame(gameId) {
     try {
         const response = await fetch(`${API_BASE}/games/${gameId}/announce`, {
@@ -1473,10 +2045,11 @@ async function saveRoster(gameId) {
         return;
     }
 
-    const result = await updateRosterAPI(gameId, roster, subs);
+    const result = await updateRosterAPI(gameId, roster, subs, game.version);
     if (result) {
         game.roster = result.roster;
         game.subs = result.subs;
+        game.version = result.version;
         document.getElementById('rosterModal').classList.remove('active');
         // Clear temp arrays
         tempRoster = [];
@@ -1493,6 +2066,217 @@ async function saveWebhookSetting() {
     }
 }
 
+// ==================== CALENDAR FEEDS ====================
+
+function feedLabel(feed) {
+    if (feed.kind === 'league') {
+        const league = state.leagues.find(l => l.id === feed.leagueId);
+        return `League: ${league ? league.name : 'removed league'}`;
+    }
+    if (feed.kind === 'player') {
+        const member = allMembers.find(m => m.id === feed.memberId);
+        return `Player: ${member ? member.name : feed.memberId}`;
+    }
+    return 'Whole team';
+}
+
+async function loadFeeds() {
+    const container = document.getElementById('feedsList');
+    if (!container) return;
+
+    const select = document.getElementById('feedLeague');
+    if (select) {
+        select.innerHTML = '<option value="">Select League</option>' +
+            state.leagues.map(l => `<option value="${l.id}">${l.name}</option>`).join('');
+    }
+
+    const feeds = await fetchFeedsAPI();
+    container.innerHTML = feeds.map(feed => `
+        <div class="item-row">
+            <span>${feedLabel(feed)}</span>
+            <input type="text" value="${feed.url}" readonly onclick="this.select()">
+            <button class="btn-remove" onclick="revokeFeed('${feed.token}')" title="Revoke">×</button>
+        </div>
+    `).join('') || '<p class="no-items">No feeds yet</p>';
+}
+
+async function addFeed(kind) {
+    const feed = { kind };
+    if (kind === 'league') {
+        feed.leagueId = document.getElementById('feedLeague')?.value;
+        if (!feed.leagueId) {
+            showError('Please select a league');
+            return;
+        }
+    }
+    if (await createFeedAPI(feed)) {
+        await loadFeeds();
+    }
+}
+
+async function revokeFeed(token) {
+    if (!confirm('Revoke this feed? Calendars subscribed to it will stop updating.')) return;
+    if (await revokeFeedAPI(token)) {
+        await loadFeeds();
+    }
+}
+
+// downloadExport downloads the schedule or availability matrix of the
+// viewed season as CSV, narrowed by the export form's dates and league
+function downloadExport(kind) {
+    const params = new URLSearchParams({ format: 'csv' });
+    if (state.seasonId) params.set('season', state.seasonId);
+    const from = document.getElementById('exportFrom')?.value;
+    const to = document.getElementById('exportTo')?.value;
+    const league = document.getElementById('exportLeague')?.value;
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    if (league) params.set('league', league);
+    window.location.href = `${API_BASE}/export/${kind}?${params}`;
+}
+
+// loadConflicts lists upcoming games that overlap, or share a rostered
+// player, in the Conflicts section
+async function loadConflicts() {
+    const container = document.getElementById('conflictsList');
+    if (!container) return;
+    const conflicts = await fetchConflictsAPI();
+    container.innerHTML = conflicts.map(c => {
+        const game = state.games.find(g => g.id === c.gameId);
+        const label = game ? `${formatDate(game)} ${formatTime(game)} vs ${game.opponent}` : c.gameId;
+        return `
+        <div class="item-row error">
+            <span>${label}: ${c.message}</span>
+        </div>
+    `;
+    }).join('') || '<p class="no-items">No conflicts</p>';
+}
+
+async function loadGameDurations() {
+    const container = document.getElementById('durationsList');
+    if (!container) return;
+    const durations = await fetchGameDurationsAPI();
+    if (!durations) return;
+    document.getElementById('durationMinutes').placeholder = `Minutes (default ${durations.default})`;
+    container.innerHTML = Object.entries(durations.modes).map(([mode, minutes]) => `
+        <div class="item-row">
+            <span>${mode}: ${minutes} min</span>
+            <button class="btn-remove" onclick="clearGameDuration('${mode}')" title="Use the default">×</button>
+        </div>
+    `).join('') || '<p class="no-items">Every mode uses the default</p>';
+}
+
+async function setGameDuration() {
+    const mode = document.getElementById('durationMode').value.trim();
+    const minutes = parseInt(document.getElementById('durationMinutes').value, 10);
+    if (!mode || !minutes) {
+        showError('Please enter a game mode and minutes');
+        return;
+    }
+    try {
+        await setGameDurationAPI(mode, minutes);
+        document.getElementById('durationMode').value = '';
+        document.getElementById('durationMinutes').value = '';
+        await loadGameDurations();
+        await loadConflicts();
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+async function clearGameDuration(mode) {
+    try {
+        await setGameDurationAPI(mode, 0);
+        await loadGameDurations();
+        await loadConflicts();
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+function fixtureRowLabel(row) {
+    if (row.error) return `Line ${row.line}: ${row.error}`;
+    const game = row.game;
+    let label = `${game.date} ${game.time} vs ${game.opponent}`;
+    if (row.newOpponent) label += ' (new opponent)';
+    if (row.duplicateOf) label += ' (already scheduled, skipped)';
+    if (row.duplicateLine) label += ` (same as line ${row.duplicateLine}, skipped)`;
+    return label;
+}
+
+function renderFixtureRows(rows) {
+    const container = document.getElementById('fixturesPreview');
+    container.innerHTML = rows.map(row => `
+        <div class="item-row${row.error ? ' error' : ''}">
+            <span>${fixtureRowLabel(row)}</span>
+        </div>
+    `).join('');
+
+    const button = document.getElementById('fixturesImportBtn');
+    const count = rows.filter(r => r.game && !r.duplicateOf && !r.duplicateLine).length;
+    const ok = count > 0 && !rows.some(r => r.error);
+    button.style.display = ok ? '' : 'none';
+    button.textContent = `Import ${count} Game${count === 1 ? '' : 's'}`;
+}
+
+async function previewFixtures() {
+    const file = document.getElementById('fixturesFile')?.files[0];
+    if (!file) {
+        showError('Please choose a file');
+        return;
+    }
+    try {
+        const preview = await importFixturesAPI(file, document.getElementById('fixturesLeague').value, false);
+        renderFixtureRows(preview.rows);
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+async function importFixtures() {
+    const input = document.getElementById('fixturesFile');
+    const file = input?.files[0];
+    if (!file) return;
+    try {
+        const imported = await importFixturesAPI(file, document.getElementById('fixturesLeague').value, true);
+        state.games.push(...imported.games);
+        await fetchOpponents();
+        renderAll();
+        input.value = '';
+        document.getElementById('fixturesPreview').innerHTML = '';
+        document.getElementById('fixturesImportBtn').style.display = 'none';
+        alert(`Imported ${imported.games.length} game(s)`);
+    } catch (error) {
+        showError(error.message);
+    }
+}
+
+// loadMyFeed shows the player's own feed link in the account modal
+async function loadMyFeed() {
+    const input = document.getElementById('myFeedUrl');
+    const button = document.getElementById('myFeedBtn');
+    if (!input || !state.currentPlayer) return;
+
+    const feeds = await fetchFeedsAPI();
+    const mine = feeds.find(f => f.kind === 'player' && f.memberId === state.currentPlayer);
+    input.value = mine ? mine.url : '';
+    input.dataset.token = mine ? mine.token : '';
+    if (button) button.textContent = mine ? 'Reset Link' : 'Get Link';
+}
+
+// createMyFeed makes a new personal feed, revoking the old link if any
+async function createMyFeed() {
+    const input = document.getElementById('myFeedUrl');
+    const oldToken = input?.dataset.token;
+    if (oldToken) {
+        if (!confirm('Reset your link? Calendars using the old one will stop updating.')) return;
+        if (!await revokeFeedAPI(oldToken)) return;
+    }
+    if (await createFeedAPI({ kind: 'player' })) {
+        await loadMyFeed();
+    }
+}
+
 async function loadWebhookSetting() {
     try {
         const response = await fetch(`${API_BASE}/webhook`, { credentials: 'include' });
@@ -1756,7 +2540,7 @@ async function removeMember(memberId) {
     const member = allMembers.find(m => m.id === memberId);
     if (!member) return;
 
-    if (!confirm(`Remove ${member.name} from the roster?`)) return;
+    if (!confirm(`Remove ${member.name} from the roster? They will also be taken off upcoming games; past games keep their name.`)) return;
 
     const success = await removeMemberAPI(memberId);
     if (success) {
@@ -1785,11 +2569,14 @@ async function addMemberAPI(member) {
             credentials: 'include',
             body: JSON.stringify(member)
         });
-        if (!response.ok) throw new Error('Failed to add member');
+        if (!response.ok) {
+            const data = await response.json();
+            throw new Error(data.error || 'Failed to add member');
+        }
         return await response.json();
     } catch (error) {
         console.error('Error adding member:', error);
-        alert('Failed to add member');
+        alert(error.message);
         return null;
     }
 }
@@ -1892,11 +2679,8 @@ function updateETClock() {
     clockEl.textContent = etTime;
 }
 
-function getGameCountdown(dateStr, timeStr) {
-    const gameDateTime = new Date(`${dateStr}T${timeStr}:00`);
-    // Adjust for ET timezone
-    const etOffset = getETOffset();
-    gameDateTime.setHours(gameDateTime.getHours() - etOffset);
+function getGameCountdown(game) {
+    const gameDateTime = new Date(game.startsAt);
 
     const now = new Date();
     const diff = gameDateTime - now;
@@ -1918,19 +2702,6 @@ function getGameCountdown(dateStr, timeStr) {
     }
 }
 
-function getETOffset() {
-    // Get current ET offset from UTC (handles DST)
-    const now = new Date();
-    const utc = now.getTime() + (now.getTimezoneOffset() * 60000);
-    const et = new Date(utc + (-5 * 3600000)); // EST is UTC-5
-    // Check if DST
-    const jan = new Date(now.getFullYear(), 0, 1);
-    const jul = new Date(now.getFullYear(), 6, 1);
-    const stdOffset = Math.max(jan.getTimezoneOffset(), jul.getTimezoneOffset());
-    const isDST = now.getTimezoneOffset() < stdOffset;
-    return isDST ? -4 : -5; // EDT is UTC-4, EST is UTC-5
-}
-
 // ==================== MY ACCOUNT ====================
 
 function showMyAccount() {
@@ -1947,8 +2718,10 @@ function showMyAccount() {
     // Load saved email/phone (from user object if available)
     document.getElementById('accountEmail').value = state.user.email || '';
     document.getElementById('accountPhone').value = state.user.phone || '';
+    populateTimeZones(state.user.timeZone || '');
 
     modal.classList.add('active');
+    loadMyFeed();
 
     // Add auto-save listener for status toggle
     document.querySelectorAll('input[name="playerStatus"]').forEach(radio => {
@@ -1974,9 +2747,22 @@ async function handleStatusChange(e) {
     }
 }
 
+// populateTimeZones fills the account time zone picker, keeping the
+// user's current choice even if the browser doesn't list it
+function populateTimeZones(current) {
+    const select = document.getElementById('accountTimeZone');
+    if (!select) return;
+    const zones = Intl.supportedValuesOf ? Intl.supportedValuesOf('timeZone') : [];
+    if (current && !zones.includes(current)) zones.unshift(current);
+    select.innerHTML = '<option value="">Automatic</option>' +
+        zones.map(z => `<option value="${z}">${z.replace(/_/g, ' ')}</option>`).join('');
+    select.value = current;
+}
+
 async function saveAccountSettings() {
     const email = document.getElementById('accountEmail').value.trim();
     const phone = document.getElementById('accountPhone').value.trim();
+    const timeZone = document.getElementById('accountTimeZone').value;
 
     // Save to user profile
     try {
@@ -1984,12 +2770,18 @@ async function saveAccountSettings() {
             method: 'PUT',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'include',
-            body: JSON.stringify({ email, phone })
+            body: JSON.stringify({ email, phone, timeZone })
         });
 
         if (response.ok) {
             state.user.email = email;
             state.user.phone = phone;
+            if (state.user.timeZone !== timeZone) {
+                // Game times come back rendered in the new zone
+                state.user.timeZone = timeZone;
+                await fetchData();
+                renderAll();
+            }
             document.getElementById('accountModal').classList.remove('active');
             alert('Settings saved!');
         } else {
@@ -2071,30 +2863,52 @@ function initEventHandlers() {
                 date: document.getElementById('gameDate').value,
                 time: document.getElementById('gameTime').value,
                 opponent: document.getElementById('opponent').value,
-                league: document.getElementById('gameLeague')?.value || '',
-                division: document.getElementById('gameDivision')?.value || '',
+                leagueId: document.getElementById('gameLeague')?.value || '',
+                divisionId: document.getElementById('gameDivision')?.value || '',
                 gameMode: gameMode,
                 teamSize: parseInt(document.getElementById('teamSize')?.value) || 10,
                 notes: document.getElementById('gameNotes').value
             };
 
+            const repeat = document.getElementById('gameRepeat')?.value || '';
+
             let result;
             if (state.editingGameId) {
-                // Update existing game
-                result = await updateGame(state.editingGameId, gameData);
+                // Update existing game, and maybe the rest of its series
+                const editing = state.games.find(g => g.id === state.editingGameId);
+                const scope = askSeriesScope(editing, 'Apply changes to');
+                if (scope === null) return;
+                result = await updateGame(state.editingGameId, gameData, editing?.version, scope);
                 if (result) {
-                    const index = state.games.findIndex(g => g.id === state.editingGameId);
-                    if (index !== -1) {
-                        state.games[index] = result;
+                    if (scope && scope !== 'this') {
+                        await fetchData();
+                    } else {
+                        const index = state.games.findIndex(g => g.id === state.editingGameId);
+                        if (index !== -1) {
+                            state.games[index] = result;
+                        }
                     }
                     state.editingGameId = null;
                     updateFormMode(false);
                 }
+            } else if (repeat) {
+                // Create a recurring series; until is inclusive
+                const until = document.getElementById('gameRepeatUntil')?.value;
+                const rrule = until ? `${repeat};UNTIL=${until.replace(/-/g, '')}` : repeat;
+                const created = await createSeriesAPI({ ...gameData, rrule });
+                if (created) {
+                    state.games.push(...created.games);
+                    await fetchOpponents();
+                    // Only the first game of a series gets announced
+                    result = created.games[0];
+                }
             } else {
                 // Create new game
                 result = await createGame(gameData);
                 if (result) {
                     state.games.push(result);
+                    // The opponent may be new
+                    await fetchOpponents();
                 }
             }
 
@@ -2215,6 +3029,12 @@ async function init() {
     // Start the ET clock
     startETClock();
 
+    // A link to a team (e.g. from Discord) switches to it
+    const teamParam = new URLSearchParams(window.location.search).get('team');
+    if (teamParam) {
+        rememberTeam(teamParam);
+    }
+
     // Check authentication
     const isAuthenticated = await checkAuth();
     updateAuthUI();
@@ -2233,13 +3053,17 @@ async function init() {
 
     // Fetch data and render
     await fetchData();
+    await fetchTeams();
+    await fetchSeasons();
     await fetchLeagues();
     await fetchDivisions();
+    await fetchOpponents();
 
     // Fetch members from API (updates isVet status etc.)
     const members = await fetchMembers();
     if (members.active) activeMembers = members.active;
     if (members.subs) subMembers = members.subs;
+    if (members.former) formerMembers = members.former;
     // Rebuild allMembers with updated data
     allMembers = [
         ...activeMembers.map(m => ({ ...m, type: 'active' })),
@@ -2276,12 +3100,16 @@ async function init() {
     // Load webhook setting for managers
     if (state.isManager) {
         loadWebhookSetting();
+        loadFeeds();
+        loadConflicts();
+        loadGameDurations();
     }
 
     // Auto-refresh every 30 seconds (also updates countdown timers)
     setInterval(async () => {
         await fetchData();
         renderAll();
+        if (state.isManager) loadConflicts();
     }, 30000);
 
     // Update countdowns every minute
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ==================== AUDIT LOG ====================
+
+// AuditEvent records one change made through the API. TeamID is the team
+// it was made for, "" for changes that aren't any one team's. Before and
+// After are JSON snapshots of the entity; Before is null for creates and
+// After for deletes.
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	TeamID     string          `json:"teamId,omitempty"`
+	At         time.Time       `json:"at"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entityType"`
+	EntityID   string          `json:"entityId"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+// AuditFilter narrows ListAuditEvents; zero fields don't filter.
+type AuditFilter struct {
+	TeamID     string
+	EntityType string
+	EntityID   string
+	Actor      string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// Audited entity types
+const (
+	auditGame       = "game"
+	auditMember     = "member"
+	auditUser       = "user"
+	auditPreference = "preference"
+	auditSetting    = "setting"
+	auditTrash      = "trash"
+	auditSeason     = "season"
+	auditLeague     = "league"
+	auditDivision   = "division"
+	auditOpponent   = "opponent"
+	auditTeam       = "team"
+	auditSeries     = "series"
+	auditFeed       = "feed"
+)
+
+// auditSystemActor is recorded for changes made by the server itself rather
+// than a signed-in user.
+const auditSystemActor = "system"
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditJSON snapshots v for an audit event; nil values and nil pointers
+// become an absent snapshot.
+func auditJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+// recordAudit writes an audit event for a team; teamID is "" for changes
+// that aren't any one team's. Failing to record one is logged but doesn't
+// fail the request, since the change itself has already been made.
+func recordAudit(teamID, actor, action, entityType, entityID string, before, after interface{}) {
+	event := AuditEvent{
+		TeamID:     teamID,
+		At:         time.Now().UTC(),
+		Actor:      actor,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+	}
+	if err := store.RecordAudit(event); err != nil {
+		log.Printf("Error recording audit event %s %s/%s: %v", action, entityType, entityID, err)
+	}
+}
+
+// maskSecret keeps enough of a secret setting to tell values apart in the
+// audit log without storing the whole thing.
+func maskSecret(value string) string {
+	if len(value) > 20 {
+		return value[:20] + "..."
+	}
+	return value
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp or a plain date.
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := AuditFilter{
+		TeamID:     team.ID,
+		EntityType: query.Get("entity"),
+		EntityID:   query.Get("entityId"),
+		Actor:      query.Get("actor"),
+		Limit:      defaultAuditLimit,
+	}
+
+	if v := query.Get("since"); v != "" {
+		t, err := parseAuditTime(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid since time")
+			return
+		}
+		filter.Since = t
+	}
+	if v := query.Get("until"); v != "" {
+		t, err := parseAuditTime(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid until time")
+			return
+		}
+		filter.Until = t
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		if n > maxAuditLimit {
+			n = maxAuditLimit
+		}
+		filter.Limit = n
+	}
+
+	events, err := store.ListAuditEvents(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
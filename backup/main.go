@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -17,9 +18,12 @@ import (
 // Data structures for export
 type Game struct {
 	ID          string   `json:"id"`
-	Date        string   `json:"date"`
-	Time        string   `json:"time"`
+	StartsAt    string   `json:"startsAt"`
+	TimeZone    string   `json:"timeZone"`
 	Opponent    string   `json:"opponent"`
+	OpponentID  string   `json:"opponentId,omitempty"`
+	LeagueID    string   `json:"leagueId,omitempty"`
+	DivisionID  string   `json:"divisionId,omitempty"`
 	League      string   `json:"league"`
 	Division    string   `json:"division"`
 	GameMode    string   `json:"gameMode"`
@@ -31,26 +35,111 @@ type Game struct {
 	Subs        []string `json:"subs"`
 	Withdrawals []string `json:"withdrawals"`
 	Reminded    bool     `json:"reminded"`
+	TeamID      string   `json:"teamId,omitempty"`
+	SeasonID    string   `json:"seasonId,omitempty"`
+	DeletedAt   string   `json:"deletedAt,omitempty"`
+	Result      *Result  `json:"result,omitempty"`
+	// SeriesID and OccurrenceAt are set on games generated by a series
+	SeriesID     string `json:"seriesId,omitempty"`
+	OccurrenceAt string `json:"occurrenceAt,omitempty"`
+	// Stats holds each player's stat line, keyed by member ID
+	Stats map[string]json.RawMessage `json:"stats,omitempty"`
+}
+
+// Result is a game's recorded outcome; Rounds is kept as stored.
+type Result struct {
+	OurScore   int             `json:"ourScore"`
+	TheirScore int             `json:"theirScore"`
+	Outcome    string          `json:"outcome"`
+	Forfeit    string          `json:"forfeit,omitempty"`
+	Rounds     json.RawMessage `json:"rounds"`
+	RecordedBy string          `json:"recordedBy,omitempty"`
+	RecordedAt string          `json:"recordedAt"`
+}
+
+type Team struct {
+	ID                 string `json:"id"`
+	Slug               string `json:"slug"`
+	Name               string `json:"name"`
+	DiscordGuildID     string `json:"discordGuildId"`
+	DiscordManagerRole string `json:"discordManagerRole"`
+}
+
+type Season struct {
+	ID         string `json:"id"`
+	TeamID     string `json:"teamId"`
+	Name       string `json:"name"`
+	StartsAt   string `json:"startsAt"`
+	EndsAt     string `json:"endsAt,omitempty"`
+	Active     bool   `json:"active"`
+	ArchivedAt string `json:"archivedAt,omitempty"`
+}
+
+// League is a row of the leagues or divisions table; only divisions have a
+// LeagueID.
+type League struct {
+	ID              string `json:"id"`
+	SeasonID        string `json:"seasonId"`
+	LeagueID        string `json:"leagueId,omitempty"`
+	Name            string `json:"name"`
+	ShortCode       string `json:"shortCode"`
+	Color           string `json:"color"`
+	DefaultGameMode string `json:"defaultGameMode"`
+	DefaultTeamSize int    `json:"defaultTeamSize"`
+}
+
+// Series is a recurring game series; Exceptions is kept as stored.
+type Series struct {
+	ID         string          `json:"id"`
+	TeamID     string          `json:"teamId"`
+	SeasonID   string          `json:"seasonId,omitempty"`
+	RRule      string          `json:"rrule"`
+	StartsAt   string          `json:"startsAt"`
+	TimeZone   string          `json:"timeZone"`
+	Exceptions json.RawMessage `json:"exceptions"`
+	CreatedBy  string          `json:"createdBy,omitempty"`
+}
+
+type Opponent struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+	Tag     string   `json:"tag"`
+	Region  string   `json:"region"`
+	Logo    string   `json:"logo"`
+	Discord string   `json:"discord"`
+	Notes   string   `json:"notes"`
 }
 
 type User struct {
 	DiscordID   string `json:"discordId"`
 	Username    string `json:"username"`
 	DisplayName string `json:"displayName"`
-	PlayerID    string `json:"playerId"`
-	IsManager   bool   `json:"isManager"`
 	Email       string `json:"email"`
 	Phone       string `json:"phone"`
+	TimeZone    string `json:"timeZone"`
+}
+
+// TeamUser is a user's membership of one team.
+type TeamUser struct {
+	TeamID    string `json:"teamId"`
+	DiscordID string `json:"discordId"`
+	PlayerID  string `json:"playerId"`
+	IsManager bool   `json:"isManager"`
 }
 
 type Member struct {
 	ID        string `json:"id"`
+	TeamID    string `json:"teamId"`
 	Name      string `json:"name"`
 	Year      int    `json:"year"`
 	Region    string `json:"region"`
 	Note      string `json:"note"`
 	IsSub     bool   `json:"isSub"`
 	SortOrder int    `json:"sortOrder"`
+	DeletedAt string `json:"deletedAt,omitempty"`
+	// Aliases lists every name the member has gone by, oldest first
+	Aliases []string `json:"aliases"`
 }
 
 type Preference struct {
@@ -65,8 +154,15 @@ type Setting struct {
 
 type Backup struct {
 	Timestamp   string       `json:"timestamp"`
+	Teams       []Team       `json:"teams"`
+	Seasons     []Season     `json:"seasons"`
+	Leagues     []League     `json:"leagues"`
+	Divisions   []League     `json:"divisions"`
+	Opponents   []Opponent   `json:"opponents"`
+	Series      []Series     `json:"series"`
 	Games       []Game       `json:"games"`
 	Users       []User       `json:"users"`
+	TeamUsers   []TeamUser   `json:"teamUsers"`
 	Members     []Member     `json:"members"`
 	Preferences []Preference `json:"preferences"`
 	Settings    []Setting    `json:"settings"`
@@ -103,6 +199,40 @@ func main() {
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 	}
 
+	// Export teams
+	backup.Teams, err = exportTeams()
+	if err != nil {
+		log.Printf("Warning: Failed to export teams: %v", err)
+	}
+
+	// Export seasons
+	backup.Seasons, err = exportSeasons()
+	if err != nil {
+		log.Printf("Warning: Failed to export seasons: %v", err)
+	}
+
+	// Export leagues and divisions
+	backup.Leagues, err = exportLeagues("leagues")
+	if err != nil {
+		log.Printf("Warning: Failed to export leagues: %v", err)
+	}
+	backup.Divisions, err = exportLeagues("divisions")
+	if err != nil {
+		log.Printf("Warning: Failed to export divisions: %v", err)
+	}
+
+	// Export opponents
+	backup.Opponents, err = exportOpponents()
+	if err != nil {
+		log.Printf("Warning: Failed to export opponents: %v", err)
+	}
+
+	// Export series, then their games
+	backup.Series, err = exportSeries()
+	if err != nil {
+		log.Printf("Warning: Failed to export series: %v", err)
+	}
+
 	// Export games
 	backup.Games, err = exportGames()
 	if err != nil {
@@ -114,6 +244,10 @@ func main() {
 	if err != nil {
 		log.Printf("Warning: Failed to export users: %v", err)
 	}
+	backup.TeamUsers, err = exportTeamUsers()
+	if err != nil {
+		log.Printf("Warning: Failed to export team users: %v", err)
+	}
 
 	// Export members
 	backup.Members, err = exportMembers()
@@ -148,27 +282,166 @@ func main() {
 	log.Println("Backup completed successfully!")
 }
 
-func parseJSONArray(s string) []string {
-	if s == "" {
-		return []string{}
+func exportTeams() ([]Team, error) {
+	rows, err := db.Query(`
+		SELECT id, slug, name, COALESCE(discord_guild_id, ''), COALESCE(discord_manager_role, '')
+		FROM teams ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []Team
+	for rows.Next() {
+		var t Team
+		err := rows.Scan(&t.ID, &t.Slug, &t.Name, &t.DiscordGuildID, &t.DiscordManagerRole)
+		if err != nil {
+			continue
+		}
+		teams = append(teams, t)
+	}
+	return teams, nil
+}
+
+func exportSeasons() ([]Season, error) {
+	rows, err := db.Query(`
+		SELECT id, COALESCE(team_id, ''), name, starts_at, ends_at, active, archived_at
+		FROM seasons ORDER BY starts_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seasons []Season
+	for rows.Next() {
+		var s Season
+		var startsAt time.Time
+		var endsAt, archivedAt sql.NullTime
+		err := rows.Scan(&s.ID, &s.TeamID, &s.Name, &startsAt, &endsAt, &s.Active, &archivedAt)
+		if err != nil {
+			continue
+		}
+		s.StartsAt = startsAt.UTC().Format(time.RFC3339)
+		if endsAt.Valid {
+			s.EndsAt = endsAt.Time.UTC().Format(time.RFC3339)
+		}
+		if archivedAt.Valid {
+			s.ArchivedAt = archivedAt.Time.UTC().Format(time.RFC3339)
+		}
+		seasons = append(seasons, s)
+	}
+	return seasons, nil
+}
+
+// exportLeagues exports the leagues or divisions table.
+func exportLeagues(table string) ([]League, error) {
+	parent := "''"
+	if table == "divisions" {
+		parent = "COALESCE(league_id, '')"
+	}
+	rows, err := db.Query(`
+		SELECT id, season_id, ` + parent + `, name, short_code, color,
+			default_game_mode, default_team_size
+		FROM ` + table + ` ORDER BY season_id, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leagues []League
+	for rows.Next() {
+		var l League
+		err := rows.Scan(&l.ID, &l.SeasonID, &l.LeagueID, &l.Name, &l.ShortCode, &l.Color,
+			&l.DefaultGameMode, &l.DefaultTeamSize)
+		if err != nil {
+			continue
+		}
+		leagues = append(leagues, l)
+	}
+	return leagues, nil
+}
+
+func exportSeries() ([]Series, error) {
+	rows, err := db.Query(`
+		SELECT id, team_id, COALESCE(season_id, ''), rrule, starts_at, time_zone, exceptions,
+			COALESCE(created_by, '')
+		FROM game_series ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []Series
+	for rows.Next() {
+		var s Series
+		var startsAt time.Time
+		var exceptions string
+		err := rows.Scan(&s.ID, &s.TeamID, &s.SeasonID, &s.RRule, &startsAt, &s.TimeZone, &exceptions, &s.CreatedBy)
+		if err != nil {
+			continue
+		}
+		s.StartsAt = startsAt.UTC().Format(time.RFC3339)
+		s.Exceptions = json.RawMessage(exceptions)
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+func exportOpponents() ([]Opponent, error) {
+	rows, err := db.Query(`
+		SELECT id, name, tag, region, logo, discord, notes
+		FROM opponents ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
 	}
-	var arr []string
-	if err := json.Unmarshal([]byte(s), &arr); err != nil {
-		return []string{}
+	defer rows.Close()
+
+	var opponents []Opponent
+	index := make(map[string]int)
+	for rows.Next() {
+		o := Opponent{Aliases: []string{}}
+		err := rows.Scan(&o.ID, &o.Name, &o.Tag, &o.Region, &o.Logo, &o.Discord, &o.Notes)
+		if err != nil {
+			continue
+		}
+		index[o.ID] = len(opponents)
+		opponents = append(opponents, o)
 	}
-	return arr
+	rows.Close()
+
+	aliasRows, err := db.Query(`SELECT opponent_id, alias FROM opponent_aliases ORDER BY alias`)
+	if err != nil {
+		return opponents, err
+	}
+	defer aliasRows.Close()
+	for aliasRows.Next() {
+		var opponentID, alias string
+		if err := aliasRows.Scan(&opponentID, &alias); err != nil {
+			continue
+		}
+		if i, ok := index[opponentID]; ok {
+			opponents[i].Aliases = append(opponents[i].Aliases, alias)
+		}
+	}
+	return opponents, nil
 }
 
 func exportGames() ([]Game, error) {
 	rows, err := db.Query(`
-		SELECT id, date, time, opponent,
-			COALESCE(league, ''), COALESCE(division, ''),
+		SELECT id, starts_at, time_zone, opponent, COALESCE(opponent_id, ''),
+			COALESCE(league_id, ''), COALESCE(division_id, ''),
+			COALESCE((SELECT name FROM leagues WHERE leagues.id = games.league_id), ''),
+			COALESCE((SELECT name FROM divisions WHERE divisions.id = games.division_id), ''),
 			COALESCE(game_mode, 'War'), COALESCE(team_size, 10),
-			COALESCE(notes, ''), COALESCE(available, '[]'),
-			COALESCE(unavailable, '[]'), COALESCE(roster, '[]'),
-			COALESCE(subs, '[]'), COALESCE(withdrawals, '[]'),
-			COALESCE(reminded, false)
-		FROM games ORDER BY date, time
+			COALESCE(notes, ''), COALESCE(reminded, false),
+			COALESCE(team_id, ''), COALESCE(season_id, ''), deleted_at,
+			COALESCE(series_id, ''), occurrence_at
+		FROM games ORDER BY starts_at
 	`)
 	if err != nil {
 		return nil, err
@@ -176,32 +449,124 @@ func exportGames() ([]Game, error) {
 	defer rows.Close()
 
 	var games []Game
+	index := make(map[string]int)
 	for rows.Next() {
 		var g Game
-		var available, unavailable, roster, subs, withdrawals string
-		err := rows.Scan(&g.ID, &g.Date, &g.Time, &g.Opponent,
-			&g.League, &g.Division, &g.GameMode, &g.TeamSize,
-			&g.Notes, &available, &unavailable, &roster,
-			&subs, &withdrawals, &g.Reminded)
+		var startsAt time.Time
+		var deletedAt, occurrenceAt sql.NullTime
+		err := rows.Scan(&g.ID, &startsAt, &g.TimeZone, &g.Opponent, &g.OpponentID,
+			&g.LeagueID, &g.DivisionID, &g.League, &g.Division, &g.GameMode, &g.TeamSize,
+			&g.Notes, &g.Reminded, &g.TeamID, &g.SeasonID, &deletedAt,
+			&g.SeriesID, &occurrenceAt)
 		if err != nil {
 			continue
 		}
-		g.Available = parseJSONArray(available)
-		g.Unavailable = parseJSONArray(unavailable)
-		g.Roster = parseJSONArray(roster)
-		g.Subs = parseJSONArray(subs)
-		g.Withdrawals = parseJSONArray(withdrawals)
+		g.StartsAt = startsAt.UTC().Format(time.RFC3339)
+		if deletedAt.Valid {
+			g.DeletedAt = deletedAt.Time.UTC().Format(time.RFC3339)
+		}
+		if occurrenceAt.Valid {
+			g.OccurrenceAt = occurrenceAt.Time.UTC().Format(time.RFC3339)
+		}
+		g.Available = []string{}
+		g.Unavailable = []string{}
+		g.Roster = []string{}
+		g.Subs = []string{}
+		g.Withdrawals = []string{}
+		index[g.ID] = len(games)
 		games = append(games, g)
 	}
+	rows.Close()
+
+	// Rebuild the per-game lists from game_participants
+	pRows, err := db.Query(`
+		SELECT game_id, member_id, status, role
+		FROM game_participants ORDER BY position, updated_at, member_id
+	`)
+	if err != nil {
+		return games, err
+	}
+	defer pRows.Close()
+
+	for pRows.Next() {
+		var gameID, memberID, status, role string
+		if err := pRows.Scan(&gameID, &memberID, &status, &role); err != nil {
+			continue
+		}
+		i, ok := index[gameID]
+		if !ok {
+			continue
+		}
+		g := &games[i]
+		switch status {
+		case "available":
+			g.Available = append(g.Available, memberID)
+		case "unavailable":
+			g.Unavailable = append(g.Unavailable, memberID)
+		}
+		switch role {
+		case "roster":
+			g.Roster = append(g.Roster, memberID)
+		case "sub":
+			g.Subs = append(g.Subs, memberID)
+		case "withdrawn":
+			g.Withdrawals = append(g.Withdrawals, memberID)
+		}
+	}
+	pRows.Close()
+
+	rRows, err := db.Query(`
+		SELECT game_id, our_score, their_score, outcome, forfeit, rounds,
+			COALESCE(recorded_by, ''), recorded_at
+		FROM game_results
+	`)
+	if err != nil {
+		return games, err
+	}
+	defer rRows.Close()
+
+	for rRows.Next() {
+		var gameID, rounds string
+		var recordedAt time.Time
+		r := &Result{}
+		if err := rRows.Scan(&gameID, &r.OurScore, &r.TheirScore, &r.Outcome, &r.Forfeit, &rounds, &r.RecordedBy, &recordedAt); err != nil {
+			continue
+		}
+		r.Rounds = json.RawMessage(rounds)
+		r.RecordedAt = recordedAt.UTC().Format(time.RFC3339)
+		if i, ok := index[gameID]; ok {
+			games[i].Result = r
+		}
+	}
+	rRows.Close()
+
+	sRows, err := db.Query(`SELECT game_id, member_id, stats FROM player_game_stats`)
+	if err != nil {
+		return games, err
+	}
+	defer sRows.Close()
+
+	for sRows.Next() {
+		var gameID, memberID, stats string
+		if err := sRows.Scan(&gameID, &memberID, &stats); err != nil {
+			continue
+		}
+		i, ok := index[gameID]
+		if !ok {
+			continue
+		}
+		if games[i].Stats == nil {
+			games[i].Stats = make(map[string]json.RawMessage)
+		}
+		games[i].Stats[memberID] = json.RawMessage(stats)
+	}
 	return games, nil
 }
 
 func exportUsers() ([]User, error) {
 	rows, err := db.Query(`
-		SELECT discord_id, username,
-			COALESCE(display_name, ''), COALESCE(player_id, ''),
-			COALESCE(is_manager, false),
-			COALESCE(email, ''), COALESCE(phone, '')
+		SELECT discord_id, username, COALESCE(display_name, ''),
+			COALESCE(email, ''), COALESCE(phone, ''), COALESCE(time_zone, '')
 		FROM users
 	`)
 	if err != nil {
@@ -212,8 +577,7 @@ func exportUsers() ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var u User
-		err := rows.Scan(&u.DiscordID, &u.Username, &u.DisplayName,
-			&u.PlayerID, &u.IsManager, &u.Email, &u.Phone)
+		err := rows.Scan(&u.DiscordID, &u.Username, &u.DisplayName, &u.Email, &u.Phone, &u.TimeZone)
 		if err != nil {
 			continue
 		}
@@ -222,11 +586,33 @@ func exportUsers() ([]User, error) {
 	return users, nil
 }
 
+func exportTeamUsers() ([]TeamUser, error) {
+	rows, err := db.Query(`
+		SELECT team_id, discord_id, COALESCE(player_id, ''), is_manager
+		FROM team_users ORDER BY team_id, discord_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teamUsers []TeamUser
+	for rows.Next() {
+		var tu TeamUser
+		err := rows.Scan(&tu.TeamID, &tu.DiscordID, &tu.PlayerID, &tu.IsManager)
+		if err != nil {
+			continue
+		}
+		teamUsers = append(teamUsers, tu)
+	}
+	return teamUsers, nil
+}
+
 func exportMembers() ([]Member, error) {
 	rows, err := db.Query(`
-		SELECT id, name, COALESCE(year, 2025),
+		SELECT id, COALESCE(team_id, ''), name, COALESCE(year, 2025),
 			COALESCE(region, ''), COALESCE(note, ''),
-			COALESCE(is_sub, false), COALESCE(sort_order, 0)
+			COALESCE(is_sub, false), COALESCE(sort_order, 0), deleted_at
 		FROM members ORDER BY is_sub, sort_order
 	`)
 	if err != nil {
@@ -235,15 +621,37 @@ func exportMembers() ([]Member, error) {
 	defer rows.Close()
 
 	var members []Member
+	index := make(map[string]int)
 	for rows.Next() {
-		var m Member
-		err := rows.Scan(&m.ID, &m.Name, &m.Year, &m.Region,
-			&m.Note, &m.IsSub, &m.SortOrder)
+		m := Member{Aliases: []string{}}
+		var deletedAt sql.NullTime
+		err := rows.Scan(&m.ID, &m.TeamID, &m.Name, &m.Year, &m.Region,
+			&m.Note, &m.IsSub, &m.SortOrder, &deletedAt)
 		if err != nil {
 			continue
 		}
+		if deletedAt.Valid {
+			m.DeletedAt = deletedAt.Time.UTC().Format(time.RFC3339)
+		}
+		index[m.ID] = len(members)
 		members = append(members, m)
 	}
+	rows.Close()
+
+	aliasRows, err := db.Query(`SELECT member_id, alias FROM member_aliases ORDER BY created_at, alias`)
+	if err != nil {
+		return members, err
+	}
+	defer aliasRows.Close()
+	for aliasRows.Next() {
+		var memberID, alias string
+		if err := aliasRows.Scan(&memberID, &alias); err != nil {
+			continue
+		}
+		if i, ok := index[memberID]; ok {
+			members[i].Aliases = append(members[i].Aliases, alias)
+		}
+	}
 	return members, nil
 }
 
@@ -280,8 +688,8 @@ func exportSettings() ([]Setting, error) {
 		if err != nil {
 			continue
 		}
-		// Mask sensitive values
-		if s.Key == "discord_webhook" && len(s.Value) > 20 {
+		// Mask sensitive values (every team has its own webhook)
+		if strings.HasPrefix(s.Key, "discord_webhook") && len(s.Value) > 20 {
 			s.Value = s.Value[:20] + "..."
 		}
 		settings = append(settings, s)
@@ -292,6 +700,12 @@ func exportSettings() ([]Setting, error) {
 func sendToDiscord(webhookURL string, backup Backup, jsonData []byte) error {
 	// Create summary message
 	summary := fmt.Sprintf("**Database Backup - %s**\n\n"+
+		"**Teams:** %d\n"+
+		"**Seasons:** %d\n"+
+		"**Leagues:** %d\n"+
+		"**Divisions:** %d\n"+
+		"**Opponents:** %d\n"+
+		"**Series:** %d\n"+
 		"**Games:** %d\n"+
 		"**Users:** %d\n"+
 		"**Members:** %d\n"+
@@ -299,6 +713,12 @@ func sendToDiscord(webhookURL string, backup Backup, jsonData []byte) error {
 		"**Settings:** %d\n\n"+
 		"Full backup attached as JSON file.",
 		time.Now().UTC().Format("Jan 02, 2006 15:04 UTC"),
+		len(backup.Teams),
+		len(backup.Seasons),
+		len(backup.Leagues),
+		len(backup.Divisions),
+		len(backup.Opponents),
+		len(backup.Series),
 		len(backup.Games),
 		len(backup.Users),
 		len(backup.Members),
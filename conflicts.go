@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== SCHEDULING CONFLICTS ====================
+
+// A game takes up its start plus how long games of its mode run. Two of the
+// team's games that overlap can't both be played, and neither can two
+// overlapping games with the same player on both rosters, even in different
+// leagues. Creating or moving a game and rostering players check for this:
+// a conflict fails with 409 and the conflicts unless ?force=true, which
+// goes ahead and returns them as warnings.
+
+// defaultGameDuration is how long games of a mode without its own
+// duration run.
+const defaultGameDuration = 2 * time.Hour
+
+// maxGameDurationMinutes caps the duration a manager can set for a mode.
+const maxGameDurationMinutes = 24 * 60
+
+// gameDurationsSetting is the team setting holding the per-mode durations,
+// in minutes, as a JSON object.
+const gameDurationsSetting = "game_durations"
+
+// Conflict kinds
+const (
+	conflictGame   = "game"
+	conflictPlayer = "player"
+)
+
+// Conflict is a game overlapping another of the team's games. Player
+// conflicts name the member rostered on both. GameID is empty when the
+// game hasn't been saved, as when its creation is refused.
+type Conflict struct {
+	Kind          string    `json:"kind"`
+	GameID        string    `json:"gameId"`
+	OtherGameID   string    `json:"otherGameId"`
+	OtherOpponent string    `json:"otherOpponent"`
+	OtherStartsAt time.Time `json:"otherStartsAt"`
+	MemberID      string    `json:"memberId,omitempty"`
+	Member        string    `json:"member,omitempty"`
+	Message       string    `json:"message"`
+	// index is the game's place in the games findGamesConflicts checked
+	index int
+}
+
+// GameDurations is a team's game lengths in minutes: the default and the
+// game modes that replace it.
+type GameDurations struct {
+	Default int            `json:"default"`
+	Modes   map[string]int `json:"modes"`
+}
+
+// loadGameDurations returns the team's per-mode game durations in minutes.
+// A malformed setting is logged and treated as unset.
+func loadGameDurations(teamID string) (map[string]int, error) {
+	modes := make(map[string]int)
+	key := teamSettingKey(teamID, gameDurationsSetting)
+	value, err := store.GetSetting(key)
+	if err != nil || value == "" {
+		return modes, err
+	}
+	if err := json.Unmarshal([]byte(value), &modes); err != nil {
+		log.Printf("Setting %s is malformed, using the default game duration: %v", key, err)
+		return make(map[string]int), nil
+	}
+	return modes, nil
+}
+
+// gameDuration is how long a game of the mode runs.
+func gameDuration(durations map[string]int, gameMode string) time.Duration {
+	if minutes, ok := durations[gameMode]; ok && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultGameDuration
+}
+
+// longestGameDuration is the longest any of the team's games runs, which is
+// how far back a game overlapping a window can start.
+func longestGameDuration(durations map[string]int) time.Duration {
+	longest := defaultGameDuration
+	for mode := range durations {
+		if d := gameDuration(durations, mode); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// gamesOverlap reports whether a and b are played at the same time. Games
+// that end as the other starts don't overlap.
+func gamesOverlap(durations map[string]int, a, b *Game) bool {
+	return a.StartsAt.Before(b.StartsAt.Add(gameDuration(durations, b.GameMode))) &&
+		b.StartsAt.Before(a.StartsAt.Add(gameDuration(durations, a.GameMode)))
+}
+
+// conflictNames looks up member names for conflict messages.
+type conflictNames map[string]string
+
+func (n conflictNames) name(memberID string) string {
+	if name, ok := n[memberID]; ok {
+		return name
+	}
+	name := memberID
+	if member, err := store.GetMember(memberID); err == nil && member != nil {
+		name = member.Name
+	}
+	n[memberID] = name
+	return name
+}
+
+func gameConflict(g, other *Game) Conflict {
+	return Conflict{
+		Kind:          conflictGame,
+		GameID:        g.ID,
+		OtherGameID:   other.ID,
+		OtherOpponent: other.Opponent,
+		OtherStartsAt: other.StartsAt,
+		Message:       fmt.Sprintf("Overlaps the game vs %s on %s at %s", other.Opponent, other.Date, other.Time),
+	}
+}
+
+func playerConflict(g, other *Game, memberID string, names conflictNames) Conflict {
+	name := names.name(memberID)
+	return Conflict{
+		Kind:          conflictPlayer,
+		GameID:        g.ID,
+		OtherGameID:   other.ID,
+		OtherOpponent: other.Opponent,
+		OtherStartsAt: other.StartsAt,
+		MemberID:      memberID,
+		Member:        name,
+		Message:       fmt.Sprintf("%s is rostered for the game vs %s on %s at %s", name, other.Opponent, other.Date, other.Time),
+	}
+}
+
+// gameConflicts reports how g clashes with others: with checkGame the
+// games it overlaps, and the players in roster already rostered on one.
+func gameConflicts(durations map[string]int, g *Game, roster []string, checkGame bool, others []Game, names conflictNames) []Conflict {
+	var conflicts []Conflict
+	for i := range others {
+		other := &others[i]
+		if !gamesOverlap(durations, g, other) {
+			continue
+		}
+		if checkGame {
+			conflicts = append(conflicts, gameConflict(g, other))
+		}
+		for _, id := range roster {
+			if indexOf(other.Roster, id) >= 0 {
+				conflicts = append(conflicts, playerConflict(g, other, id, names))
+			}
+		}
+	}
+	return conflicts
+}
+
+// savedGamesAround returns the team's saved games that could overlap any
+// of games, leaving out the saved copies of games themselves.
+func savedGamesAround(teamID string, durations map[string]int, games []Game) ([]Game, error) {
+	filter := GameFilter{TeamID: teamID}
+	skip := make(map[string]bool)
+	for i := range games {
+		g := &games[i]
+		if from := g.StartsAt.Add(-longestGameDuration(durations)); filter.From.IsZero() || from.Before(filter.From) {
+			filter.From = from
+		}
+		if until := g.StartsAt.Add(gameDuration(durations, g.GameMode)); until.After(filter.Until) {
+			filter.Until = until
+		}
+		if g.ID != "" {
+			skip[g.ID] = true
+		}
+	}
+	saved, _, err := store.FindGames(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	others := saved[:0]
+	for _, other := range saved {
+		if !skip[other.ID] {
+			others = append(others, other)
+		}
+	}
+	return others, nil
+}
+
+// findGameConflicts checks g, as it is about to be saved, against the
+// team's other games. With checkGame it reports games g overlaps; it
+// reports players in roster already rostered on an overlapping game.
+func findGameConflicts(g *Game, roster []string, checkGame bool) ([]Conflict, error) {
+	durations, err := loadGameDurations(g.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	others, err := savedGamesAround(g.TeamID, durations, []Game{*g})
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := gameConflicts(durations, g, roster, checkGame, others, make(conflictNames))
+	if conflicts == nil {
+		conflicts = []Conflict{}
+	}
+	return conflicts, nil
+}
+
+// findGamesConflicts is findGameConflicts for games of one team saved
+// together, like a new series, a fixture import or a series moved at once.
+// Each is checked for overlaps and for the players on its own roster,
+// against the team's other games and the games before it in the list.
+func findGamesConflicts(games []Game) ([]Conflict, error) {
+	conflicts := []Conflict{}
+	if len(games) == 0 {
+		return conflicts, nil
+	}
+	teamID := games[0].TeamID
+	durations, err := loadGameDurations(teamID)
+	if err != nil {
+		return nil, err
+	}
+	others, err := savedGamesAround(teamID, durations, games)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unsaved games have no date and time of their own for the messages yet
+	batch := append([]Game(nil), games...)
+	for i := range batch {
+		localizeGame(&batch[i])
+	}
+	names := make(conflictNames)
+	for i := range batch {
+		g := &batch[i]
+		found := gameConflicts(durations, g, g.Roster, true, others, names)
+		found = append(found, gameConflicts(durations, g, g.Roster, true, batch[:i], names)...)
+		for _, c := range found {
+			c.index = i
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts, nil
+}
+
+// setConflictGames fills in the IDs of the games findGamesConflicts was
+// given, once they have been saved.
+func setConflictGames(conflicts []Conflict, games []Game) {
+	for i := range conflicts {
+		conflicts[i].GameID = games[conflicts[i].index].ID
+	}
+}
+
+// forceRequested reports whether a write should go ahead despite conflicts.
+func forceRequested(r *http.Request) bool {
+	return r.URL.Query().Get("force") == "true"
+}
+
+// writeSchedulingConflict answers a write blocked by conflicts.
+func writeSchedulingConflict(w http.ResponseWriter, conflicts []Conflict) {
+	messages := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		messages[i] = c.Message
+	}
+	writeJSON(w, http.StatusConflict, map[string]interface{}{
+		"error":     "Scheduling conflict: " + strings.Join(messages, "; "),
+		"conflicts": conflicts,
+	})
+}
+
+// addedMembers returns the members of after not in before.
+func addedMembers(before, after []string) []string {
+	var added []string
+	for _, id := range after {
+		if indexOf(before, id) < 0 {
+			added = append(added, id)
+		}
+	}
+	return added
+}
+
+// handleGetConflicts lists the team's current conflicts: games that
+// overlap and players rostered on both. It looks from ?from=, or by default
+// from the games that may still be running now, up to ?to=, across every
+// season. Each overlap is listed once, on the earlier game.
+func handleGetConflicts(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	durations, err := loadGameDurations(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	filter := GameFilter{TeamID: team.ID, From: time.Now().UTC().Add(-longestGameDuration(durations))}
+	query := r.URL.Query()
+	if v := query.Get("from"); v != "" {
+		t, err := parseGameListTime(v, false)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("bad from time %q", v))
+			return
+		}
+		filter.From = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseGameListTime(v, true)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("bad to time %q", v))
+			return
+		}
+		filter.Until = t
+	}
+
+	games, _, err := store.FindGames(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Games come ordered by start, so each only has to be checked against
+	// the ones after it that start before it ends
+	conflicts := []Conflict{}
+	names := make(conflictNames)
+	for i := range games {
+		g := &games[i]
+		end := g.StartsAt.Add(gameDuration(durations, g.GameMode))
+		for j := i + 1; j < len(games) && games[j].StartsAt.Before(end); j++ {
+			other := &games[j]
+			conflicts = append(conflicts, gameConflict(g, other))
+			for _, id := range g.Roster {
+				if indexOf(other.Roster, id) >= 0 {
+					conflicts = append(conflicts, playerConflict(g, other, id, names))
+				}
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, conflicts)
+}
+
+// handleGetGameDurations lists how long the team's games run, in minutes.
+func handleGetGameDurations(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	modes, err := loadGameDurations(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, GameDurations{Default: int(defaultGameDuration / time.Minute), Modes: modes})
+}
+
+// handleSetGameDuration sets how many minutes games of one mode run; 0 goes
+// back to the default.
+func handleSetGameDuration(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	mode := strings.TrimSpace(mux.Vars(r)["mode"])
+	if mode == "" {
+		writeError(w, http.StatusBadRequest, "Game mode is required")
+		return
+	}
+
+	var body struct {
+		Minutes int `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if body.Minutes < 0 || body.Minutes > maxGameDurationMinutes {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Minutes must be between 0 and %d", maxGameDurationMinutes))
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	modes, err := loadGameDurations(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	before := modes[mode]
+	if body.Minutes == 0 {
+		delete(modes, mode)
+	} else {
+		modes[mode] = body.Minutes
+	}
+
+	key := teamSettingKey(team.ID, gameDurationsSetting)
+	value, err := json.Marshal(modes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := store.SetSetting(key, string(value)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditSetting, key+":"+mode, before, body.Minutes)
+
+	writeJSON(w, http.StatusOK, GameDurations{Default: int(defaultGameDuration / time.Minute), Modes: modes})
+}
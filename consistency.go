@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// ==================== CONSISTENCY ====================
+
+// Reasons a participant is dangling
+const (
+	// danglingMissing means the member doesn't exist at all, so the game
+	// can only show their raw ID.
+	danglingMissing = "missing"
+	// danglingDeleted means the member is in the trash but still on a game
+	// that hasn't started. Past games keep deleted members on purpose.
+	danglingDeleted = "deleted"
+)
+
+// DanglingParticipant is a game participant whose member is gone.
+type DanglingParticipant struct {
+	GameID   string    `json:"gameId"`
+	StartsAt time.Time `json:"startsAt"`
+	MemberID string    `json:"memberId"`
+	Role     string    `json:"role"`
+	Status   string    `json:"status"`
+	Reason   string    `json:"reason"`
+}
+
+// danglingGameIDs lists each game in dangling once, in order.
+func danglingGameIDs(dangling []DanglingParticipant) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, d := range dangling {
+		if !seen[d.GameID] {
+			seen[d.GameID] = true
+			ids = append(ids, d.GameID)
+		}
+	}
+	return ids
+}
+
+func handleGetConsistency(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	dangling, err := store.ListDanglingParticipants(team.ID, time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"dangling": dangling,
+		"games":    len(danglingGameIDs(dangling)),
+	})
+}
+
+// handleFixConsistency removes the team's dangling participants and records the
+// change to each affected game in the audit log.
+func handleFixConsistency(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	now := time.Now()
+
+	// Snapshot the games first so the audit log can show what was removed
+	before := make(map[string]*Game)
+	if dangling, err := store.ListDanglingParticipants(team.ID, now); err == nil {
+		for _, id := range danglingGameIDs(dangling) {
+			before[id], _ = store.GetGame(id)
+		}
+	}
+
+	removed, err := store.RemoveDanglingParticipants(team.ID, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, id := range danglingGameIDs(removed) {
+		after, _ := store.GetGame(id)
+		recordAudit(team.ID, session.DiscordID, "remove_dangling", auditGame, id, before[id], after)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"removed": removed,
+		"games":   len(danglingGameIDs(removed)),
+	})
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== EXPORTS ====================
+
+// Managers plan rosters in spreadsheets, so the schedule and who can make
+// each game can be downloaded as CSV as well as JSON (the default, or
+// ?format=json). Both take the game listing's filters, such as from, to and
+// league.
+
+// Export formats
+const (
+	exportJSON = "json"
+	exportCSV  = "csv"
+)
+
+// availabilityNoResponse is a member's entry in the availability matrix
+// for a game they haven't answered and aren't on.
+const availabilityNoResponse = "no response"
+
+// ExportGame is a game as exported, with its players by name.
+type ExportGame struct {
+	ID          string      `json:"id"`
+	StartsAt    time.Time   `json:"startsAt"`
+	Date        string      `json:"date"`
+	Time        string      `json:"time"`
+	TimeZone    string      `json:"timeZone"`
+	Opponent    string      `json:"opponent"`
+	League      string      `json:"league,omitempty"`
+	Division    string      `json:"division,omitempty"`
+	GameMode    string      `json:"gameMode,omitempty"`
+	TeamSize    int         `json:"teamSize"`
+	Notes       string      `json:"notes,omitempty"`
+	Roster      []string    `json:"roster"`
+	Subs        []string    `json:"subs"`
+	Available   []string    `json:"available"`
+	Unavailable []string    `json:"unavailable"`
+	Withdrawals []string    `json:"withdrawals"`
+	Result      *GameResult `json:"result,omitempty"`
+}
+
+// AvailabilityRow is one member's line of the availability matrix: their
+// entry for each of the matrix's games, in order. DiscordUsername is only
+// shown to managers.
+type AvailabilityRow struct {
+	MemberID        string   `json:"memberId"`
+	Name            string   `json:"name"`
+	DiscordUsername string   `json:"discordUsername,omitempty"`
+	Games           []string `json:"games"`
+}
+
+// AvailabilityMatrix is the team's players by games.
+type AvailabilityMatrix struct {
+	Games   []ExportGame      `json:"games"`
+	Players []AvailabilityRow `json:"players"`
+}
+
+// exportMembers looks up the names of the team's members, and of trashed
+// members the games still name, in the team's order.
+type exportMembers struct {
+	order []Member
+	byID  map[string]*Member
+}
+
+func loadExportMembers(teamID string, games []Game) (*exportMembers, error) {
+	members, err := store.ListMembers(teamID)
+	if err != nil {
+		return nil, err
+	}
+	m := &exportMembers{order: members, byID: make(map[string]*Member)}
+	for i := range m.order {
+		m.byID[m.order[i].ID] = &m.order[i]
+	}
+
+	for _, g := range games {
+		for _, list := range [][]string{g.Roster, g.Subs, g.Available, g.Unavailable, g.Withdrawals} {
+			for _, id := range list {
+				if _, ok := m.byID[id]; ok {
+					continue
+				}
+				member, err := store.GetMember(id)
+				if err != nil {
+					return nil, err
+				}
+				if member == nil {
+					member = &Member{ID: id, Name: id}
+				}
+				m.order = append(m.order, *member)
+				m.byID[id] = member
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *exportMembers) names(ids []string) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = id
+		if member := m.byID[id]; member != nil {
+			names[i] = member.Name
+		}
+	}
+	return names
+}
+
+func (m *exportMembers) exportGame(g *Game) ExportGame {
+	return ExportGame{
+		ID:          g.ID,
+		StartsAt:    g.StartsAt,
+		Date:        g.Date,
+		Time:        g.Time,
+		TimeZone:    g.TimeZone,
+		Opponent:    g.Opponent,
+		League:      g.League,
+		Division:    g.Division,
+		GameMode:    g.GameMode,
+		TeamSize:    g.TeamSize,
+		Notes:       g.Notes,
+		Roster:      m.names(g.Roster),
+		Subs:        m.names(g.Subs),
+		Available:   m.names(g.Available),
+		Unavailable: m.names(g.Unavailable),
+		Withdrawals: m.names(g.Withdrawals),
+		Result:      g.Result,
+	}
+}
+
+// memberAvailability is a member's entry for a game. Their place on the
+// game outranks what they answered.
+func memberAvailability(g *Game, memberID string) string {
+	switch {
+	case indexOf(g.Withdrawals, memberID) >= 0:
+		return participationWithdrawn
+	case indexOf(g.Roster, memberID) >= 0:
+		return participationRostered
+	case indexOf(g.Subs, memberID) >= 0:
+		return participationSub
+	case indexOf(g.Available, memberID) >= 0:
+		return participationAvailable
+	case indexOf(g.Unavailable, memberID) >= 0:
+		return participationUnavailable
+	}
+	return availabilityNoResponse
+}
+
+// exportRequest reads what every export takes: the format and the games
+// matching the listing filters. It writes the error response itself and
+// returns ok false on failure.
+func exportRequest(w http.ResponseWriter, r *http.Request) (team *Team, format string, games []Game, ok bool) {
+	format = strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		format = exportJSON
+	case exportJSON, exportCSV:
+	default:
+		writeError(w, http.StatusBadRequest, "format must be csv or json")
+		return nil, "", nil, false
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return nil, "", nil, false
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return nil, "", nil, false
+	}
+
+	filter, err := parseGameFilter(r, team.ID, seasonID, 0)
+	if err != nil {
+		writeGameFilterError(w, err)
+		return nil, "", nil, false
+	}
+	games, _, err = store.FindGames(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, "", nil, false
+	}
+	return team, format, games, true
+}
+
+// writeCSV sends records as a CSV download named filename.
+func writeCSV(w http.ResponseWriter, filename string, records [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	writer := csv.NewWriter(w)
+	writer.WriteAll(records)
+}
+
+// exportGameLabel is a game's column heading in the availability matrix.
+func exportGameLabel(g *ExportGame) string {
+	return g.Date + " " + g.Time + " vs " + g.Opponent
+}
+
+// handleExportGames downloads the schedule, one game per row with its
+// players by name.
+func handleExportGames(w http.ResponseWriter, r *http.Request) {
+	team, format, games, ok := exportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := loadExportMembers(team.ID, games)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	exported := make([]ExportGame, len(games))
+	for i := range games {
+		exported[i] = members.exportGame(&games[i])
+	}
+
+	if format == exportJSON {
+		writeJSON(w, http.StatusOK, exported)
+		return
+	}
+
+	records := [][]string{{"Date", "Time", "Time Zone", "Opponent", "League", "Division", "Mode", "Team Size",
+		"Roster", "Subs", "Available", "Unavailable", "Withdrawn", "Result", "Notes"}}
+	for _, g := range exported {
+		result := ""
+		if g.Result != nil {
+			result = g.Result.Outcome + " " + strconv.Itoa(g.Result.OurScore) + "-" + strconv.Itoa(g.Result.TheirScore)
+		}
+		records = append(records, []string{g.Date, g.Time, g.TimeZone, g.Opponent, g.League, g.Division, g.GameMode,
+			strconv.Itoa(g.TeamSize), strings.Join(g.Roster, "; "), strings.Join(g.Subs, "; "),
+			strings.Join(g.Available, "; "), strings.Join(g.Unavailable, "; "), strings.Join(g.Withdrawals, "; "),
+			result, g.Notes})
+	}
+	writeCSV(w, team.Slug+"-games.csv", records)
+}
+
+// handleExportAvailability downloads the players-by-games matrix: each
+// member's entry for each game is available, unavailable, rostered, sub,
+// withdrawn or no response. Managers also get each member's linked
+// Discord username.
+func handleExportAvailability(w http.ResponseWriter, r *http.Request) {
+	team, format, games, ok := exportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := loadExportMembers(team.ID, games)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	session := getSessionFromRequest(r)
+	usernames := make(map[string]string)
+	if session != nil && session.IsManager {
+		users, err := store.ListLinkedUsers(team.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, u := range users {
+			usernames[u.PlayerID] = u.Username
+		}
+	}
+
+	matrix := AvailabilityMatrix{Games: make([]ExportGame, len(games)), Players: []AvailabilityRow{}}
+	for i := range games {
+		matrix.Games[i] = members.exportGame(&games[i])
+	}
+	for _, m := range members.order {
+		row := AvailabilityRow{MemberID: m.ID, Name: m.Name, DiscordUsername: usernames[m.ID], Games: make([]string, len(games))}
+		for i := range games {
+			row.Games[i] = memberAvailability(&games[i], m.ID)
+		}
+		matrix.Players = append(matrix.Players, row)
+	}
+
+	if format == exportJSON {
+		writeJSON(w, http.StatusOK, matrix)
+		return
+	}
+
+	header := []string{"Player"}
+	if session != nil && session.IsManager {
+		header = append(header, "Discord")
+	}
+	for i := range matrix.Games {
+		header = append(header, exportGameLabel(&matrix.Games[i]))
+	}
+	records := [][]string{header}
+	for _, row := range matrix.Players {
+		record := []string{row.Name}
+		if session != nil && session.IsManager {
+			record = append(record, row.DiscordUsername)
+		}
+		records = append(records, append(record, row.Games...))
+	}
+	writeCSV(w, team.Slug+"-availability.csv", records)
+}
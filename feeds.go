@@ -0,0 +1,442 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== CALENDAR FEEDS ====================
+
+// CalendarFeed is an iCalendar (RFC 5545) feed of a team's games that
+// Google, Apple or Outlook calendars can subscribe to. The token in its URL
+// is its only secret: anyone holding it can read the feed, and deleting the
+// feed revokes it.
+type CalendarFeed struct {
+	Token  string `json:"token,omitempty"`
+	TeamID string `json:"teamId"`
+	Kind   string `json:"kind"`
+	// LeagueID is set on league feeds, MemberID on player feeds
+	LeagueID  string    `json:"leagueId,omitempty"`
+	MemberID  string    `json:"memberId,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	URL       string    `json:"url,omitempty"`
+}
+
+// Feed kinds. Player feeds only carry the games the player is rostered or
+// subbing for.
+const (
+	feedTeam   = "team"
+	feedLeague = "league"
+	feedPlayer = "player"
+)
+
+const (
+	// feedHistory is how far back a feed goes; calendars keep the events
+	// they have already seen
+	feedHistory = 90 * 24 * time.Hour
+)
+
+func newFeedToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// feedAuditID names a feed in the audit log without giving away its token:
+// the start of the token's SHA-256, the same for the feed's create and
+// delete events.
+func feedAuditID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:6])
+}
+
+// feedAuditSnapshot is feed as the audit log records it, without the token
+// or the URL holding it.
+func feedAuditSnapshot(feed *CalendarFeed) *CalendarFeed {
+	snapshot := *feed
+	snapshot.Token = ""
+	snapshot.URL = ""
+	return &snapshot
+}
+
+func feedURL(token string) string {
+	return siteURL() + "/calendar/" + token + ".ics"
+}
+
+// canManageFeed reports whether the session may see and revoke f: managers
+// can for every feed of their team, players for their own player feeds.
+func canManageFeed(session *Session, f *CalendarFeed) bool {
+	if session.IsManager {
+		return true
+	}
+	return f.Kind == feedPlayer && session.PlayerID != "" && f.MemberID == session.PlayerID
+}
+
+// feedGames returns the games a feed shows, oldest first.
+func feedGames(f *CalendarFeed, now time.Time) ([]Game, error) {
+	filter := GameFilter{TeamID: f.TeamID, From: now.Add(-feedHistory)}
+	switch f.Kind {
+	case feedLeague:
+		filter.LeagueID = f.LeagueID
+	case feedPlayer:
+		filter.MemberID = f.MemberID
+	}
+	games, _, err := store.FindGames(filter)
+	if err != nil || f.Kind != feedPlayer {
+		return games, err
+	}
+
+	var playing []Game
+	for _, g := range games {
+		if indexOf(g.Roster, f.MemberID) >= 0 || indexOf(g.Subs, f.MemberID) >= 0 {
+			playing = append(playing, g)
+		}
+	}
+	return playing, nil
+}
+
+// feedName is the calendar name subscribers see, e.g. "GO – Pro League".
+func feedName(team *Team, f *CalendarFeed) (string, error) {
+	switch f.Kind {
+	case feedLeague:
+		league, err := store.GetLeague(f.LeagueID)
+		if err != nil || league == nil {
+			return team.Name, err
+		}
+		return team.Name + " – " + league.Name, nil
+	case feedPlayer:
+		member, err := store.GetMember(f.MemberID)
+		if err != nil || member == nil {
+			return team.Name, err
+		}
+		return team.Name + " – " + member.Name, nil
+	}
+	return team.Name, nil
+}
+
+// icsWriter builds an iCalendar document: CRLF line endings, text values
+// escaped and lines folded at 75 octets as RFC 5545 asks.
+type icsWriter struct {
+	b strings.Builder
+}
+
+func (w *icsWriter) prop(name, value string) {
+	line := name + ":" + value
+	for first := true; ; first = false {
+		limit := 75
+		if !first {
+			limit = 74
+			w.b.WriteString(" ")
+		}
+		if len(line) <= limit {
+			w.b.WriteString(line + "\r\n")
+			return
+		}
+		// Never split a UTF-8 sequence
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.b.WriteString(line[:cut] + "\r\n")
+		line = line[cut:]
+	}
+}
+
+func (w *icsWriter) text(name, value string) {
+	w.prop(name, icsEscape(value))
+}
+
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// renderCalendar writes games as an iCalendar feed. Events are keyed by game
+// ID and their SEQUENCE is the game's version, so calendars pick up every
+// change made to a game, and last as long as games of their mode run.
+// memberID, on player feeds, marks games they sub in.
+func renderCalendar(name string, team *Team, games []Game, names map[string]string, durations map[string]int, memberID string, now time.Time) string {
+	host := "go-calendar"
+	if u, err := url.Parse(siteURL()); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	memberNames := func(ids []string) string {
+		list := make([]string, len(ids))
+		for i, id := range ids {
+			list[i] = firstNonEmpty(names[id], id)
+		}
+		return strings.Join(list, ", ")
+	}
+
+	var w icsWriter
+	w.prop("BEGIN", "VCALENDAR")
+	w.prop("VERSION", "2.0")
+	w.prop("PRODID", "-//"+host+"//Game Calendar//EN")
+	w.prop("CALSCALE", "GREGORIAN")
+	w.prop("METHOD", "PUBLISH")
+	w.text("X-WR-CALNAME", name)
+	// Ask subscribers to check back hourly
+	w.prop("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	w.prop("X-PUBLISHED-TTL", "PT1H")
+
+	for i := range games {
+		g := &games[i]
+		link := teamGameLink(team, g.ID)
+
+		summary := fmt.Sprintf("%s vs %s (%s)", team.Name, g.Opponent, g.GameMode)
+		if memberID != "" && indexOf(g.Subs, memberID) >= 0 {
+			summary = "Sub: " + summary
+		}
+
+		details := []string{fmt.Sprintf("Game mode: %s, %d players", g.GameMode, g.TeamSize)}
+		if league := strings.TrimSpace(g.League + " " + g.Division); league != "" {
+			details = append(details, "League: "+league)
+		}
+		if g.Notes != "" {
+			details = append(details, "Notes: "+g.Notes)
+		}
+		if len(g.Roster) > 0 {
+			details = append(details, "Roster: "+memberNames(g.Roster))
+		}
+		if len(g.Subs) > 0 {
+			details = append(details, "Subs: "+memberNames(g.Subs))
+		}
+		details = append(details, "", link)
+
+		w.prop("BEGIN", "VEVENT")
+		w.prop("UID", g.ID+"@"+host)
+		w.prop("DTSTAMP", icsTime(now))
+		w.prop("DTSTART", icsTime(g.StartsAt))
+		w.prop("DTEND", icsTime(g.StartsAt.Add(gameDuration(durations, g.GameMode))))
+		w.prop("SEQUENCE", fmt.Sprint(g.Version))
+		w.text("SUMMARY", summary)
+		w.text("DESCRIPTION", strings.Join(details, "\n"))
+		w.prop("URL", link)
+		w.prop("STATUS", "CONFIRMED")
+		w.prop("END", "VEVENT")
+	}
+
+	w.prop("END", "VCALENDAR")
+	return w.b.String()
+}
+
+// handleGetCalendarFeed serves a feed at /calendar/{token}.ics. It needs no
+// session: calendar apps can't log in, so the token is the credential.
+func handleGetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(mux.Vars(r)["token"], ".ics")
+	feed, err := store.GetCalendarFeed(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if feed == nil {
+		http.NotFound(w, r)
+		return
+	}
+	team, err := store.GetTeam(feed.TeamID)
+	if err != nil || team == nil {
+		http.Error(w, "Team not found", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	games, err := feedGames(feed, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name, err := feedName(team, feed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	members, err := store.ListMembers(team.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	names := make(map[string]string, len(members))
+	for _, m := range members {
+		names[m.ID] = m.Name
+	}
+	durations, err := loadGameDurations(team.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	memberID := ""
+	if feed.Kind == feedPlayer {
+		memberID = feed.MemberID
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="`+team.Slug+`.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(renderCalendar(name, team, games, names, durations, memberID, now)))
+}
+
+// handleGetFeeds lists the team's feeds the user may manage, with their URLs.
+func handleGetFeeds(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	feeds, err := store.ListCalendarFeeds(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	visible := []CalendarFeed{}
+	for _, f := range feeds {
+		if canManageFeed(session, &f) {
+			f.URL = feedURL(f.Token)
+			visible = append(visible, f)
+		}
+	}
+	writeJSON(w, http.StatusOK, visible)
+}
+
+// handleCreateFeed makes a new feed. Team and league feeds are for managers;
+// players can make feeds of their own games, managers of anyone's.
+func handleCreateFeed(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	var body struct {
+		Kind     string `json:"kind"`
+		LeagueID string `json:"leagueId"`
+		MemberID string `json:"memberId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	feed := CalendarFeed{TeamID: team.ID, Kind: body.Kind, CreatedBy: session.DiscordID}
+	switch body.Kind {
+	case feedTeam, feedLeague:
+		if !session.IsManager {
+			writeError(w, http.StatusForbidden, "Manager access required")
+			return
+		}
+		if body.Kind == feedLeague {
+			_, league, err := getLeagueInTeam(r, body.LeagueID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if league == nil {
+				writeError(w, http.StatusBadRequest, "Unknown league")
+				return
+			}
+			feed.LeagueID = league.ID
+		}
+	case feedPlayer:
+		memberID := firstNonEmpty(body.MemberID, session.PlayerID)
+		if memberID == "" {
+			writeError(w, http.StatusBadRequest, "Link your player first")
+			return
+		}
+		member, err := getTeamMember(team.ID, memberID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if member == nil {
+			writeError(w, http.StatusBadRequest, "Unknown member")
+			return
+		}
+		if !session.IsManager && member.ID != session.PlayerID {
+			writeError(w, http.StatusForbidden, "You can only subscribe to your own games")
+			return
+		}
+		feed.MemberID = member.ID
+	default:
+		writeError(w, http.StatusBadRequest, "kind must be team, league or player")
+		return
+	}
+
+	if feed.Token, err = newFeedToken(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	created, err := store.CreateCalendarFeed(feed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "create", auditFeed, feedAuditID(created.Token), nil, feedAuditSnapshot(created))
+
+	created.URL = feedURL(created.Token)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// handleDeleteFeed revokes a feed; its URL stops working at once.
+func handleDeleteFeed(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	token := mux.Vars(r)["token"]
+	feed, err := store.GetCalendarFeed(token)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if feed == nil || feed.TeamID != team.ID || !canManageFeed(session, feed) {
+		writeError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+
+	if err := store.DeleteCalendarFeed(token); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "delete", auditFeed, feedAuditID(token), feedAuditSnapshot(feed), nil)
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
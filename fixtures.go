@@ -0,0 +1,733 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ==================== FIXTURE IMPORT ====================
+
+// Leagues publish their schedules as iCalendar files or spreadsheets. A
+// manager uploads one to preview the games it holds, then uploads it again
+// with ?commit=true to create them all at once.
+
+// maxFixtureBytes caps the size of an uploaded fixture list, and
+// maxFixtureGames the games in it.
+const (
+	maxFixtureBytes = 1 << 20
+	maxFixtureGames = 200
+)
+
+// Fixture list formats
+const (
+	fixturesICS = "ics"
+	fixturesCSV = "csv"
+)
+
+var errInvalidFixtures = errors.New("invalid fixture list")
+
+func invalidFixtures(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errInvalidFixtures, fmt.Sprintf(format, args...))
+}
+
+// fixture is one game read from a fixture list, or what is wrong with it.
+type fixture struct {
+	Line  int
+	Input gameInput
+	Err   string
+}
+
+// FixtureRow is one game of an import preview. Game is the game that would
+// be created, unless the row has an error. A row that matches a game the
+// team already has, or an earlier row, is flagged and skipped on commit.
+type FixtureRow struct {
+	Line          int    `json:"line"`
+	Game          *Game  `json:"game,omitempty"`
+	NewOpponent   bool   `json:"newOpponent,omitempty"`
+	DuplicateOf   string `json:"duplicateOf,omitempty"`
+	DuplicateLine int    `json:"duplicateLine,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+func (row *FixtureRow) duplicate() bool {
+	return row.DuplicateOf != "" || row.DuplicateLine != 0
+}
+
+// fixtureDefaults reads the settings for games the file leaves them out of
+// from the query: timeZone, league, division, gameMode and teamSize.
+func fixtureDefaults(query url.Values) (gameInput, error) {
+	defaults := gameInput{
+		TimeZone: query.Get("timeZone"),
+		League:   query.Get("league"),
+		Division: query.Get("division"),
+		GameMode: query.Get("gameMode"),
+	}
+	if size := query.Get("teamSize"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return defaults, invalidFixtures("teamSize must be a positive number")
+		}
+		defaults.TeamSize = n
+	}
+	return defaults, nil
+}
+
+// fixtureFormat returns the format a request names with ?format= or its
+// Content-Type, or "" to tell from the file itself.
+func fixtureFormat(r *http.Request) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		if format != fixturesICS && format != fixturesCSV {
+			return "", invalidFixtures("format must be ics or csv")
+		}
+		return format, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/calendar":
+		return fixturesICS, nil
+	case "text/csv":
+		return fixturesCSV, nil
+	}
+	return "", nil
+}
+
+// parseFixtures reads a fixture list. Problems with the whole file fail
+// with errInvalidFixtures; problems with one game are left on its fixture.
+func parseFixtures(format string, body io.Reader, team *Team, defaults gameInput) (string, []fixture, error) {
+	reader := bufio.NewReader(body)
+	if format == "" {
+		head, _ := reader.Peek(64)
+		head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\ufeff")), " \t\r\n")
+		format = fixturesCSV
+		if bytes.HasPrefix(bytes.ToUpper(head), []byte("BEGIN:VCALENDAR")) {
+			format = fixturesICS
+		}
+	}
+
+	var fixtures []fixture
+	var err error
+	if format == fixturesICS {
+		fixtures, err = parseFixtureICS(reader, team, defaults)
+	} else {
+		fixtures, err = parseFixtureCSV(reader, defaults)
+	}
+	if err != nil {
+		return format, nil, err
+	}
+	if len(fixtures) == 0 {
+		return format, nil, invalidFixtures("the file has no games")
+	}
+	if len(fixtures) > maxFixtureGames {
+		return format, nil, invalidFixtures("the file has %d games; at most %d can be imported at once", len(fixtures), maxFixtureGames)
+	}
+	return format, fixtures, nil
+}
+
+// fixtureColumns maps CSV headers, lowercased without spaces or
+// punctuation, to the gameInput field they fill.
+var fixtureColumns = map[string]string{
+	"date":     "date",
+	"time":     "time",
+	"timezone": "timeZone",
+	"tz":       "timeZone",
+	"opponent": "opponent",
+	"league":   "league",
+	"division": "division",
+	"mode":     "gameMode",
+	"gamemode": "gameMode",
+	"teamsize": "teamSize",
+	"size":     "teamSize",
+	"notes":    "notes",
+}
+
+// parseFixtureCSV reads a spreadsheet export: a header row with date, time
+// and opponent columns, and optionally timezone, league, division, mode,
+// team size and notes, then one row per game. Dates are YYYY-MM-DD and
+// times are wall-clock in the row's time zone.
+func parseFixtureCSV(body io.Reader, defaults gameInput) ([]fixture, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, invalidFixtures("the file is empty")
+	}
+	if err != nil {
+		return nil, invalidFixtures("%v", err)
+	}
+
+	fields := make([]string, len(header))
+	found := make(map[string]bool)
+	for i, name := range header {
+		key := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, name)
+		if key == "" {
+			continue
+		}
+		field, ok := fixtureColumns[key]
+		if !ok {
+			return nil, invalidFixtures("unknown column %q", strings.TrimSpace(name))
+		}
+		fields[i] = field
+		found[field] = true
+	}
+	for _, field := range []string{"date", "time", "opponent"} {
+		if !found[field] {
+			return nil, invalidFixtures("no %s column", field)
+		}
+	}
+
+	var fixtures []fixture
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalidFixtures("%v", err)
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		f := fixture{Line: line, Input: defaults}
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+			if i >= len(fields) || fields[i] == "" || cell == "" {
+				continue
+			}
+			if err := setFixtureField(&f.Input, fields[i], cell); err != nil {
+				f.Err = err.Error()
+			}
+		}
+		if f.Err == "" && f.Input.Opponent == "" {
+			f.Err = "no opponent"
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+func setFixtureField(in *gameInput, field, value string) error {
+	switch field {
+	case "date":
+		in.Date = value
+	case "time":
+		in.Time = value
+	case "timeZone":
+		in.TimeZone = value
+	case "opponent":
+		in.Opponent = value
+	case "league":
+		in.League = value
+	case "division":
+		in.Division = value
+	case "gameMode":
+		in.GameMode = value
+	case "teamSize":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid team size %q", value)
+		}
+		in.TeamSize = n
+	case "notes":
+		in.Notes = value
+	}
+	return nil
+}
+
+// icsProperty is one unfolded iCalendar content line,
+// NAME;PARAM=value:value, and the line it starts on.
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+	Line   int
+}
+
+// readICS splits an iCalendar file into its properties, unfolding lines
+// that continue onto the next.
+func readICS(body io.Reader) ([]icsProperty, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFixtureBytes)
+
+	var props []icsProperty
+	var current strings.Builder
+	start, line := 0, 0
+	flush := func() error {
+		text := current.String()
+		current.Reset()
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		prop, ok := parseICSProperty(text)
+		if !ok {
+			return invalidFixtures("line %d is not an iCalendar property", start)
+		}
+		prop.Line = start
+		props = append(props, prop)
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && current.Len() > 0 {
+			current.WriteString(text[1:])
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		current.WriteString(text)
+		start = line
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, invalidFixtures("%v", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// parseICSProperty splits a content line at the first colon outside a
+// quoted parameter value.
+func parseICSProperty(text string) (icsProperty, bool) {
+	colon, quoted := -1, false
+	for i, r := range text {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return icsProperty{}, false
+	}
+
+	parts := strings.Split(text[:colon], ";")
+	prop := icsProperty{
+		Name:   strings.ToUpper(strings.TrimSpace(parts[0])),
+		Params: make(map[string]string),
+		Value:  text[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			prop.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return prop, true
+}
+
+// icsUnescape undoes icsEscape on a TEXT value.
+func icsUnescape(value string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped && (r == 'n' || r == 'N'):
+			b.WriteByte('\n')
+		case escaped:
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			continue
+		default:
+			b.WriteRune(r)
+		}
+		escaped = false
+	}
+	return b.String()
+}
+
+// parseFixtureICS reads the events of an iCalendar file as games: the
+// start from DTSTART, the opponent from SUMMARY and the notes from
+// DESCRIPTION. Cancelled events are left out.
+func parseFixtureICS(body io.Reader, team *Team, defaults gameInput) ([]fixture, error) {
+	props, err := readICS(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(props) == 0 || props[0].Name != "BEGIN" || !strings.EqualFold(props[0].Value, "VCALENDAR") {
+		return nil, invalidFixtures("not an iCalendar file")
+	}
+
+	// Events are read once the whole file is, as the calendar's own time
+	// zone may come after them.
+	var events []map[string]icsProperty
+	var event map[string]icsProperty
+	calendarZone := ""
+	depth := 0
+	for _, prop := range props {
+		switch {
+		case prop.Name == "BEGIN" && event == nil && strings.EqualFold(prop.Value, "VEVENT"):
+			event = map[string]icsProperty{"BEGIN": prop}
+		case prop.Name == "BEGIN" && event != nil:
+			depth++
+		case prop.Name == "END" && event != nil && depth > 0:
+			depth--
+		case prop.Name == "END" && event != nil:
+			events = append(events, event)
+			event = nil
+		case event != nil && depth == 0:
+			if _, ok := event[prop.Name]; !ok {
+				event[prop.Name] = prop
+			}
+		case prop.Name == "X-WR-TIMEZONE":
+			calendarZone = strings.TrimSpace(prop.Value)
+		}
+	}
+	if event != nil {
+		return nil, invalidFixtures("the event on line %d never ends", event["BEGIN"].Line)
+	}
+
+	zone := firstNonEmpty(defaults.TimeZone, calendarZone, defaultGameTimeZone)
+	var fixtures []fixture
+	for _, event := range events {
+		if strings.EqualFold(strings.TrimSpace(event["STATUS"].Value), "CANCELLED") {
+			continue
+		}
+		f := fixture{Line: event["BEGIN"].Line, Input: defaults}
+		f.Input.Notes = strings.TrimSpace(icsUnescape(event["DESCRIPTION"].Value))
+
+		start, ok := event["DTSTART"]
+		switch {
+		case !ok:
+			f.Err = "no start time"
+		case event["RRULE"].Value != "":
+			f.Err = "recurring events can't be imported; create a series instead"
+		default:
+			startsAt, timeZone, err := icsStart(start, zone)
+			if err != nil {
+				f.Err = err.Error()
+				break
+			}
+			f.Input.StartsAt = startsAt.Format(time.RFC3339)
+			f.Input.TimeZone = timeZone
+			f.Input.Opponent, err = fixtureOpponent(icsUnescape(event["SUMMARY"].Value), team.Name)
+			if err != nil {
+				f.Err = err.Error()
+			}
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+// icsStart reads a DTSTART: a UTC time, a local time in its TZID, or a
+// floating time in zone. It returns the start and the game's time zone.
+func icsStart(prop icsProperty, zone string) (time.Time, string, error) {
+	value := strings.TrimSpace(prop.Value)
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(value) == len("20060102") {
+		return time.Time{}, "", fmt.Errorf("all-day events have no start time")
+	}
+
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		zone = strings.TrimPrefix(tzid, "/")
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("unknown time zone %q", zone)
+	}
+
+	var t time.Time
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+	} else {
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid start time %q", value)
+	}
+	return t.UTC(), zone, nil
+}
+
+// Separators between the two teams of an event title, as in "Us vs Them"
+// or "Them @ Us"
+var (
+	leadingVersus  = regexp.MustCompile(`(?i)^\s*(?:vs\.?|versus|v\.?|@|at|-)\s+`)
+	trailingVersus = regexp.MustCompile(`(?i)\s+(?:vs\.?|versus|v\.?|@|at|-)\s*$`)
+	versus         = regexp.MustCompile(`(?i)\s(?:vs\.?|versus|v\.?|@|at)\s`)
+)
+
+// fixtureOpponent picks the opponent out of an event title: the side of a
+// "vs" that isn't the team, or the whole title if it names one team only.
+// The team's name only counts as a whole word right next to the "vs", so
+// "GOATS vs GO" is against the GOATS.
+func fixtureOpponent(summary, teamName string) (string, error) {
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("no opponent")
+	}
+
+	lower, name := strings.ToLower(summary), strings.ToLower(teamName)
+	for from := 0; name != "" && len(lower) == len(summary); {
+		i := strings.Index(lower[from:], name)
+		if i < 0 {
+			break
+		}
+		i += from
+		from = i + 1
+		before, after := summary[:i], summary[i+len(name):]
+		if !wordBoundary(before, after) {
+			continue
+		}
+		if m := leadingVersus.FindStringIndex(after); m != nil && strings.TrimSpace(after[m[1]:]) != "" {
+			return strings.TrimSpace(after[m[1]:]), nil
+		}
+		if m := trailingVersus.FindStringIndex(before); m != nil && strings.TrimSpace(before[:m[0]]) != "" {
+			return strings.TrimSpace(before[:m[0]]), nil
+		}
+	}
+
+	if m := leadingVersus.FindStringIndex(summary); m != nil {
+		return strings.TrimSpace(summary[m[1]:]), nil
+	}
+	if versus.MatchString(summary) {
+		return "", fmt.Errorf("can't tell which team in %q is the opponent", summary)
+	}
+	return summary, nil
+}
+
+// wordBoundary reports whether a word could end with before and another
+// start with after.
+func wordBoundary(before, after string) bool {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	last, _ := utf8.DecodeLastRuneInString(before)
+	first, _ := utf8.DecodeRuneInString(after)
+	return (before == "" || !isWord(last)) && (after == "" || !isWord(first))
+}
+
+// previewFixtures works out the game each fixture would create and flags
+// the ones the team already has. It writes nothing.
+func previewFixtures(teamID string, fixtures []fixture) ([]FixtureRow, error) {
+	rows := make([]FixtureRow, len(fixtures))
+	var first, last time.Time
+	for i, f := range fixtures {
+		rows[i].Line = f.Line
+		if f.Err != "" {
+			rows[i].Error = f.Err
+			continue
+		}
+
+		startsAt, timeZone, err := resolveGameStart(f.Input.StartsAt, f.Input.Date, f.Input.Time, f.Input.TimeZone)
+		if err != nil {
+			rows[i].Error = err.Error()
+			continue
+		}
+		game, err := draftGame(teamID, f.Input, startsAt, timeZone)
+		if errors.Is(err, errInvalidLeague) || errors.Is(err, errInvalidOpponent) {
+			rows[i].Error = err.Error()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		localizeGame(&game)
+		rows[i].Game = &game
+		rows[i].NewOpponent = game.OpponentID == ""
+
+		if first.IsZero() || startsAt.Before(first) {
+			first = startsAt
+		}
+		if startsAt.After(last) {
+			last = startsAt
+		}
+	}
+	if first.IsZero() {
+		return rows, nil
+	}
+
+	// A day either side covers every game on the same local date
+	existing, _, err := store.FindGames(GameFilter{TeamID: teamID, From: first.Add(-24 * time.Hour), Until: last.Add(24 * time.Hour)})
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		game := rows[i].Game
+		if game == nil {
+			continue
+		}
+		for j := range existing {
+			if sameFixture(game, &existing[j]) {
+				rows[i].DuplicateOf = existing[j].ID
+				break
+			}
+		}
+		for j := 0; j < i && !rows[i].duplicate(); j++ {
+			if rows[j].Game != nil && sameFixture(game, rows[j].Game) {
+				rows[i].DuplicateLine = rows[j].Line
+			}
+		}
+	}
+	return rows, nil
+}
+
+// sameFixture reports whether two games are against the same opponent on
+// the same day, in the first game's time zone.
+func sameFixture(a, b *Game) bool {
+	sameOpponent := a.OpponentID != "" && a.OpponentID == b.OpponentID ||
+		opponentKey(a.Opponent) == opponentKey(b.Opponent)
+	local := gameLocalTime(a)
+	return sameOpponent && local.Format("2006-01-02") == b.StartsAt.In(local.Location()).Format("2006-01-02")
+}
+
+// handleImportFixtures previews the games in an uploaded .ics or CSV file,
+// or with ?commit=true creates them in one go. A commit fails if any row
+// has an error, and skips duplicates unless ?duplicates=true. Games that
+// would conflict fail it too, unless ?force=true.
+func handleImportFixtures(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := fixtureFormat(r)
+	var fixtures []fixture
+	var rows []FixtureRow
+	if err == nil {
+		var defaults gameInput
+		if defaults, err = fixtureDefaults(query); err == nil {
+			format, fixtures, err = parseFixtures(format, http.MaxBytesReader(w, r.Body, maxFixtureBytes), team, defaults)
+		}
+	}
+	if err == nil {
+		rows, err = previewFixtures(team.ID, fixtures)
+	}
+	if errors.Is(err, errInvalidFixtures) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if query.Get("commit") != "true" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"format": format,
+			"rows":   rows,
+		})
+		return
+	}
+
+	for _, row := range rows {
+		if row.Error != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  fmt.Sprintf("Line %d: %s", row.Line, row.Error),
+				"format": format,
+				"rows":   rows,
+			})
+			return
+		}
+	}
+
+	importDuplicates := query.Get("duplicates") == "true"
+	games := []Game{}
+	for i, row := range rows {
+		if row.duplicate() && !importDuplicates {
+			continue
+		}
+		game, err := draftGame(team.ID, fixtures[i].Input, row.Game.StartsAt, row.Game.TimeZone)
+		if errors.Is(err, errInvalidLeague) || errors.Is(err, errInvalidOpponent) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Line %d: %s", row.Line, err.Error()))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		games = append(games, game)
+	}
+
+	conflicts, err := findGamesConflicts(games)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(conflicts) > 0 && !forceRequested(r) {
+		writeSchedulingConflict(w, conflicts)
+		return
+	}
+
+	status := http.StatusOK
+	if len(games) > 0 {
+		// New opponents are created with the games, one for each name however
+		// it is spelled, so a failed import leaves none behind
+		var opponents []Opponent
+		newOpponents := make(map[string]int)
+		for i := range games {
+			g := &games[i]
+			g.ID = generateGameID()
+			if g.OpponentID != "" {
+				continue
+			}
+			key := opponentKey(g.Opponent)
+			j, ok := newOpponents[key]
+			if !ok {
+				j = len(opponents)
+				newOpponents[key] = j
+				opponents = append(opponents, Opponent{ID: generateID("opponent"), Name: g.Opponent, Aliases: []string{}})
+			}
+			g.Opponent, g.OpponentID = opponents[j].Name, opponents[j].ID
+		}
+
+		created, createdOpponents, err := store.CreateGames(games, opponents)
+		if errors.Is(err, errDuplicateName) {
+			writeError(w, http.StatusConflict, "Someone added one of the file's opponents meanwhile; import it again")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i := range createdOpponents {
+			recordAudit(team.ID, session.DiscordID, "create", auditOpponent, createdOpponents[i].ID, nil, createdOpponents[i])
+		}
+		for i := range created {
+			recordAudit(team.ID, session.DiscordID, "create", auditGame, created[i].ID, nil, created[i])
+		}
+		setConflictGames(conflicts, created)
+		games, status = created, http.StatusCreated
+	}
+
+	response := map[string]interface{}{
+		"format": format,
+		"rows":   rows,
+		"games":  games,
+	}
+	if len(conflicts) > 0 {
+		response["conflicts"] = conflicts
+	}
+	writeJSON(w, status, response)
+}
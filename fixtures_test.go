@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFixtureOpponent(t *testing.T) {
+	tests := []struct {
+		summary, team string
+		want          string // or "" if the title should be refused
+	}{
+		{"Blue Devils vs Red Wolves", "Blue Devils", "Red Wolves"},
+		{"Red Wolves @ Blue Devils", "Blue Devils", "Red Wolves"},
+		{"blue devils v. Red Wolves", "Blue Devils", "Red Wolves"},
+		{"Red Wolves", "Blue Devils", "Red Wolves"},
+		{"vs Red Wolves", "Blue Devils", "Red Wolves"},
+		{"  @ Red Wolves ", "", "Red Wolves"},
+		// The team's name inside another word isn't the team
+		{"GOATS vs GO", "GO", "GOATS"},
+		{"GO vs GOATS", "GO", "GOATS"},
+		{"Strangers vs Rangers", "Rangers", "Strangers"},
+		{"Rangers at Strangers", "Rangers", "Strangers"},
+		{"Cup final: Blue Devils vs Red Wolves", "Blue Devils", "Red Wolves"},
+		{"Red Wolves - Green Giants - Blue Devils", "Blue Devils", "Red Wolves - Green Giants"},
+		{"Team V vs Blue Devils", "Blue Devils", "Team V"},
+
+		{"", "Blue Devils", ""},
+		{"Red Wolves vs Green Giants", "Blue Devils", ""},
+		{"GOATS vs GOPHERS", "GO", ""},
+		{"Blue Devils vs Red Wolves", "", ""},
+	}
+	for _, tt := range tests {
+		got, err := fixtureOpponent(tt.summary, tt.team)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("fixtureOpponent(%q, %q) = %q, want an error", tt.summary, tt.team, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("fixtureOpponent(%q, %q) = %q, %v; want %q", tt.summary, tt.team, got, err, tt.want)
+		}
+	}
+}
+
+func TestReadICS(t *testing.T) {
+	file := "\ufeffBEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Blue Devils vs Red\r\n" +
+		"  Wolves\r\n" +
+		"DESCRIPTION:Bring\r\n" +
+		"\tsnacks\r\n" +
+		"\r\n" +
+		"DTSTART;TZID=\"America/New_York\":20250304T200000\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	props, err := readICS(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []icsProperty{
+		{Name: "BEGIN", Value: "VCALENDAR", Line: 1},
+		{Name: "BEGIN", Value: "VEVENT", Line: 2},
+		{Name: "SUMMARY", Value: "Blue Devils vs Red Wolves", Line: 3},
+		{Name: "DESCRIPTION", Value: "Bringsnacks", Line: 5},
+		{Name: "DTSTART", Value: "20250304T200000", Line: 8},
+		{Name: "END", Value: "VEVENT", Line: 9},
+		{Name: "END", Value: "VCALENDAR", Line: 10},
+	}
+	if len(props) != len(want) {
+		t.Fatalf("got %d properties, want %d: %+v", len(props), len(want), props)
+	}
+	for i, p := range props {
+		if p.Name != want[i].Name || p.Value != want[i].Value || p.Line != want[i].Line {
+			t.Errorf("property %d = %+v, want %+v", i, p, want[i])
+		}
+	}
+	if tzid := props[4].Params["TZID"]; tzid != "America/New_York" {
+		t.Errorf("TZID %q", tzid)
+	}
+
+	if _, err := readICS(strings.NewReader("BEGIN:VCALENDAR\r\nnot a property\r\n")); !errors.Is(err, errInvalidFixtures) {
+		t.Errorf("line without a colon: %v", err)
+	}
+}
+
+func TestICSStart(t *testing.T) {
+	tests := []struct {
+		value  string
+		params map[string]string
+		zone   string
+
+		want     string // UTC, or "" if it should be refused
+		wantZone string
+	}{
+		{"20250304T200000", nil, "America/New_York", "2025-03-05T01:00:00Z", "America/New_York"},
+		{"20250311T200000", nil, "America/New_York", "2025-03-12T00:00:00Z", "America/New_York"},
+		{"20250304T200000Z", nil, "America/New_York", "2025-03-04T20:00:00Z", "America/New_York"},
+		{"20250304T200000", map[string]string{"TZID": "Europe/London"}, "America/New_York", "2025-03-04T20:00:00Z", "Europe/London"},
+		{"20250304T200000", map[string]string{"TZID": "/Europe/London"}, "America/New_York", "2025-03-04T20:00:00Z", "Europe/London"},
+
+		{"20250304", nil, "America/New_York", "", ""},
+		{"20250304", map[string]string{"VALUE": "DATE"}, "America/New_York", "", ""},
+		{"20250304T200000", map[string]string{"TZID": "Mars/Olympus"}, "America/New_York", "", ""},
+		{"2025-03-04 20:00", nil, "America/New_York", "", ""},
+	}
+	for _, tt := range tests {
+		got, zone, err := icsStart(icsProperty{Name: "DTSTART", Params: tt.params, Value: tt.value}, tt.zone)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("icsStart(%q, %v) = %s, want an error", tt.value, tt.params, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("icsStart(%q, %v): %v", tt.value, tt.params, err)
+			continue
+		}
+		if got.Format(time.RFC3339) != tt.want || zone != tt.wantZone {
+			t.Errorf("icsStart(%q, %v) = %s %s, want %s %s", tt.value, tt.params, got.Format(time.RFC3339), zone, tt.want, tt.wantZone)
+		}
+	}
+}
+
+func TestSameFixture(t *testing.T) {
+	at := func(value string) time.Time {
+		tm, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	// 20:00 on March 4 in New York, 01:00 on March 5 in UTC
+	game := Game{StartsAt: at("2025-03-05T01:00:00Z"), TimeZone: "America/New_York", Opponent: "Red Wolves", OpponentID: "opp_1"}
+
+	tests := []struct {
+		name  string
+		other Game
+		want  bool
+	}{
+		{"same game", Game{StartsAt: game.StartsAt, Opponent: "Red Wolves"}, true},
+		{"earlier that day", Game{StartsAt: at("2025-03-04T14:00:00Z"), Opponent: "red  wolves"}, true},
+		{"same opponent, renamed", Game{StartsAt: game.StartsAt, Opponent: "Wolves", OpponentID: "opp_1"}, true},
+		{"next day in New York", Game{StartsAt: at("2025-03-05T05:30:00Z"), Opponent: "Red Wolves"}, false},
+		{"another opponent", Game{StartsAt: game.StartsAt, Opponent: "Green Giants", OpponentID: "opp_2"}, false},
+	}
+	for _, tt := range tests {
+		if got := sameFixture(&game, &tt.other); got != tt.want {
+			t.Errorf("%s: sameFixture = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
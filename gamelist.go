@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== GAME LISTING ====================
+
+// GameFilter narrows FindGames; zero fields don't filter. Games come back
+// ordered by start time, then ID.
+type GameFilter struct {
+	TeamID   string
+	SeasonID string
+	// Games starting at or after From and before Until
+	From  time.Time
+	Until time.Time
+
+	LeagueID   string
+	DivisionID string
+	GameMode   string
+	OpponentID string
+	SeriesID   string
+
+	// MemberID keeps games the member takes part in; Participation narrows
+	// that to one of the participation values below.
+	MemberID      string
+	Participation string
+
+	// Status is gameUpcoming or gamePast, as of Now
+	Status string
+	Now    time.Time
+
+	// After skips games up to and including the cursor position
+	After *GameCursor
+	Limit int
+}
+
+// GameCursor is a position in a game listing: the last game of a page.
+type GameCursor struct {
+	StartsAt time.Time
+	ID       string
+}
+
+// Participation values for GameFilter
+const (
+	participationRostered    = "rostered"
+	participationSub         = "sub"
+	participationWithdrawn   = "withdrawn"
+	participationAvailable   = "available"
+	participationUnavailable = "unavailable"
+)
+
+// Status values for GameFilter
+const (
+	gameUpcoming = "upcoming"
+	gamePast     = "past"
+)
+
+const (
+	defaultGameLimit = 100
+	maxGameLimit     = 500
+)
+
+var errInvalidGameFilter = errors.New("invalid game filter")
+
+// matchesParticipation reports whether a member's participant row counts
+// for the filter's Participation. With no Participation, any answer or
+// role counts.
+func matchesParticipation(p participant, participation string) bool {
+	switch participation {
+	case participationRostered:
+		return p.Role == roleRoster
+	case participationSub:
+		return p.Role == roleSub
+	case participationWithdrawn:
+		return p.Role == roleWithdrawn
+	case participationAvailable:
+		return p.Status == statusAvailable
+	case participationUnavailable:
+		return p.Status == statusUnavailable
+	}
+	return p.Role != roleNone || p.Status != statusNone
+}
+
+// encodeGameCursor makes the opaque nextCursor value for a page ending at g.
+func encodeGameCursor(g *Game) string {
+	raw := g.StartsAt.UTC().Format(time.RFC3339Nano) + "|" + g.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeGameCursor(value string) (*GameCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	startsAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, startsAt)
+	if err != nil {
+		return nil, err
+	}
+	return &GameCursor{StartsAt: t.UTC(), ID: id}, nil
+}
+
+// parseGameListTime accepts an RFC 3339 timestamp or a plain date, which is
+// midnight in the default game time zone. A plain date for the end of a
+// range takes in the whole day.
+func parseGameListTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	loc, _ := time.LoadLocation(defaultGameTimeZone)
+	d, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d.UTC(), nil
+}
+
+// parseGameFilter reads a game listing's query parameters for the team and
+// season. limit is used when the request doesn't set one; 0 lists every
+// matching game. Bad parameters fail with errInvalidGameFilter.
+func parseGameFilter(r *http.Request, teamID, seasonID string, limit int) (GameFilter, error) {
+	query := r.URL.Query()
+	filter := GameFilter{
+		TeamID:     teamID,
+		SeasonID:   seasonID,
+		LeagueID:   query.Get("league"),
+		DivisionID: query.Get("division"),
+		GameMode:   query.Get("mode"),
+		Now:        time.Now().UTC(),
+		Limit:      limit,
+	}
+	invalid := func(format string, args ...interface{}) (GameFilter, error) {
+		return GameFilter{}, fmt.Errorf("%w: %s", errInvalidGameFilter, fmt.Sprintf(format, args...))
+	}
+
+	if v := query.Get("from"); v != "" {
+		t, err := parseGameListTime(v, false)
+		if err != nil {
+			return invalid("bad from time %q", v)
+		}
+		filter.From = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseGameListTime(v, true)
+		if err != nil {
+			return invalid("bad to time %q", v)
+		}
+		filter.Until = t
+	}
+
+	if v := query.Get("opponent"); v != "" {
+		opponent, err := store.GetOpponent(v)
+		if err == nil && opponent == nil {
+			opponent, err = store.FindOpponent(v)
+		}
+		if err != nil {
+			return GameFilter{}, err
+		}
+		// An opponent we have never heard of has no games
+		filter.OpponentID = v
+		if opponent != nil {
+			filter.OpponentID = opponent.ID
+		}
+	}
+
+	filter.SeriesID = query.Get("series")
+
+	switch v := query.Get("participation"); v {
+	case "", participationRostered, participationSub, participationWithdrawn, participationAvailable, participationUnavailable:
+		filter.Participation = v
+	default:
+		return invalid("unknown participation %q", v)
+	}
+	player := query.Get("player")
+	if player == "" && filter.Participation != "" {
+		if session := getSessionFromRequest(r); session != nil {
+			player = session.PlayerID
+		}
+		if player == "" {
+			return invalid("participation needs a player")
+		}
+	}
+	if player != "" {
+		member, err := getTeamMember(teamID, player)
+		if err != nil {
+			return GameFilter{}, err
+		}
+		if member == nil {
+			return invalid("unknown player %q", player)
+		}
+		filter.MemberID = member.ID
+	}
+
+	switch v := query.Get("status"); v {
+	case "", gameUpcoming, gamePast:
+		filter.Status = v
+	default:
+		return invalid("unknown status %q", v)
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodeGameCursor(v)
+		if err != nil {
+			return invalid("bad cursor")
+		}
+		filter.After = cursor
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return invalid("bad limit %q", v)
+		}
+		filter.Limit = n
+	}
+	if filter.Limit > maxGameLimit {
+		filter.Limit = maxGameLimit
+	}
+	return filter, nil
+}
+
+// GamePage is one page of a game listing. NextCursor is empty on the last
+// page; Total counts every matching game, not just this page's.
+type GamePage struct {
+	Games      []Game `json:"games"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// findGamePage runs a game listing, fetching one extra game to tell whether
+// there is a next page.
+func findGamePage(filter GameFilter) (*GamePage, error) {
+	limit := filter.Limit
+	if limit > 0 {
+		filter.Limit = limit + 1
+	}
+	games, total, err := store.FindGames(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &GamePage{Games: games, Total: total}
+	if limit > 0 && len(games) > limit {
+		page.Games = games[:limit]
+		page.NextCursor = encodeGameCursor(&page.Games[limit-1])
+	}
+	return page, nil
+}
+
+// writeGameFilterError reports a failure from parseGameFilter.
+func writeGameFilterError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidGameFilter) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== LEAGUES & DIVISIONS ====================
+
+// League is a competition a team plays in during one of its seasons. Games point
+// at leagues by ID, so a rename shows up on every game at once.
+type League struct {
+	ID              string `json:"id"`
+	SeasonID        string `json:"seasonId"`
+	Name            string `json:"name"`
+	ShortCode       string `json:"shortCode"`
+	Color           string `json:"color"`
+	DefaultGameMode string `json:"defaultGameMode"`
+	DefaultTeamSize int    `json:"defaultTeamSize"`
+}
+
+// Division is a tier within a season, optionally belonging to one league.
+type Division struct {
+	ID              string `json:"id"`
+	SeasonID        string `json:"seasonId"`
+	LeagueID        string `json:"leagueId,omitempty"`
+	Name            string `json:"name"`
+	ShortCode       string `json:"shortCode"`
+	Color           string `json:"color"`
+	DefaultGameMode string `json:"defaultGameMode"`
+	DefaultTeamSize int    `json:"defaultTeamSize"`
+}
+
+var errInvalidLeague = errors.New("invalid league or division")
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+const maxShortCodeLength = 8
+
+// cleanLeagueFields trims the fields leagues and divisions share and
+// returns what is wrong with them, or "" if they are fine.
+func cleanLeagueFields(name, shortCode, color, gameMode *string, teamSize int) string {
+	*name = strings.TrimSpace(*name)
+	*shortCode = strings.ToUpper(strings.TrimSpace(*shortCode))
+	*color = strings.TrimSpace(*color)
+	*gameMode = strings.TrimSpace(*gameMode)
+
+	switch {
+	case *name == "":
+		return "Name is required"
+	case len(*shortCode) > maxShortCodeLength:
+		return fmt.Sprintf("Short code can be at most %d characters", maxShortCodeLength)
+	case *color != "" && !hexColor.MatchString(*color):
+		return "Color must be a hex color like #1e90ff"
+	case teamSize < 0:
+		return "Default team size can't be negative"
+	}
+	return ""
+}
+
+// requestedLeagueSeason returns the season a new league or division goes in:
+// the team's season named in the body, or its active season.
+func requestedLeagueSeason(teamID, seasonID string) (string, error) {
+	if seasonID == "" {
+		return activeSeasonID(teamID)
+	}
+	season, err := getTeamSeason(teamID, seasonID)
+	if err != nil {
+		return "", err
+	}
+	if season == nil {
+		return "", errUnknownSeason
+	}
+	return season.ID, nil
+}
+
+// getLeagueInTeam returns the request's team and a league if it is in one
+// of the team's seasons, or a nil league.
+func getLeagueInTeam(r *http.Request, leagueID string) (*Team, *League, error) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	league, err := store.GetLeague(leagueID)
+	if err != nil || league == nil {
+		return team, nil, err
+	}
+	if season, err := getTeamSeason(team.ID, league.SeasonID); err != nil || season == nil {
+		return team, nil, err
+	}
+	return team, league, nil
+}
+
+// getDivisionInTeam is getLeagueInTeam for divisions.
+func getDivisionInTeam(r *http.Request, divisionID string) (*Team, *Division, error) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	division, err := store.GetDivision(divisionID)
+	if err != nil || division == nil {
+		return team, nil, err
+	}
+	if season, err := getTeamSeason(team.ID, division.SeasonID); err != nil || season == nil {
+		return team, nil, err
+	}
+	return team, division, nil
+}
+
+// findLeague looks a league up by ID or, for older clients, by name within
+// the season.
+func findLeague(seasonID, ref string) (*League, error) {
+	leagues, err := store.ListLeagues("", seasonID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range leagues {
+		if leagues[i].ID == ref {
+			return &leagues[i], nil
+		}
+	}
+	for i := range leagues {
+		if strings.EqualFold(leagues[i].Name, ref) {
+			return &leagues[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown league %q", errInvalidLeague, ref)
+}
+
+// findDivision is findLeague for divisions.
+func findDivision(seasonID, ref string) (*Division, error) {
+	divisions, err := store.ListDivisions("", seasonID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range divisions {
+		if divisions[i].ID == ref {
+			return &divisions[i], nil
+		}
+	}
+	for i := range divisions {
+		if strings.EqualFold(divisions[i].Name, ref) {
+			return &divisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown division %q", errInvalidLeague, ref)
+}
+
+// resolveGameLeague turns the league and division a game request names (by
+// ID or name, either may be empty) into IDs in the game's season. A division
+// that belongs to a league puts the game in that league too.
+func resolveGameLeague(seasonID, leagueRef, divisionRef string) (*League, *Division, error) {
+	var league *League
+	var division *Division
+	var err error
+
+	if leagueRef != "" {
+		if league, err = findLeague(seasonID, leagueRef); err != nil {
+			return nil, nil, err
+		}
+	}
+	if divisionRef != "" {
+		if division, err = findDivision(seasonID, divisionRef); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if division != nil && division.LeagueID != "" {
+		if league == nil {
+			if league, err = store.GetLeague(division.LeagueID); err != nil {
+				return nil, nil, err
+			}
+		} else if league.ID != division.LeagueID {
+			return nil, nil, fmt.Errorf("%w: division %q is not part of league %q", errInvalidLeague, division.Name, league.Name)
+		}
+	}
+	return league, division, nil
+}
+
+// copySeasonLeagues gives a new season copies of another season's leagues
+// and divisions, keeping each division under its league's copy.
+func copySeasonLeagues(fromID, toID string) error {
+	leagues, err := store.ListLeagues("", fromID)
+	if err != nil {
+		return err
+	}
+	newIDs := make(map[string]string)
+	for _, l := range leagues {
+		l.SeasonID = toID
+		old := l.ID
+		l.ID = generateID("league")
+		if _, err := store.CreateLeague(l); err != nil {
+			return err
+		}
+		newIDs[old] = l.ID
+	}
+
+	divisions, err := store.ListDivisions("", fromID)
+	if err != nil {
+		return err
+	}
+	for _, d := range divisions {
+		d.SeasonID = toID
+		d.ID = generateID("division")
+		d.LeagueID = newIDs[d.LeagueID]
+		if _, err := store.CreateDivision(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditMovedGames records the games a delete moved to another league or
+// division, using the snapshots taken before the delete.
+func auditMovedGames(teamID, actor string, before map[string]*Game, movedIDs []string) {
+	for _, id := range movedIDs {
+		after, _ := store.GetGame(id)
+		recordAudit(teamID, actor, "update", auditGame, id, before[id], after)
+	}
+}
+
+func handleGetLeagues(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+
+	leagues, err := store.ListLeagues(team.ID, seasonID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, leagues)
+}
+
+func handleCreateLeague(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	var league League
+	if err := json.NewDecoder(r.Body).Decode(&league); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if msg := cleanLeagueFields(&league.Name, &league.ShortCode, &league.Color, &league.DefaultGameMode, league.DefaultTeamSize); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedLeagueSeason(team.ID, league.SeasonID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+	league.ID = generateID("league")
+	league.SeasonID = seasonID
+
+	created, err := store.CreateLeague(league)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "League already exists")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "create", auditLeague, created.ID, nil, created)
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func handleUpdateLeague(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	leagueID := vars["id"]
+
+	team, before, err := getLeagueInTeam(r, leagueID)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "League not found")
+		return
+	}
+
+	// Fields missing from the body keep their current values
+	league := *before
+	if err := json.NewDecoder(r.Body).Decode(&league); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	league.ID, league.SeasonID = before.ID, before.SeasonID
+	if msg := cleanLeagueFields(&league.Name, &league.ShortCode, &league.Color, &league.DefaultGameMode, league.DefaultTeamSize); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	updated, err := store.UpdateLeague(league)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "League already exists")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditLeague, leagueID, before, updated)
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// handleDeleteLeague deletes a league that no game uses, or moves its games
+// to the league named by ?replaceWith= first. Its divisions move along with
+// the games, or lose their league if there is no replacement.
+func handleDeleteLeague(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	leagueID := vars["id"]
+	replaceWith := r.URL.Query().Get("replaceWith")
+
+	team, league, err := getLeagueInTeam(r, leagueID)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	if league == nil {
+		writeError(w, http.StatusNotFound, "League not found")
+		return
+	}
+
+	if replaceWith != "" {
+		replacement, err := findLeague(league.SeasonID, replaceWith)
+		if err != nil || replacement.ID == league.ID {
+			writeError(w, http.StatusBadRequest, "replaceWith must be another league in the same season")
+			return
+		}
+		replaceWith = replacement.ID
+	}
+
+	before := make(map[string]*Game)
+	if games, err := store.ListGames("", league.SeasonID); err == nil {
+		for i := range games {
+			if games[i].LeagueID == leagueID {
+				before[games[i].ID] = &games[i]
+			}
+		}
+	}
+
+	moved, err := store.DeleteLeague(leagueID, replaceWith)
+	if errors.Is(err, errInUse) {
+		writeError(w, http.StatusConflict, "League is still used by games; pass replaceWith to move them to another league")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "delete", auditLeague, leagueID, league, nil)
+	auditMovedGames(team.ID, session.DiscordID, before, moved)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"movedGames": len(moved),
+	})
+}
+
+func handleGetDivisions(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+
+	divisions, err := store.ListDivisions(team.ID, seasonID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, divisions)
+}
+
+// checkDivisionLeague resolves a division's parent league, which must be in
+// the division's season. It returns a message for the client if it isn't.
+func checkDivisionLeague(d *Division) (string, error) {
+	if d.LeagueID == "" {
+		return "", nil
+	}
+	league, err := findLeague(d.SeasonID, d.LeagueID)
+	if errors.Is(err, errInvalidLeague) {
+		return "League must be a league in the division's season", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	d.LeagueID = league.ID
+	return "", nil
+}
+
+func handleCreateDivision(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	var division Division
+	if err := json.NewDecoder(r.Body).Decode(&division); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if msg := cleanLeagueFields(&division.Name, &division.ShortCode, &division.Color, &division.DefaultGameMode, division.DefaultTeamSize); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedLeagueSeason(team.ID, division.SeasonID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+	division.ID = generateID("division")
+	division.SeasonID = seasonID
+
+	msg, err := checkDivisionLeague(&division)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	created, err := store.CreateDivision(division)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "Division already exists")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "create", auditDivision, created.ID, nil, created)
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func handleUpdateDivision(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	divisionID := vars["id"]
+
+	team, before, err := getDivisionInTeam(r, divisionID)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Division not found")
+		return
+	}
+
+	// Fields missing from the body keep their current values
+	division := *before
+	if err := json.NewDecoder(r.Body).Decode(&division); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	division.ID, division.SeasonID = before.ID, before.SeasonID
+	if msg := cleanLeagueFields(&division.Name, &division.ShortCode, &division.Color, &division.DefaultGameMode, division.DefaultTeamSize); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	msg, err := checkDivisionLeague(&division)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	updated, err := store.UpdateDivision(division)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "Division already exists")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditDivision, divisionID, before, updated)
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// handleDeleteDivision is handleDeleteLeague for divisions.
+func handleDeleteDivision(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	divisionID := vars["id"]
+	replaceWith := r.URL.Query().Get("replaceWith")
+
+	team, division, err := getDivisionInTeam(r, divisionID)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	if division == nil {
+		writeError(w, http.StatusNotFound, "Division not found")
+		return
+	}
+
+	if replaceWith != "" {
+		replacement, err := findDivision(division.SeasonID, replaceWith)
+		if err != nil || replacement.ID == division.ID {
+			writeError(w, http.StatusBadRequest, "replaceWith must be another division in the same season")
+			return
+		}
+		replaceWith = replacement.ID
+	}
+
+	before := make(map[string]*Game)
+	if games, err := store.ListGames("", division.SeasonID); err == nil {
+		for i := range games {
+			if games[i].DivisionID == divisionID {
+				before[games[i].ID] = &games[i]
+			}
+		}
+	}
+
+	moved, err := store.DeleteDivision(divisionID, replaceWith)
+	if errors.Is(err, errInUse) {
+		writeError(w, http.StatusConflict, "Division is still used by games; pass replaceWith to move them to another division")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "delete", auditDivision, divisionID, division, nil)
+	auditMovedGames(team.ID, session.DiscordID, before, moved)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"movedGames": len(moved),
+	})
+}
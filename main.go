@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,27 +17,38 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	_ "time/tzdata" // game time zones must resolve even on hosts without zoneinfo
 
 	"github.com/gorilla/mux"
-	_ "github.com/lib/pq"
 )
 
 // ==================== TYPES ====================
 
 type Member struct {
 	ID     string `json:"id"`
+	TeamID string `json:"teamId,omitempty"`
 	Name   string `json:"name"`
 	Year   int    `json:"year"`
 	Region string `json:"region,omitempty"`
 	Note   string `json:"note,omitempty"`
 	IsVet  bool   `json:"isVet,omitempty"`
+	// Aliases are the member's former names, oldest first
+	Aliases []string `json:"aliases,omitempty"`
+	// DeletedAt is set while the member is in the trash
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
 type Game struct {
-	ID          string   `json:"id"`
+	ID       string    `json:"id"`
+	StartsAt time.Time `json:"startsAt"`
+	TimeZone string    `json:"timeZone"`
+	// Date and Time are the wall-clock start in TimeZone, derived from StartsAt
 	Date        string   `json:"date"`
 	Time        string   `json:"time"`
 	Opponent    string   `json:"opponent"`
+	OpponentID  string   `json:"opponentId,omitempty"`
+	LeagueID    string   `json:"leagueId,omitempty"`
+	DivisionID  string   `json:"divisionId,omitempty"`
 	League      string   `json:"league,omitempty"`
 	Division    string   `json:"division,omitempty"`
 	GameMode    string   `json:"gameMode,omitempty"`
@@ -49,6 +60,24 @@ type Game struct {
 	Subs        []string `json:"subs"`
 	Withdrawals []string `json:"withdrawals"`
 	Reminded    bool     `json:"reminded"`
+	TeamID      string   `json:"teamId,omitempty"`
+	SeasonID    string   `json:"seasonId,omitempty"`
+	// SeriesID links a game generated by a recurring series; OccurrenceAt
+	// is the start the series gave it, kept when the game is moved
+	SeriesID     string     `json:"seriesId,omitempty"`
+	OccurrenceAt *time.Time `json:"occurrenceAt,omitempty"`
+	// Result is set once a manager records how the game went
+	Result *GameResult `json:"result,omitempty"`
+	// Version goes up with every change; it is the game's ETag
+	Version int `json:"version"`
+	// DeletedAt is set while the game is in the trash
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Viewer is the start in the requesting user's time zone, when known;
+	// it is never stored
+	Viewer *ViewerTime `json:"viewer,omitempty"`
+	// Conflicts are the scheduling conflicts a forced write went ahead
+	// with; they are never stored
+	Conflicts []Conflict `json:"conflicts,omitempty"`
 }
 
 type User struct {
@@ -60,14 +89,24 @@ type User struct {
 	IsManager   bool   `json:"isManager"`
 	Email       string `json:"email,omitempty"`
 	Phone       string `json:"phone,omitempty"`
+	// TimeZone is the IANA zone the user wants times shown in, if they
+	// have picked one
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 type AllData struct {
+	Team              *Team             `json:"team"`
+	Season            *Season           `json:"season,omitempty"`
 	Games             []Game            `json:"games"`
+	TotalGames        int               `json:"totalGames"`
+	NextCursor        string            `json:"nextCursor,omitempty"`
 	PlayerPreferences map[string]string `json:"playerPreferences"`
 	DiscordWebhook    string            `json:"discordWebhook"`
 }
 
+// Session is the signed session cookie. IsManager and PlayerID are not
+// trusted from the cookie: getSessionFromRequest fills them in for the team
+// the request is for.
 type Session struct {
 	DiscordID   string `json:"d"`
 	Username    string `json:"u"`
@@ -78,14 +117,13 @@ type Session struct {
 
 // ==================== GLOBALS ====================
 
-var db *sql.DB
-
 var (
 	discordClientID     = os.Getenv("DISCORD_CLIENT_ID")
 	discordClientSecret = os.Getenv("DISCORD_CLIENT_SECRET")
 	discordBotToken     = os.Getenv("DISCORD_BOT_TOKEN")
 	discordGuildID      = os.Getenv("DISCORD_GUILD_ID")
 	discordManagerRole  = os.Getenv("DISCORD_MANAGER_ROLE")
+	adminDiscordIDs     = os.Getenv("ADMIN_DISCORD_IDS") // comma-separated
 	sessionSecret       = os.Getenv("SESSION_SECRET")
 	baseURL             = os.Getenv("BASE_URL") // e.g., https://go-pop1-calendar.onrender.com
 )
@@ -136,155 +174,6 @@ var SubMembers = []Member{
 	{ID: "johnharple", Name: "GO_JohnHarple", Year: 2025, IsVet: true},
 }
 
-// ==================== DATABASE ====================
-
-func initDB() error {
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Println("DATABASE_URL not set, running without database")
-		return nil
-	}
-
-	// Add search_path to connection string for go_calendar schema
-	if strings.Contains(dbURL, "?") {
-		dbURL += "&search_path=go_calendar"
-	} else {
-		dbURL += "?search_path=go_calendar"
-	}
-
-	var err error
-	db, err = sql.Open("postgres", dbURL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %v", err)
-	}
-
-	if err = db.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %v", err)
-	}
-
-	// Create schema for go_calendar to keep tables separate from hopzle
-	_, err = db.Exec(`CREATE SCHEMA IF NOT EXISTS go_calendar`)
-	if err != nil {
-		return fmt.Errorf("failed to create schema: %v", err)
-	}
-
-	// Create tables (will be in go_calendar schema due to search_path)
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS games (
-			id TEXT PRIMARY KEY,
-			date TEXT NOT NULL,
-			time TEXT NOT NULL,
-			opponent TEXT NOT NULL,
-			notes TEXT DEFAULT '',
-			available TEXT DEFAULT '[]',
-			unavailable TEXT DEFAULT '[]',
-			roster TEXT DEFAULT '[]',
-			reminded BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create games table: %v", err)
-	}
-
-	// Add reminded column if it doesn't exist
-	db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS reminded BOOLEAN DEFAULT FALSE`)
-	// Add league and division columns
-	db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS league TEXT DEFAULT ''`)
-	db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS division TEXT DEFAULT ''`)
-	// Add game mode and team size columns
-	db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS game_mode TEXT DEFAULT 'War'`)
-	db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS team_size INTEGER DEFAULT 10`)
-	// Add withdrawals column for tracking players who pulled out
-	db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS withdrawals TEXT DEFAULT '[]'`)
-	// Add subs column for backup players
-	db.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS subs TEXT DEFAULT '[]'`)
-
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS player_preferences (
-			player_id TEXT PRIMARY KEY,
-			preference TEXT DEFAULT 'starter'
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create player_preferences table: %v", err)
-	}
-
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS settings (
-			key TEXT PRIMARY KEY,
-			value TEXT
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create settings table: %v", err)
-	}
-
-	// Users table for Discord OAuth
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			discord_id TEXT PRIMARY KEY,
-			username TEXT NOT NULL,
-			display_name TEXT,
-			avatar TEXT,
-			player_id TEXT,
-			is_manager BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create users table: %v", err)
-	}
-	// Add email and phone columns for notifications
-	db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT DEFAULT ''`)
-	db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT DEFAULT ''`)
-
-	// Members table for roster management
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS members (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			year INTEGER DEFAULT 2025,
-			region TEXT,
-			note TEXT,
-			is_vet BOOLEAN DEFAULT FALSE,
-			sort_order INTEGER DEFAULT 0,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create members table: %v", err)
-	}
-
-	// Seed members if table is empty
-	var count int
-	db.QueryRow("SELECT COUNT(*) FROM members").Scan(&count)
-	if count == 0 {
-		seedMembers()
-	}
-
-	log.Println("Database initialized with go_calendar schema")
-	return nil
-}
-
-func seedMembers() {
-	log.Println("Seeding members table with initial data...")
-
-	// Insert active members
-	for i, m := range ActiveMembers {
-		db.Exec(`INSERT INTO members (id, name, year, region, note, is_vet, sort_order) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-			m.ID, m.Name, m.Year, m.Region, m.Note, false, i)
-	}
-
-	// Insert sub members
-	for i, m := range SubMembers {
-		db.Exec(`INSERT INTO members (id, name, year, region, note, is_vet, sort_order) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-			m.ID, m.Name, m.Year, m.Region, m.Note, true, i)
-	}
-
-	log.Printf("Seeded %d active members and %d subs", len(ActiveMembers), len(SubMembers))
-}
-
 // ==================== SESSION HELPERS ====================
 
 func createSessionToken(session Session) (string, error) {
@@ -360,6 +249,13 @@ func getSessionFromRequest(r *http.Request) *Session {
 		return nil
 	}
 
+	session.IsManager, session.PlayerID = false, ""
+	if team, err := requestedTeam(r); err == nil {
+		if user, err := store.GetUser(team.ID, session.DiscordID); err == nil && user != nil {
+			session.IsManager, session.PlayerID = user.IsManager, user.PlayerID
+		}
+	}
+
 	return session
 }
 
@@ -461,47 +357,45 @@ func getDiscordUser(accessToken string) (map[string]interface{}, error) {
 	return user, nil
 }
 
-func checkUserHasManagerRole(accessToken, userID string) bool {
-	if discordGuildID == "" || discordManagerRole == "" {
-		return false
-	}
-
+// checkGuildMember reports whether the logged-in user is in the Discord
+// server and whether they hold the named manager role there.
+func checkGuildMember(accessToken, guildID, managerRole string) (member, manager bool) {
 	// Get guild member info
-	url := fmt.Sprintf("https://discord.com/api/users/@me/guilds/%s/member", discordGuildID)
+	url := fmt.Sprintf("https://discord.com/api/users/@me/guilds/%s/member", guildID)
 	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("Error fetching guild member: %v", err)
-		return false
+		return false, false
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return false
+		return false, false
 	}
 
-	var member struct {
+	var guildMember struct {
 		Roles []string `json:"roles"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil {
-		return false
+	if err := json.NewDecoder(resp.Body).Decode(&guildMember); err != nil {
+		return false, false
 	}
 
 	// We need to get guild roles to match role name to ID
 	// Using bot token to get roles
-	if discordBotToken == "" {
-		return false
+	if discordBotToken == "" || managerRole == "" {
+		return true, false
 	}
 
-	rolesURL := fmt.Sprintf("https://discord.com/api/guilds/%s/roles", discordGuildID)
+	rolesURL := fmt.Sprintf("https://discord.com/api/guilds/%s/roles", guildID)
 	rolesReq, _ := http.NewRequest("GET", rolesURL, nil)
 	rolesReq.Header.Set("Authorization", "Bot "+discordBotToken)
 
 	rolesResp, err := http.DefaultClient.Do(rolesReq)
 	if err != nil {
-		return false
+		return true, false
 	}
 	defer rolesResp.Body.Close()
 
@@ -510,177 +404,133 @@ func checkUserHasManagerRole(accessToken, userID string) bool {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(rolesResp.Body).Decode(&roles); err != nil {
-		return false
+		return true, false
 	}
 
 	// Find the manager role ID
 	var managerRoleID string
 	for _, role := range roles {
-		if strings.EqualFold(role.Name, discordManagerRole) {
+		if strings.EqualFold(role.Name, managerRole) {
 			managerRoleID = role.ID
 			break
 		}
 	}
 
 	if managerRoleID == "" {
-		return false
+		return true, false
 	}
 
 	// Check if user has the role
-	for _, roleID := range member.Roles {
+	for _, roleID := range guildMember.Roles {
 		if roleID == managerRoleID {
-			return true
+			return true, true
 		}
 	}
 
-	return false
+	return true, false
 }
 
-// ==================== USER DATABASE FUNCTIONS ====================
-
-func getUserByDiscordID(discordID string) (*User, error) {
-	if db == nil {
-		return nil, nil
-	}
-
-	var u User
-	var playerID, displayName, avatar, email, phone sql.NullString
-	err := db.QueryRow(`
-		SELECT discord_id, username, display_name, avatar, player_id, is_manager, COALESCE(email, ''), COALESCE(phone, '')
-		FROM users WHERE discord_id = $1
-	`, discordID).Scan(&u.DiscordID, &u.Username, &displayName, &avatar, &playerID, &u.IsManager, &email, &phone)
+// ==================== HELPERS ====================
 
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
+func getMemberName(memberID string) string {
+	if m, err := store.GetMember(memberID); err == nil && m != nil {
+		return m.Name
 	}
-
-	u.DisplayName = displayName.String
-	u.Avatar = avatar.String
-	u.PlayerID = playerID.String
-	u.Email = email.String
-	u.Phone = phone.String
-
-	return &u, nil
+	return memberID
 }
 
-func createOrUpdateUser(discordID, username, displayName, avatar string, isManager bool) error {
-	if db == nil {
-		return fmt.Errorf("database not connected")
+func getDiscordIDForPlayer(playerID string) string {
+	user, err := store.GetUserByPlayerID(playerID)
+	if err != nil || user == nil {
+		return ""
 	}
+	return user.DiscordID
+}
 
-	_, err := db.Exec(`
-		INSERT INTO users (discord_id, username, display_name, avatar, is_manager)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (discord_id) DO UPDATE SET
-			username = $2,
-			display_name = $3,
-			avatar = $4,
-			is_manager = $5
-	`, discordID, username, displayName, avatar, isManager)
+// defaultGameTimeZone applies to games created without an explicit zone;
+// the team has always scheduled in Eastern time.
+const defaultGameTimeZone = "America/New_York"
 
-	return err
-}
+var clockLayouts = []string{"15:04", "15:04:05", "3:04 PM", "3:04PM", "3 PM", "3PM"}
 
-func linkUserToPlayer(discordID, playerID string) error {
-	if db == nil {
-		return fmt.Errorf("database not connected")
+// parseWallClock turns a date ("2006-01-02") and a time of day in loc into an instant.
+func parseWallClock(date, clock string, loc *time.Location) (time.Time, error) {
+	d, err := time.Parse("2006-01-02", strings.TrimSpace(date))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", date)
 	}
 
-	_, err := db.Exec(`UPDATE users SET player_id = $1 WHERE discord_id = $2`, playerID, discordID)
-	return err
+	clock = strings.ToUpper(strings.TrimSpace(clock))
+	for _, layout := range clockLayouts {
+		if c, err := time.Parse(layout, clock); err == nil {
+			return time.Date(d.Year(), d.Month(), d.Day(), c.Hour(), c.Minute(), 0, 0, loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", clock)
 }
 
-func getLinkedPlayerIDs() ([]string, error) {
-	if db == nil {
-		return []string{}, nil
+// resolveGameStart reads a game's start from a request: either an RFC 3339
+// startsAt, or date and time as wall-clock in timeZone. It returns the
+// instant and the validated zone name.
+func resolveGameStart(startsAt, date, clock, timeZone string) (time.Time, string, error) {
+	if timeZone == "" {
+		timeZone = defaultGameTimeZone
 	}
-
-	rows, err := db.Query(`SELECT player_id FROM users WHERE player_id IS NOT NULL AND player_id != ''`)
+	loc, err := time.LoadLocation(timeZone)
 	if err != nil {
-		return nil, err
+		return time.Time{}, "", fmt.Errorf("unknown time zone %q", timeZone)
 	}
-	defer rows.Close()
 
-	var ids []string
-	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err == nil {
-			ids = append(ids, id)
+	if startsAt != "" {
+		t, err := time.Parse(time.RFC3339, startsAt)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("startsAt must be an RFC 3339 timestamp")
 		}
+		return t.UTC(), timeZone, nil
 	}
 
-	return ids, nil
-}
-
-func getUserByPlayerID(playerID string) (*User, error) {
-	if db == nil {
-		return nil, nil
+	t, err := parseWallClock(date, clock, loc)
+	if err != nil {
+		return time.Time{}, "", err
 	}
+	return t.UTC(), timeZone, nil
+}
 
-	var u User
-	var pID, displayName, avatar sql.NullString
-	err := db.QueryRow(`
-		SELECT discord_id, username, display_name, avatar, player_id, is_manager
-		FROM users WHERE player_id = $1
-	`, playerID).Scan(&u.DiscordID, &u.Username, &displayName, &avatar, &pID, &u.IsManager)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+// gameLocalTime returns the game's start in its own time zone.
+func gameLocalTime(g *Game) time.Time {
+	loc, err := time.LoadLocation(g.TimeZone)
 	if err != nil {
-		return nil, err
+		loc, _ = time.LoadLocation(defaultGameTimeZone)
 	}
-
-	u.DisplayName = displayName.String
-	u.Avatar = avatar.String
-	u.PlayerID = pID.String
-
-	return &u, nil
+	return g.StartsAt.In(loc)
 }
 
-// ==================== HELPERS ====================
-
-func getMemberName(memberID string) string {
-	allMembers := append(ActiveMembers, SubMembers...)
-	for _, m := range allMembers {
-		if m.ID == memberID {
-			return m.Name
-		}
-	}
-	return memberID
+// localizeGame fills the wall-clock Date and Time fields from StartsAt.
+func localizeGame(g *Game) {
+	local := gameLocalTime(g)
+	g.Date = local.Format("2006-01-02")
+	g.Time = local.Format("15:04")
 }
 
-func getDiscordIDForPlayer(playerID string) string {
-	var discordID string
-	err := db.QueryRow("SELECT discord_id FROM users WHERE player_id = $1", playerID).Scan(&discordID)
-	if err != nil {
-		return ""
-	}
-	return discordID
+// formatGameDate renders a game's date in its own time zone, e.g.
+// "Tuesday, Mar 04, 2025", for Discord text that can't take timestamps.
+func formatGameDate(g *Game) string {
+	return gameLocalTime(g).Format("Monday, Jan 02, 2006")
 }
 
 func generateGameID() string {
+	return generateID("game")
+}
+
+// generateID makes a unique ID such as game_1700000000000_k3j9x0q2a.
+func generateID(prefix string) string {
 	timestamp := time.Now().UnixMilli()
 	chars := "abcdefghijklmnopqrstuvwxyz0123456789"
 	suffix := make([]byte, 9)
 	for i := range suffix {
 		suffix[i] = chars[rand.Intn(len(chars))]
 	}
-	return fmt.Sprintf("game_%d_%s", timestamp, string(suffix))
-}
-
-func parseJSONArray(s string) []string {
-	if s == "" {
-		return []string{}
-	}
-	var arr []string
-	if err := json.Unmarshal([]byte(s), &arr); err != nil {
-		return []string{}
-	}
-	return arr
+	return fmt.Sprintf("%s_%d_%s", prefix, timestamp, string(suffix))
 }
 
 func toJSONString(arr []string) string {
@@ -691,6 +541,16 @@ func toJSONString(arr []string) string {
 	return string(b)
 }
 
+// firstNonEmpty returns the first of values that isn't "".
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -701,202 +561,62 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
-func getUserAvatar(user *User) string {
-	if user != nil {
-		return user.Avatar
-	}
-	return ""
-}
-
-func getUserDisplayName(user *User) string {
-	if user != nil {
-		return user.DisplayName
-	}
-	return ""
-}
-
-// ==================== DATA FUNCTIONS ====================
-
-func getAllGames() ([]Game, error) {
-	if db == nil {
-		return []Game{}, nil
-	}
-
-	rows, err := db.Query(`SELECT id, date, time, opponent, COALESCE(league, ''), COALESCE(division, ''),
-		COALESCE(game_mode, 'War'), COALESCE(team_size, 10), notes, available, unavailable, roster,
-		COALESCE(subs, '[]'), COALESCE(withdrawals, '[]'), COALESCE(reminded, false) FROM games ORDER BY date, time`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var games []Game
-	for rows.Next() {
-		var g Game
-		var available, unavailable, roster, subs, withdrawals string
-		if err := rows.Scan(&g.ID, &g.Date, &g.Time, &g.Opponent, &g.League, &g.Division, &g.GameMode, &g.TeamSize, &g.Notes, &available, &unavailable, &roster, &subs, &withdrawals, &g.Reminded); err != nil {
-			return nil, err
-		}
-		g.Available = parseJSONArray(available)
-		g.Unavailable = parseJSONArray(unavailable)
-		g.Roster = parseJSONArray(roster)
-		g.Subs = parseJSONArray(subs)
-		g.Withdrawals = parseJSONArray(withdrawals)
-		games = append(games, g)
-	}
-
-	if games == nil {
-		games = []Game{}
-	}
-	return games, nil
-}
-
-func getGameByID(gameID string) (*Game, error) {
-	if db == nil {
-		return nil, nil
-	}
-
-	var g Game
-	var available, unavailable, roster, subs, withdrawals string
-	err := db.QueryRow(`SELECT id, date, time, opponent, COALESCE(league, ''), COALESCE(division, ''),
-		COALESCE(game_mode, 'War'), COALESCE(team_size, 10), notes, available, unavailable, roster,
-		COALESCE(subs, '[]'), COALESCE(withdrawals, '[]'), COALESCE(reminded, false) FROM games WHERE id = $1`, gameID).
-		Scan(&g.ID, &g.Date, &g.Time, &g.Opponent, &g.League, &g.Division, &g.GameMode, &g.TeamSize, &g.Notes, &available, &unavailable, &roster, &subs, &withdrawals, &g.Reminded)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	g.Available = parseJSONArray(available)
-	g.Unavailable = parseJSONArray(unavailable)
-	g.Roster = parseJSONArray(roster)
-	g.Subs = parseJSONArray(subs)
-	g.Withdrawals = parseJSONArray(withdrawals)
-	return &g, nil
-}
-
-func createGame(date, gameTime, opponent, league, division, gameMode string, teamSize int, notes string) (*Game, error) {
-	if db == nil {
-		return nil, fmt.Errorf("database not connected")
-	}
-
-	if gameMode == "" {
-		gameMode = "War"
-	}
-	if teamSize <= 0 {
-		teamSize = 10
-	}
-
-	gameID := generateGameID()
-	_, err := db.Exec(
-		`INSERT INTO games (id, date, time, opponent, league, division, game_mode, team_size, notes, available, unavailable, roster, subs, withdrawals, reminded)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, '[]', '[]', '[]', '[]', '[]', false)`,
-		gameID, date, gameTime, opponent, league, division, gameMode, teamSize, notes,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return getGameByID(gameID)
+func gameETag(g *Game) string {
+	return fmt.Sprintf(`"%d"`, g.Version)
 }
 
-func updateGame(gameID string, updates map[string]interface{}) (*Game, error) {
-	if db == nil {
-		return nil, fmt.Errorf("database not connected")
-	}
-
-	for key, value := range updates {
-		var val interface{}
-		switch v := value.(type) {
-		case []string:
-			val = toJSONString(v)
-		case string:
-			val = v
-		case bool:
-			val = v
-		default:
-			continue
-		}
-		_, err := db.Exec(fmt.Sprintf("UPDATE games SET %s = $1 WHERE id = $2", key), val, gameID)
-		if err != nil {
-			return nil, err
-		}
+// ifMatchVersion reads the game version a client last saw from If-Match.
+// It returns 0, meaning don't check, when the header is missing or "*".
+func ifMatchVersion(r *http.Request) (int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
 	}
-
-	return getGameByID(gameID)
-}
-
-func deleteGame(gameID string) error {
-	if db == nil {
-		return fmt.Errorf("database not connected")
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.Atoi(value)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header")
 	}
-
-	_, err := db.Exec("DELETE FROM games WHERE id = $1", gameID)
-	return err
+	return version, nil
 }
 
-func getAllPreferences() (map[string]string, error) {
-	prefs := make(map[string]string)
-	if db == nil {
-		return prefs, nil
-	}
-
-	rows, err := db.Query("SELECT player_id, preference FROM player_preferences")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var playerID, pref string
-		if err := rows.Scan(&playerID, &pref); err != nil {
-			return nil, err
-		}
-		prefs[playerID] = pref
+// writeGame writes a game along with its ETag, with its start as the
+// requesting user sees it.
+func writeGame(w http.ResponseWriter, r *http.Request, status int, g *Game) {
+	if loc := viewerLocation(r); loc != nil {
+		setViewerTime(g, loc)
 	}
-
-	return prefs, nil
+	w.Header().Set("ETag", gameETag(g))
+	writeJSON(w, status, g)
 }
 
-func setPreference(playerID, preference string) error {
-	if db == nil {
-		return fmt.Errorf("database not connected")
+// writeVersionConflict answers a stale If-Match with 409 and the game as it
+// is now, so the client can merge and retry.
+func writeVersionConflict(w http.ResponseWriter, gameID string) {
+	current, err := store.GetGame(gameID)
+	if err != nil || current == nil {
+		writeError(w, http.StatusConflict, errVersionConflict.Error())
+		return
 	}
-
-	_, err := db.Exec(`
-		INSERT INTO player_preferences (player_id, preference)
-		VALUES ($1, $2)
-		ON CONFLICT (player_id) DO UPDATE SET preference = $2
-	`, playerID, preference)
-	return err
+	w.Header().Set("ETag", gameETag(current))
+	writeJSON(w, http.StatusConflict, map[string]interface{}{
+		"error": errVersionConflict.Error(),
+		"game":  current,
+	})
 }
 
-func getSetting(key string) (string, error) {
-	if db == nil {
-		return "", nil
-	}
-
-	var value string
-	err := db.QueryRow("SELECT value FROM settings WHERE key = $1", key).Scan(&value)
-	if err == sql.ErrNoRows {
-		return "", nil
+func getUserAvatar(user *User) string {
+	if user != nil {
+		return user.Avatar
 	}
-	return value, err
+	return ""
 }
 
-func setSetting(key, value string) error {
-	if db == nil {
-		return fmt.Errorf("database not connected")
+func getUserDisplayName(user *User) string {
+	if user != nil {
+		return user.DisplayName
 	}
-
-	_, err := db.Exec(`
-		INSERT INTO settings (key, value)
-		VALUES ($1, $2)
-		ON CONFLICT (key) DO UPDATE SET value = $2
-	`, key, value)
-	return err
+	return ""
 }
 
 // ==================== AUTH HANDLERS ====================
@@ -943,27 +663,22 @@ func handleDiscordCallback(w http.ResponseWriter, r *http.Request) {
 		avatar = fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png", discordID, av.(string))
 	}
 
-	// Check manager role
-	isManager := checkUserHasManagerRole(accessToken, discordID)
-
 	// Save/update user in database
-	if err := createOrUpdateUser(discordID, username, displayName, avatar, isManager); err != nil {
+	if err := store.SaveDiscordUser(User{
+		DiscordID:   discordID,
+		Username:    username,
+		DisplayName: displayName,
+		Avatar:      avatar,
+	}); err != nil {
 		log.Printf("Save user error: %v", err)
 	}
 
-	// Get existing user to check if they have a player linked
-	existingUser, _ := getUserByDiscordID(discordID)
-	playerID := ""
-	if existingUser != nil {
-		playerID = existingUser.PlayerID
-	}
+	refreshTeamRoles(accessToken, discordID)
 
 	// Create session
 	session := Session{
 		DiscordID: discordID,
 		Username:  username,
-		IsManager: isManager,
-		PlayerID:  playerID,
 		ExpiresAt: time.Now().Add(7 * 24 * time.Hour).Unix(),
 	}
 
@@ -971,6 +686,36 @@ func handleDiscordCallback(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 }
 
+// refreshTeamRoles adds the user to every team whose Discord server they
+// are in and refreshes their manager flag in each. Managers who have left
+// a team's server lose the flag but stay in the team.
+func refreshTeamRoles(accessToken, discordID string) {
+	teams, err := store.ListTeams()
+	if err != nil {
+		log.Printf("Error listing teams: %v", err)
+		return
+	}
+	memberships, _ := store.ListUserTeams(discordID)
+	wasManager := make(map[string]bool)
+	for _, m := range memberships {
+		wasManager[m.TeamID] = m.IsManager
+	}
+
+	for i, team := range teams {
+		guildID, managerRole := teamGuild(&team, i == 0)
+		if guildID == "" {
+			continue
+		}
+		inGuild, isManager := checkGuildMember(accessToken, guildID, managerRole)
+		if !inGuild && !wasManager[team.ID] {
+			continue
+		}
+		if err := store.SaveTeamUser(team.ID, discordID, isManager); err != nil {
+			log.Printf("Error saving %s in team %s: %v", discordID, team.ID, err)
+		}
+	}
+}
+
 func handleAuthMe(w http.ResponseWriter, r *http.Request) {
 	session := getSessionFromRequest(r)
 	if session == nil {
@@ -981,7 +726,11 @@ func handleAuthMe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get full user from DB
-	user, _ := getUserByDiscordID(session.DiscordID)
+	user, _ := store.GetUser("", session.DiscordID)
+	teams, _ := store.ListUserTeams(session.DiscordID)
+	if teams == nil {
+		teams = []TeamUser{}
+	}
 
 	response := map[string]interface{}{
 		"authenticated": true,
@@ -989,14 +738,16 @@ func handleAuthMe(w http.ResponseWriter, r *http.Request) {
 		"username":      session.Username,
 		"isManager":     session.IsManager,
 		"playerId":      session.PlayerID,
+		"teams":         teams,
 		"avatar":        getUserAvatar(user),
 		"displayName":   getUserDisplayName(user),
 	}
 
-	// Include email and phone if available
+	// Include email, phone and time zone if available
 	if user != nil {
 		response["email"] = user.Email
 		response["phone"] = user.Phone
+		response["timeZone"] = user.TimeZone
 	}
 
 	writeJSON(w, http.StatusOK, response)
@@ -1027,22 +778,35 @@ func handleLinkPlayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	member, err := getTeamMember(team.ID, body.PlayerID)
+	if err != nil || member == nil || member.DeletedAt != nil {
+		writeError(w, http.StatusBadRequest, "Unknown player")
+		return
+	}
+	body.PlayerID = member.ID
+
 	// Check if player is already linked
-	existingUser, _ := getUserByPlayerID(body.PlayerID)
+	existingUser, _ := store.GetUserByPlayerID(body.PlayerID)
 	if existingUser != nil && existingUser.DiscordID != session.DiscordID {
 		writeError(w, http.StatusConflict, "This player is already linked to another account")
 		return
 	}
 
+	before, _ := store.GetUser(team.ID, session.DiscordID)
+
 	// Link player
-	if err := linkUserToPlayer(session.DiscordID, body.PlayerID); err != nil {
+	if err := store.LinkPlayer(team.ID, session.DiscordID, body.PlayerID); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Update session cookie with new player ID
-	session.PlayerID = body.PlayerID
-	setSessionCookie(w, *session)
+	after, _ := store.GetUser(team.ID, session.DiscordID)
+	recordAudit(team.ID, session.DiscordID, "link_player", auditUser, session.DiscordID, before, after)
 
 	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
@@ -1057,66 +821,62 @@ func handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Email string `json:"email"`
 		Phone string `json:"phone"`
+		// TimeZone is left alone when missing; "" clears it
+		TimeZone *string `json:"timeZone"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
-
-	if db == nil {
-		writeError(w, http.StatusInternalServerError, "Database not connected")
-		return
+	if body.TimeZone != nil && *body.TimeZone != "" {
+		if _, err := loadUserTimeZone(*body.TimeZone); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
 
-	_, err := db.Exec(`UPDATE users SET email = $1, phone = $2 WHERE discord_id = $3`,
-		body.Email, body.Phone, session.DiscordID)
-	if err != nil {
+	before, _ := store.GetUser("", session.DiscordID)
+
+	if err := store.UpdateUserContact(session.DiscordID, body.Email, body.Phone); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to update account")
 		return
 	}
+	if body.TimeZone != nil {
+		if err := store.UpdateUserTimeZone(session.DiscordID, *body.TimeZone); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to update account")
+			return
+		}
+	}
+
+	after, _ := store.GetUser("", session.DiscordID)
+	recordAudit("", session.DiscordID, "update_contact", auditUser, session.DiscordID, before, after)
 
 	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
 func handleGetLinkedUsers(w http.ResponseWriter, r *http.Request) {
-	// Return a map of player_id -> {avatar, displayName}
-	if db == nil {
-		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
 		return
 	}
 
-	rows, err := db.Query(`
-		SELECT player_id, avatar, display_name, username
-		FROM users
-		WHERE player_id IS NOT NULL AND player_id != ''
-	`)
+	// Return a map of player_id -> {avatar, displayName}
+	users, err := store.ListLinkedUsers(team.ID)
 	if err != nil {
 		writeJSON(w, http.StatusOK, map[string]interface{}{})
 		return
 	}
-	defer rows.Close()
 
 	result := make(map[string]interface{})
-	for rows.Next() {
-		var playerID, avatar, displayName, username string
-		var avatarPtr, displayNamePtr *string
-
-		if err := rows.Scan(&playerID, &avatarPtr, &displayNamePtr, &username); err != nil {
-			continue
-		}
-
-		if avatarPtr != nil {
-			avatar = *avatarPtr
-		}
-		if displayNamePtr != nil {
-			displayName = *displayNamePtr
-		}
+	for _, u := range users {
+		displayName := u.DisplayName
 		if displayName == "" {
-			displayName = username
+			displayName = u.Username
 		}
 
-		result[playerID] = map[string]string{
-			"avatar":      avatar,
+		result[u.PlayerID] = map[string]string{
+			"avatar":      u.Avatar,
 			"displayName": displayName,
 		}
 	}
@@ -1126,25 +886,23 @@ func handleGetLinkedUsers(w http.ResponseWriter, r *http.Request) {
 
 // ==================== MEMBER HANDLERS ====================
 
-func getMembersFromDB() ([]Member, []Member, error) {
-	if db == nil {
-		return ActiveMembers, SubMembers, nil
-	}
+// memberKey is what member names and aliases are matched on within a team:
+// lower case with runs of spaces collapsed, so "GO Sean" and "go  sean" are
+// the same member but "GO_Sean" and "GOSean" are not.
+func memberKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
 
-	rows, err := db.Query("SELECT id, name, year, COALESCE(region, ''), COALESCE(note, ''), is_vet FROM members ORDER BY is_vet, sort_order, name")
+func getMembersFromDB(teamID string) ([]Member, []Member, error) {
+	members, err := store.ListMembers(teamID)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer rows.Close()
 
 	var active []Member
 	var subs []Member
 
-	for rows.Next() {
-		var m Member
-		if err := rows.Scan(&m.ID, &m.Name, &m.Year, &m.Region, &m.Note, &m.IsVet); err != nil {
-			continue
-		}
+	for _, m := range members {
 		if m.IsVet {
 			subs = append(subs, m)
 		} else {
@@ -1156,10 +914,16 @@ func getMembersFromDB() ([]Member, []Member, error) {
 }
 
 func handleGetMembers(w http.ResponseWriter, r *http.Request) {
-	linkedIDs, _ := getLinkedPlayerIDs()
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	linkedUsers, _ := store.ListLinkedUsers(team.ID)
 	linkedMap := make(map[string]bool)
-	for _, id := range linkedIDs {
-		linkedMap[id] = true
+	for _, u := range linkedUsers {
+		linkedMap[u.PlayerID] = true
 	}
 
 	type MemberWithStatus struct {
@@ -1167,15 +931,15 @@ func handleGetMembers(w http.ResponseWriter, r *http.Request) {
 		Linked bool `json:"linked"`
 	}
 
-	active, subs, err := getMembersFromDB()
+	active, subs, err := getMembersFromDB(team.ID)
 	if err != nil {
-		// Fallback to hardcoded
-		active = ActiveMembers
-		subs = SubMembers
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	var activeWithStatus []MemberWithStatus
-	var subsWithStatus []MemberWithStatus
+	// A new team has no members yet; send empty lists rather than null
+	activeWithStatus := []MemberWithStatus{}
+	subsWithStatus := []MemberWithStatus{}
 
 	for _, m := range active {
 		activeWithStatus = append(activeWithStatus, MemberWithStatus{Member: m, Linked: linkedMap[m.ID]})
@@ -1184,12 +948,42 @@ func handleGetMembers(w http.ResponseWriter, r *http.Request) {
 		subsWithStatus = append(subsWithStatus, MemberWithStatus{Member: m, Linked: linkedMap[m.ID]})
 	}
 
+	// Deleted members are left out of the pickers but still named on old rosters
+	former, _ := store.ListDeletedMembers(team.ID)
+	if former == nil {
+		former = []Member{}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"active": activeWithStatus,
 		"subs":   subsWithStatus,
+		"former": former,
 	})
 }
 
+// handleGetMember finds a member by ID or by any name they have gone by.
+func handleGetMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	memberID := vars["id"]
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	member, err := getTeamMember(team.ID, memberID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if member == nil {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, member)
+}
+
 func handleAddMember(w http.ResponseWriter, r *http.Request) {
 	session := getSessionFromRequest(r)
 	if session == nil || !session.IsManager {
@@ -1208,28 +1002,34 @@ func handleAddMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	input.Name = strings.TrimSpace(input.Name)
 	if input.Name == "" {
 		writeError(w, http.StatusBadRequest, "Name is required")
 		return
 	}
 
-	// Generate ID from name
-	id := strings.ToLower(strings.ReplaceAll(input.Name, " ", ""))
-	id = strings.ReplaceAll(id, "_", "")
-	id = strings.ReplaceAll(id, "-", "")
-
-	// Get max sort order
-	var maxOrder int
-	db.QueryRow("SELECT COALESCE(MAX(sort_order), 0) FROM members WHERE is_vet = $1", input.IsVet).Scan(&maxOrder)
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
 
-	_, err := db.Exec(`INSERT INTO members (id, name, year, region, is_vet, sort_order) VALUES ($1, $2, $3, $4, $5, $6)`,
-		id, input.Name, 2025, input.Region, input.IsVet, maxOrder+1)
+	// IDs never change, so renames don't break rosters, links or preferences
+	id := generateID("member")
 
+	member := Member{ID: id, TeamID: team.ID, Name: input.Name, Year: time.Now().Year(), Region: input.Region, IsVet: input.IsVet}
+	err = store.CreateMember(member)
+	if errors.Is(err, errDuplicateMember) {
+		writeError(w, http.StatusConflict, "Another member already goes or went by that name")
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to add member")
 		return
 	}
 
+	recordAudit(team.ID, session.DiscordID, "create", auditMember, id, nil, member)
+
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"id":     id,
 		"name":   input.Name,
@@ -1259,41 +1059,44 @@ func handleUpdateMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build update query dynamically
-	updates := []string{}
-	args := []interface{}{}
-	argNum := 1
-
-	if input.Name != nil {
-		updates = append(updates, fmt.Sprintf("name = $%d", argNum))
-		args = append(args, *input.Name)
-		argNum++
-	}
-	if input.IsVet != nil {
-		updates = append(updates, fmt.Sprintf("is_vet = $%d", argNum))
-		args = append(args, *input.IsVet)
-		argNum++
-	}
-	if input.Region != nil {
-		updates = append(updates, fmt.Sprintf("region = $%d", argNum))
-		args = append(args, *input.Region)
-		argNum++
-	}
-
-	if len(updates) == 0 {
+	if input.Name == nil && input.IsVet == nil && input.Region == nil {
 		writeError(w, http.StatusBadRequest, "No updates provided")
 		return
 	}
-
-	args = append(args, memberID)
-	query := fmt.Sprintf("UPDATE members SET %s WHERE id = $%d", strings.Join(updates, ", "), argNum)
-
-	_, err := db.Exec(query, args...)
-	if err != nil {
+	if input.Name != nil {
+		*input.Name = strings.TrimSpace(*input.Name)
+		if *input.Name == "" {
+			writeError(w, http.StatusBadRequest, "Name is required")
+			return
+		}
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	before, _ := getTeamMember(team.ID, memberID)
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+	memberID = before.ID
+
+	err = store.UpdateMember(memberID, MemberUpdate{Name: input.Name, IsVet: input.IsVet, Region: input.Region})
+	if errors.Is(err, errDuplicateMember) {
+		writeError(w, http.StatusConflict, "Another member already goes or went by that name")
+		return
+	}
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to update member")
 		return
 	}
 
+	after, _ := store.GetMember(memberID)
+	recordAudit(team.ID, session.DiscordID, "update", auditMember, memberID, before, after)
+
 	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
@@ -1307,13 +1110,46 @@ func handleDeleteMember(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	memberID := vars["id"]
 
-	_, err := db.Exec("DELETE FROM members WHERE id = $1", memberID)
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	before, _ := getTeamMember(team.ID, memberID)
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+	memberID = before.ID
+
+	// Snapshot the games so the audit log can show who was taken off them
+	gamesBefore := make(map[string]*Game)
+	if games, err := store.ListGames(team.ID, ""); err == nil {
+		for i := range games {
+			gamesBefore[games[i].ID] = &games[i]
+		}
+	}
+
+	gameIDs, err := store.DeleteMember(memberID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to delete member")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+	recordAudit(team.ID, session.DiscordID, "delete", auditMember, memberID, before, nil)
+	for _, gameID := range gameIDs {
+		after, _ := store.GetGame(gameID)
+		recordAudit(team.ID, session.DiscordID, "remove_member", auditGame, gameID, gamesBefore[gameID], after)
+	}
+
+	if gameIDs == nil {
+		gameIDs = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":          true,
+		"removedFromGames": gameIDs,
+	})
 }
 
 func handleUpdateMemberOrder(w http.ResponseWriter, r *http.Request) {
@@ -1333,43 +1169,250 @@ func handleUpdateMemberOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	members, err := store.ListMembers(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var before []string
+	inTeam := make(map[string]bool)
+	for _, m := range members {
+		inTeam[m.ID] = true
+		if m.IsVet == (input.Type == "subs") {
+			before = append(before, m.ID)
+		}
+	}
+	for _, id := range input.Order {
+		if !inTeam[id] {
+			writeError(w, http.StatusBadRequest, "Unknown member "+id)
+			return
+		}
+	}
+
 	// Update sort order for each member
-	for i, id := range input.Order {
-		db.Exec("UPDATE members SET sort_order = $1 WHERE id = $2", i, id)
+	if err := store.SetMemberOrder(input.Order); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update member order")
+		return
 	}
 
+	recordAudit(team.ID, session.DiscordID, "reorder", auditMember, input.Type, before, input.Order)
+
 	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
 // ==================== GAME HANDLERS ====================
 
 func handleGetAllData(w http.ResponseWriter, r *http.Request) {
-	games, err := getAllGames()
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+
+	var season *Season
+	if seasonID != "" {
+		if season, err = store.GetSeason(seasonID); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	// The page only wants every game in the season, but scripts can narrow
+	// it down like /api/games
+	filter, err := parseGameFilter(r, team.ID, seasonID, 0)
+	if err != nil {
+		writeGameFilterError(w, err)
+		return
+	}
+	page, err := findGamePage(filter)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	localizeForViewer(r, page.Games)
 
-	prefs, err := getAllPreferences()
+	prefs, err := store.GetPreferences(team.ID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	writeJSON(w, http.StatusOK, AllData{
-		Games:             games,
+		Team:              team,
+		Season:            season,
+		Games:             page.Games,
+		TotalGames:        page.Total,
+		NextCursor:        page.NextCursor,
 		PlayerPreferences: prefs,
 		DiscordWebhook:    "",
 	})
 }
 
+// handleGetGames lists the season's games a page at a time, narrowed by the
+// from, to, league, division, mode, opponent, player, participation and
+// status query parameters. Pass nextCursor back as cursor for the next page.
 func handleGetGames(w http.ResponseWriter, r *http.Request) {
-	games, err := getAllGames()
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+
+	filter, err := parseGameFilter(r, team.ID, seasonID, defaultGameLimit)
+	if err != nil {
+		writeGameFilterError(w, err)
+		return
+	}
+	page, err := findGamePage(filter)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, games)
+	localizeForViewer(r, page.Games)
+	writeJSON(w, http.StatusOK, page)
+}
+
+func handleGetGame(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	game, err := getTeamGame(team.ID, gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	if r.Header.Get("If-None-Match") == gameETag(game) {
+		w.Header().Set("ETag", gameETag(game))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeGame(w, r, http.StatusOK, game)
+}
+
+// gameInput is the body of a game create or update.
+type gameInput struct {
+	StartsAt string `json:"startsAt"`
+	TimeZone string `json:"timeZone"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+	Opponent string `json:"opponent"`
+	// The opponent, league and division can be picked by ID or, as older
+	// clients do, by name or alias
+	OpponentID string `json:"opponentId"`
+	LeagueID   string `json:"leagueId"`
+	DivisionID string `json:"divisionId"`
+	League     string `json:"league"`
+	Division   string `json:"division"`
+	GameMode   string `json:"gameMode"`
+	TeamSize   int    `json:"teamSize"`
+	Notes      string `json:"notes"`
+}
+
+// finishDraftGame readies a game from draftGame for saving: it creates the
+// game's opponent if it is new, audited as actor's, and gives the game an
+// ID.
+func finishDraftGame(actor string, game *Game) error {
+	opponent, err := saveGameOpponent(actor, game.TeamID, &Opponent{ID: game.OpponentID, Name: game.Opponent})
+	if err != nil {
+		return err
+	}
+	game.Opponent, game.OpponentID = opponent.Name, opponent.ID
+	game.ID = generateGameID()
+	return nil
+}
+
+// draftGame builds a game in the team's active season without writing
+// anything: the game has no ID yet, and an opponent that doesn't exist is
+// left as a name with no OpponentID. Settings the body leaves out come from
+// the division, then the league. Unknown leagues and opponents fail with
+// errInvalidLeague and errInvalidOpponent.
+func draftGame(teamID string, body gameInput, startsAt time.Time, timeZone string) (Game, error) {
+	seasonID, err := activeSeasonID(teamID)
+	if err != nil {
+		return Game{}, err
+	}
+
+	league, division, err := resolveGameLeague(seasonID, firstNonEmpty(body.LeagueID, body.League), firstNonEmpty(body.DivisionID, body.Division))
+	if err != nil {
+		return Game{}, err
+	}
+
+	opponent, err := draftGameOpponent(body.OpponentID, body.Opponent)
+	if err != nil {
+		return Game{}, err
+	}
+
+	gameMode, teamSize := gameSettings(body, league, division)
+	game := Game{
+		StartsAt:   startsAt,
+		TimeZone:   timeZone,
+		Opponent:   opponent.Name,
+		OpponentID: opponent.ID,
+		GameMode:   gameMode,
+		TeamSize:   teamSize,
+		Notes:      body.Notes,
+		TeamID:     teamID,
+		SeasonID:   seasonID,
+	}
+	if division != nil {
+		game.DivisionID = division.ID
+	}
+	if league != nil {
+		game.LeagueID = league.ID
+	}
+	return game, nil
+}
+
+// gameSettings resolves a game's mode and team size: the body's, and for
+// what it leaves out the division's defaults, then the league's.
+func gameSettings(body gameInput, league *League, division *Division) (gameMode string, teamSize int) {
+	gameMode, teamSize = body.GameMode, body.TeamSize
+	if division != nil {
+		gameMode = firstNonEmpty(gameMode, division.DefaultGameMode)
+		if teamSize <= 0 {
+			teamSize = division.DefaultTeamSize
+		}
+	}
+	if league != nil {
+		gameMode = firstNonEmpty(gameMode, league.DefaultGameMode)
+		if teamSize <= 0 {
+			teamSize = league.DefaultTeamSize
+		}
+	}
+	if teamSize <= 0 {
+		teamSize = 10
+	}
+	return firstNonEmpty(gameMode, "War"), teamSize
 }
 
 func handleCreateGame(w http.ResponseWriter, r *http.Request) {
@@ -1380,34 +1423,69 @@ func handleCreateGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body struct {
-		Date     string `json:"date"`
-		Time     string `json:"time"`
-		Opponent string `json:"opponent"`
-		League   string `json:"league"`
-		Division string `json:"division"`
-		GameMode string `json:"gameMode"`
-		TeamSize int    `json:"teamSize"`
-		Notes    string `json:"notes"`
-	}
-
+	var body gameInput
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	if body.Date == "" || body.Time == "" || body.Opponent == "" {
+	if (body.StartsAt == "" && (body.Date == "" || body.Time == "")) || (body.Opponent == "" && body.OpponentID == "") {
 		writeError(w, http.StatusBadRequest, "Missing required fields")
 		return
 	}
 
-	game, err := createGame(body.Date, body.Time, body.Opponent, body.League, body.Division, body.GameMode, body.TeamSize, body.Notes)
+	startsAt, timeZone, err := resolveGameStart(body.StartsAt, body.Date, body.Time, body.TimeZone)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	// Nothing is written, a new opponent included, until the game has
+	// passed the conflict check
+	game, err := draftGame(team.ID, body, startsAt, timeZone)
+	if errors.Is(err, errInvalidLeague) || errors.Is(err, errInvalidOpponent) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	conflicts, err := findGameConflicts(&game, nil, true)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if len(conflicts) > 0 && !forceRequested(r) {
+		writeSchedulingConflict(w, conflicts)
+		return
+	}
 
-	writeJSON(w, http.StatusCreated, game)
+	if err := finishDraftGame(session.DiscordID, &game); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i := range conflicts {
+		conflicts[i].GameID = game.ID
+	}
+
+	created, err := store.CreateGame(game)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "create", auditGame, created.ID, nil, created)
+
+	created.Conflicts = conflicts
+	writeGame(w, r, http.StatusCreated, created)
 }
 
 func handleDeleteGame(w http.ResponseWriter, r *http.Request) {
@@ -1421,10 +1499,60 @@ func handleDeleteGame(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	if err := deleteGame(gameID); err != nil {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	before, err := getTeamGame(team.ID, gameID)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	scope, err := requestedScope(r, before)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Games in a series also change the series, so it won't bring them back
+	if before.SeriesID != "" {
+		deleted, err := deleteSeriesGame(session.DiscordID, before, scope, version)
+		if errors.Is(err, errVersionConflict) {
+			writeVersionConflict(w, gameID)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "deleted": deleted})
+		return
+	}
+
+	err = store.DeleteGame(gameID, version)
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, gameID)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "delete", auditGame, gameID, before, nil)
 
 	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
@@ -1440,49 +1568,151 @@ func handleUpdateGame(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	var body struct {
-		Date     string `json:"date"`
-		Time     string `json:"time"`
-		Opponent string `json:"opponent"`
-		League   string `json:"league"`
-		Division string `json:"division"`
-		GameMode string `json:"gameMode"`
-		TeamSize int    `json:"teamSize"`
-		Notes    string `json:"notes"`
-	}
-
+	var body gameInput
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
 	// Validate required fields
-	if body.Date == "" || body.Time == "" || body.Opponent == "" {
+	if (body.StartsAt == "" && (body.Date == "" || body.Time == "")) || (body.Opponent == "" && body.OpponentID == "") {
 		writeError(w, http.StatusBadRequest, "Date, time, and opponent are required")
 		return
 	}
 
-	// Update the game in database
-	_, err := db.Exec(`
-		UPDATE games SET date = $1, time = $2, opponent = $3, league = $4,
-		division = $5, game_mode = $6, team_size = $7, notes = $8
-		WHERE id = $9
-	`, body.Date, body.Time, body.Opponent, body.League, body.Division,
-		body.GameMode, body.TeamSize, body.Notes, gameID)
+	startsAt, timeZone, err := resolveGameStart(body.StartsAt, body.Date, body.Time, body.TimeZone)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	before, err := getTeamGame(team.ID, gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	// A game in a series can take the rest of the series with it
+	scope, err := requestedScope(r, before)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	league, division, err := resolveGameLeague(before.SeasonID, firstNonEmpty(body.LeagueID, body.League), firstNonEmpty(body.DivisionID, body.Division))
+	if errors.Is(err, errInvalidLeague) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	var leagueID, divisionID string
+	if league != nil {
+		leagueID = league.ID
+	}
+	if division != nil {
+		divisionID = division.ID
+	}
 
-	// Return updated game
-	game, err := getGameByID(gameID)
+	opponent, err := draftGameOpponent(body.OpponentID, body.Opponent)
+	if errors.Is(err, errInvalidOpponent) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, game)
+	gameMode, teamSize := gameSettings(body, league, division)
+	patch := GamePatch{
+		StartsAt:   &startsAt,
+		TimeZone:   &timeZone,
+		Opponent:   &opponent.Name,
+		OpponentID: &opponent.ID,
+		LeagueID:   &leagueID,
+		DivisionID: &divisionID,
+		GameMode:   &gameMode,
+		TeamSize:   &teamSize,
+		Notes:      &body.Notes,
+	}
+
+	// A game moved, or made longer by a new mode, can run into others, and
+	// its players into their other games; so can the rest of the series
+	// games in scope, which move with it
+	var conflicts []Conflict
+	if !startsAt.Equal(before.StartsAt) || timeZone != before.TimeZone || gameMode != before.GameMode {
+		moved := *before
+		moved.StartsAt, moved.TimeZone, moved.GameMode = startsAt, timeZone, gameMode
+		games := []Game{moved}
+		if scope != scopeThis {
+			others, err := patchedSeriesGames(before, patch, scope)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			games = append(games, others...)
+		}
+		conflicts, err = findGamesConflicts(games)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(conflicts) > 0 && !forceRequested(r) {
+			writeSchedulingConflict(w, conflicts)
+			return
+		}
+	}
+
+	// A new opponent is only created once the game is going to change
+	if opponent, err = saveGameOpponent(session.DiscordID, team.ID, opponent); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	patch.Opponent, patch.OpponentID = &opponent.Name, &opponent.ID
+
+	// Update the game and return it
+	var game *Game
+	if scope == scopeThis {
+		game, err = store.UpdateGame(gameID, patch, session.DiscordID, version)
+	} else {
+		game, err = updateSeriesGame(session.DiscordID, before, patch, scope, version)
+	}
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, gameID)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditGame, gameID, before, game)
+
+	game.Conflicts = conflicts
+	writeGame(w, r, http.StatusOK, game)
 }
 
 func handleUpdateRoster(w http.ResponseWriter, r *http.Request) {
@@ -1496,7 +1726,19 @@ func handleUpdateRoster(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	game, err := getGameByID(gameID)
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	game, err := getTeamGame(team.ID, gameID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -1521,30 +1763,68 @@ func handleUpdateRoster(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updates := map[string]interface{}{"roster": body.Roster}
+	// Players just added may already be rostered for a game at that time
+	conflicts, err := findGameConflicts(game, addedMembers(game.Roster, body.Roster), false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(conflicts) > 0 && !forceRequested(r) {
+		writeSchedulingConflict(w, conflicts)
+		return
+	}
+
+	patch := GamePatch{Roster: &body.Roster}
 	if body.Subs != nil {
-		updates["subs"] = body.Subs
+		patch.Subs = &body.Subs
 	}
 
 	// Clear withdrawals if roster is now full (subs have been assigned)
 	if len(body.Roster) >= game.TeamSize {
-		updates["withdrawals"] = []string{}
+		patch.Withdrawals = &[]string{}
 	}
 
-	updated, err := updateGame(gameID, updates)
+	updated, err := store.UpdateGame(gameID, patch, session.DiscordID, version)
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, gameID)
+		return
+	}
+	if errors.Is(err, errUnknownMember) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if updated == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
 
-	writeJSON(w, http.StatusOK, updated)
+	recordAudit(team.ID, session.DiscordID, "update_roster", auditGame, gameID, game, updated)
+
+	updated.Conflicts = conflicts
+	writeGame(w, r, http.StatusOK, updated)
 }
 
 func handleSetAvailability(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	game, err := getGameByID(gameID)
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	game, err := getTeamGame(team.ID, gameID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -1575,35 +1855,39 @@ func handleSetAvailability(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	available := make([]string, 0)
-	unavailable := make([]string, 0)
-	for _, p := range game.Available {
-		if p != body.PlayerID {
-			available = append(available, p)
-		}
-	}
-	for _, p := range game.Unavailable {
-		if p != body.PlayerID {
-			unavailable = append(unavailable, p)
-		}
+	status := statusUnavailable
+	if body.IsAvailable {
+		status = statusAvailable
 	}
 
-	if body.IsAvailable {
-		available = append(available, body.PlayerID)
-	} else {
-		unavailable = append(unavailable, body.PlayerID)
+	actor := ""
+	if session != nil {
+		actor = session.DiscordID
 	}
 
-	updated, err := updateGame(gameID, map[string]interface{}{
-		"available":   available,
-		"unavailable": unavailable,
-	})
+	// Only this player's row changes, so a concurrent update for another
+	// player can't be lost the way rewriting the whole list could.
+	updated, err := store.SetAvailability(gameID, body.PlayerID, status, actor, version)
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, gameID)
+		return
+	}
+	if errors.Is(err, errUnknownMember) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if updated == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	recordAudit(team.ID, actor, "set_availability", auditGame, gameID, game, updated)
 
-	writeJSON(w, http.StatusOK, updated)
+	writeGame(w, r, http.StatusOK, updated)
 }
 
 func handleWithdrawFromRoster(w http.ResponseWriter, r *http.Request) {
@@ -1616,7 +1900,19 @@ func handleWithdrawFromRoster(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	game, err := getGameByID(gameID)
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	game, err := getTeamGame(team.ID, gameID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -1626,101 +1922,52 @@ func handleWithdrawFromRoster(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if player is on roster
-	isOnRoster := false
-	newRoster := make([]string, 0)
-	for _, p := range game.Roster {
-		if p == session.PlayerID {
-			isOnRoster = true
-		} else {
-			newRoster = append(newRoster, p)
-		}
+	// Moves the player off the roster, onto withdrawals and unavailable in
+	// one step; it fails if they are no longer on the roster by then.
+	updated, err := store.WithdrawFromRoster(gameID, session.PlayerID, session.DiscordID, version)
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, gameID)
+		return
 	}
-
-	if !isOnRoster {
+	if errors.Is(err, errNotOnRoster) {
 		writeError(w, http.StatusBadRequest, "You are not on this roster")
 		return
 	}
-
-	// Add to withdrawals list (if not already there)
-	withdrawals := game.Withdrawals
-	alreadyWithdrawn := false
-	for _, w := range withdrawals {
-		if w == session.PlayerID {
-			alreadyWithdrawn = true
-			break
-		}
-	}
-	if !alreadyWithdrawn {
-		withdrawals = append(withdrawals, session.PlayerID)
-	}
-
-	// Also mark as unavailable
-	unavailable := game.Unavailable
-	isAlreadyUnavailable := false
-	for _, p := range unavailable {
-		if p == session.PlayerID {
-			isAlreadyUnavailable = true
-			break
-		}
-	}
-	if !isAlreadyUnavailable {
-		unavailable = append(unavailable, session.PlayerID)
-	}
-
-	// Remove from available
-	available := make([]string, 0)
-	for _, p := range game.Available {
-		if p != session.PlayerID {
-			available = append(available, p)
-		}
-	}
-
-	updated, err := updateGame(gameID, map[string]interface{}{
-		"roster":      newRoster,
-		"withdrawals": withdrawals,
-		"available":   available,
-		"unavailable": unavailable,
-	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if updated == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "withdraw", auditGame, gameID, game, updated)
 
 	// Send notification to managers via Discord
-	go notifyManagersOfWithdrawal(game, session.PlayerID)
+	go notifyManagersOfWithdrawal(updated, session.PlayerID)
 
-	writeJSON(w, http.StatusOK, updated)
+	writeGame(w, r, http.StatusOK, updated)
 }
 
 func notifyManagersOfWithdrawal(game *Game, playerID string) {
-	if discordBotToken == "" || discordGuildID == "" {
+	if discordBotToken == "" {
 		return
 	}
 
 	playerName := getMemberName(playerID)
 
-	// Get all managers from users table
-	if db == nil {
-		return
-	}
-
-	rows, err := db.Query(`SELECT discord_id FROM users WHERE is_manager = true`)
+	managers, err := store.ListManagers(game.TeamID)
 	if err != nil {
 		log.Printf("Error fetching managers for withdrawal notification: %v", err)
 		return
 	}
-	defer rows.Close()
 
-	message := fmt.Sprintf("⚠️ **Sub Needed**\n\n**%s** needs a sub for:\n📅 %s at %s\n⚔️ vs %s\n\nPlease find a replacement.",
-		playerName, game.Date, game.Time, game.Opponent)
+	message := fmt.Sprintf("⚠️ **Sub Needed**\n\n**%s** needs a sub for:\n📅 %s\n⚔️ vs %s\n\nPlease find a replacement.",
+		playerName, discordGameTime(game), game.Opponent)
 
-	for rows.Next() {
-		var discordID string
-		if err := rows.Scan(&discordID); err != nil {
-			continue
-		}
-		sendDiscordDM(discordID, message)
+	for _, m := range managers {
+		sendDiscordDM(m.DiscordID, message)
 	}
 }
 
@@ -1768,7 +2015,13 @@ func sendDiscordDM(userID, message string) error {
 }
 
 func handleGetPreferences(w http.ResponseWriter, r *http.Request) {
-	prefs, err := getAllPreferences()
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	prefs, err := store.GetPreferences(team.ID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -1800,11 +2053,30 @@ func handleSetPreference(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := setPreference(playerID, body.Preference); err != nil {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	var before interface{}
+	if prefs, err := store.GetPreferences(team.ID); err == nil {
+		if pref, ok := prefs[playerID]; ok {
+			before = pref
+		}
+	}
+
+	if err := store.SetPreference(playerID, body.Preference); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	actor := ""
+	if session != nil {
+		actor = session.DiscordID
+	}
+	recordAudit(team.ID, actor, "set_preference", auditPreference, playerID, before, body.Preference)
+
 	writeJSON(w, http.StatusOK, map[string]string{
 		"player_id":  playerID,
 		"preference": body.Preference,
@@ -1812,7 +2084,13 @@ func handleSetPreference(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleGetWebhook(w http.ResponseWriter, r *http.Request) {
-	webhook, _ := getSetting("discord_webhook")
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	webhook, _ := store.GetSetting(teamSettingKey(team.ID, "discord_webhook"))
 	response := map[string]interface{}{
 		"configured": webhook != "",
 	}
@@ -1844,148 +2122,23 @@ func handleSetWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := setSetting("discord_webhook", body.Webhook); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
-}
-
-// ==================== LEAGUES & DIVISIONS ====================
-
-func getListSetting(key string) []string {
-	value, _ := getSetting(key)
-	if value == "" {
-		return []string{}
-	}
-	var list []string
-	json.Unmarshal([]byte(value), &list)
-	return list
-}
-
-func setListSetting(key string, list []string) error {
-	data, _ := json.Marshal(list)
-	return setSetting(key, string(data))
-}
-
-func handleGetLeagues(w http.ResponseWriter, r *http.Request) {
-	leagues := getListSetting("leagues")
-	writeJSON(w, http.StatusOK, leagues)
-}
-
-func handleAddLeague(w http.ResponseWriter, r *http.Request) {
-	session := getSessionFromRequest(r)
-	if session == nil || !session.IsManager {
-		writeError(w, http.StatusForbidden, "Manager access required")
-		return
-	}
-
-	var body struct {
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
-		writeError(w, http.StatusBadRequest, "Name is required")
-		return
-	}
-
-	leagues := getListSetting("leagues")
-	// Check if already exists
-	for _, l := range leagues {
-		if l == body.Name {
-			writeError(w, http.StatusConflict, "League already exists")
-			return
-		}
-	}
-	leagues = append(leagues, body.Name)
-	if err := setListSetting("leagues", leagues); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	writeJSON(w, http.StatusOK, leagues)
-}
-
-func handleDeleteLeague(w http.ResponseWriter, r *http.Request) {
-	session := getSessionFromRequest(r)
-	if session == nil || !session.IsManager {
-		writeError(w, http.StatusForbidden, "Manager access required")
-		return
-	}
-
-	vars := mux.Vars(r)
-	name := vars["name"]
-
-	leagues := getListSetting("leagues")
-	newLeagues := []string{}
-	for _, l := range leagues {
-		if l != name {
-			newLeagues = append(newLeagues, l)
-		}
-	}
-	if err := setListSetting("leagues", newLeagues); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	writeJSON(w, http.StatusOK, newLeagues)
-}
-
-func handleGetDivisions(w http.ResponseWriter, r *http.Request) {
-	divisions := getListSetting("divisions")
-	writeJSON(w, http.StatusOK, divisions)
-}
-
-func handleAddDivision(w http.ResponseWriter, r *http.Request) {
-	session := getSessionFromRequest(r)
-	if session == nil || !session.IsManager {
-		writeError(w, http.StatusForbidden, "Manager access required")
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
 		return
 	}
+	key := teamSettingKey(team.ID, "discord_webhook")
 
-	var body struct {
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
-		writeError(w, http.StatusBadRequest, "Name is required")
-		return
-	}
+	before, _ := store.GetSetting(key)
 
-	divisions := getListSetting("divisions")
-	for _, d := range divisions {
-		if d == body.Name {
-			writeError(w, http.StatusConflict, "Division already exists")
-			return
-		}
-	}
-	divisions = append(divisions, body.Name)
-	if err := setListSetting("divisions", divisions); err != nil {
+	if err := store.SetSetting(key, body.Webhook); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, divisions)
-}
-
-func handleDeleteDivision(w http.ResponseWriter, r *http.Request) {
-	session := getSessionFromRequest(r)
-	if session == nil || !session.IsManager {
-		writeError(w, http.StatusForbidden, "Manager access required")
-		return
-	}
 
-	vars := mux.Vars(r)
-	name := vars["name"]
+	recordAudit(team.ID, session.DiscordID, "update", auditSetting, key, maskSecret(before), maskSecret(body.Webhook))
 
-	divisions := getListSetting("divisions")
-	newDivisions := []string{}
-	for _, d := range divisions {
-		if d != name {
-			newDivisions = append(newDivisions, d)
-		}
-	}
-	if err := setListSetting("divisions", newDivisions); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	writeJSON(w, http.StatusOK, newDivisions)
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
 func handlePostToDiscord(w http.ResponseWriter, r *http.Request) {
@@ -2000,13 +2153,19 @@ func handlePostToDiscord(w http.ResponseWriter, r *http.Request) {
 	gameID := vars["id"]
 	mentionPlayers := r.URL.Query().Get("mention") == "true"
 
-	webhook, _ := getSetting("discord_webhook")
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	webhook, _ := store.GetSetting(teamSettingKey(team.ID, "discord_webhook"))
 	if webhook == "" {
 		writeError(w, http.StatusBadRequest, "Discord webhook not configured")
 		return
 	}
 
-	game, err := getGameByID(gameID)
+	game, err := getTeamGame(team.ID, gameID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -2016,25 +2175,9 @@ func handlePostToDiscord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	formattedDate := game.Date
-	if t, err := time.Parse("2006-01-02", game.Date); err == nil {
-		formattedDate = t.Format("Monday, Jan 02, 2006")
-	}
-
-	formattedTime := game.Time
-	if parts := strings.Split(game.Time, ":"); len(parts) >= 2 {
-		if hour, err := strconv.Atoi(parts[0]); err == nil {
-			ampm := "AM"
-			if hour >= 12 {
-				ampm = "PM"
-			}
-			hour12 := hour % 12
-			if hour12 == 0 {
-				hour12 = 12
-			}
-			formattedTime = fmt.Sprintf("%d:%s %s ET", hour12, parts[1], ampm)
-		}
-	}
+	// Discord shows the start in each reader's own time zone; titles don't
+	// render timestamps, so the date goes in the time field
+	gameTime := discordGameTime(game)
 
 	var rosterNames []string
 	var mentionString string
@@ -2055,22 +2198,18 @@ func handlePostToDiscord(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build link for players to withdraw/request sub
-	siteURL := baseURL
-	if siteURL == "" {
-		siteURL = "https://go-pop1-calendar.onrender.com"
-	}
-	gameLink := siteURL + "/?game=" + gameID
+	gameLink := teamGameLink(team, gameID)
 
 	embed := map[string]interface{}{
-		"title": fmt.Sprintf("🎮 Game Day: %s", formattedDate),
+		"title": "🎮 Game Day",
 		"color": 0x00f0ff,
 		"fields": []map[string]interface{}{
-			{"name": "⏰ Time", "value": formattedTime, "inline": true},
+			{"name": "⏰ Time", "value": gameTime, "inline": true},
 			{"name": "⚔️ Opponent", "value": game.Opponent, "inline": true},
 			{"name": fmt.Sprintf("👥 Roster (%d/10)", len(rosterNames)), "value": rosterValue, "inline": false},
 			{"name": "🔗 Can't Make It?", "value": fmt.Sprintf("[Click here to request a sub](%s)", gameLink), "inline": false},
 		},
-		"footer":    map[string]string{"text": "Game Over Pop1 War Team"},
+		"footer":    map[string]string{"text": team.Name},
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	}
 
@@ -2122,13 +2261,19 @@ func handleAnnounceGame(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	webhook, _ := getSetting("discord_webhook")
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	webhook, _ := store.GetSetting(teamSettingKey(team.ID, "discord_webhook"))
 	if webhook == "" {
 		writeError(w, http.StatusBadRequest, "Discord webhook not configured")
 		return
 	}
 
-	game, err := getGameByID(gameID)
+	game, err := getTeamGame(team.ID, gameID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -2138,32 +2283,10 @@ func handleAnnounceGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	formattedDate := game.Date
-	if t, err := time.Parse("2006-01-02", game.Date); err == nil {
-		formattedDate = t.Format("Monday, Jan 02, 2006")
-	}
-
-	formattedTime := game.Time
-	if parts := strings.Split(game.Time, ":"); len(parts) >= 2 {
-		if hour, err := strconv.Atoi(parts[0]); err == nil {
-			ampm := "AM"
-			if hour >= 12 {
-				ampm = "PM"
-			}
-			hour12 := hour % 12
-			if hour12 == 0 {
-				hour12 = 12
-			}
-			formattedTime = fmt.Sprintf("%d:%s %s ET", hour12, parts[1], ampm)
-		}
-	}
+	gameTime := discordGameTime(game)
 
 	// Build link for players to mark availability
-	siteURL := baseURL
-	if siteURL == "" {
-		siteURL = "https://go-pop1-calendar.onrender.com"
-	}
-	gameLink := siteURL + "/?game=" + gameID
+	gameLink := teamGameLink(team, gameID)
 
 	gameMode := game.GameMode
 	if gameMode == "" {
@@ -2171,16 +2294,16 @@ func handleAnnounceGame(w http.ResponseWriter, r *http.Request) {
 	}
 
 	embed := map[string]interface{}{
-		"title":       fmt.Sprintf("📢 Game Scheduled: %s", formattedDate),
+		"title":       "📢 Game Scheduled",
 		"description": "A new game has been scheduled! Please mark your availability.",
 		"color":       0xf59e0b, // Orange/warning color
 		"fields": []map[string]interface{}{
-			{"name": "⏰ Time", "value": formattedTime, "inline": true},
+			{"name": "⏰ Time", "value": gameTime, "inline": true},
 			{"name": "⚔️ Opponent", "value": game.Opponent, "inline": true},
 			{"name": "🎮 Game Mode", "value": gameMode, "inline": true},
 			{"name": "✅ Mark Availability", "value": fmt.Sprintf("[Click here to mark if you can play](%s)", gameLink), "inline": false},
 		},
-		"footer":    map[string]string{"text": "Game Over Pop1 War Team • Please respond ASAP!"},
+		"footer":    map[string]string{"text": team.Name + " • Please respond ASAP!"},
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	}
 
@@ -2249,7 +2372,13 @@ func handleTestWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	webhook, _ := getSetting("discord_webhook")
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	webhook, _ := store.GetSetting(teamSettingKey(team.ID, "discord_webhook"))
 	if webhook == "" {
 		writeError(w, http.StatusBadRequest, "Discord webhook not configured. Please set up a webhook first.")
 		return
@@ -2259,7 +2388,7 @@ func handleTestWebhook(w http.ResponseWriter, r *http.Request) {
 		"title":       "🧪 Test Post from GO Calendar",
 		"description": "This is a test message. If you're seeing this, your webhook is configured correctly!",
 		"color":       0x00f0ff,
-		"footer":      map[string]string{"text": "Game Over Pop1 War Team"},
+		"footer":      map[string]string{"text": team.Name},
 		"timestamp":   time.Now().UTC().Format(time.RFC3339),
 	}
 
@@ -2303,29 +2432,25 @@ func handleTestSubNotification(w http.ResponseWriter, r *http.Request) {
 		playerName = getMemberName(session.PlayerID)
 	}
 
-	message := fmt.Sprintf("🧪 **Test Sub Notification**\n\nThis is a test of the 'Need a Sub' alert system.\n\n**%s** would need a sub for:\n📅 Tomorrow at 9:00 PM ET\n⚔️ vs Test Opponent\n\nIf you received this, manager notifications are working!",
-		playerName)
+	message := fmt.Sprintf("🧪 **Test Sub Notification**\n\nThis is a test of the 'Need a Sub' alert system.\n\n**%s** would need a sub for:\n📅 %s\n⚔️ vs Test Opponent\n\nIf you received this, manager notifications are working!",
+		playerName, discordTimestamp(time.Now().Add(24*time.Hour), 'F'))
 
-	// Get all managers and send DM
-	if db == nil {
-		writeError(w, http.StatusInternalServerError, "Database not connected")
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
 		return
 	}
 
-	rows, err := db.Query(`SELECT discord_id FROM users WHERE is_manager = true`)
+	// Get the team's managers and send DM
+	managers, err := store.ListManagers(team.ID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to fetch managers: "+err.Error())
 		return
 	}
-	defer rows.Close()
 
 	sentCount := 0
-	for rows.Next() {
-		var discordID string
-		if err := rows.Scan(&discordID); err != nil {
-			continue
-		}
-		if err := sendDiscordDM(discordID, message); err == nil {
+	for _, m := range managers {
+		if err := sendDiscordDM(m.DiscordID, message); err == nil {
 			sentCount++
 		}
 	}
@@ -2340,45 +2465,15 @@ func handleTestSubNotification(w http.ResponseWriter, r *http.Request) {
 
 func handleGetPendingReminders(w http.ResponseWriter, r *http.Request) {
 	// This endpoint is for the cron job - should be called internally
-	if db == nil {
-		writeJSON(w, http.StatusOK, []Game{})
-		return
-	}
 
-	// Get tomorrow's date
-	tomorrow := time.Now().Add(24 * time.Hour).Format("2006-01-02")
+	// Games starting within the next 24 hours
+	now := time.Now().UTC()
 
-	rows, err := db.Query(`
-		SELECT id, date, time, opponent, COALESCE(league, ''), COALESCE(division, ''),
-		COALESCE(game_mode, 'War'), COALESCE(team_size, 10), notes, available, unavailable, roster,
-		COALESCE(subs, '[]'), COALESCE(withdrawals, '[]'), COALESCE(reminded, false)
-		FROM games
-		WHERE date = $1 AND (reminded = false OR reminded IS NULL) AND roster != '[]'
-	`, tomorrow)
+	games, err := store.ListPendingReminders(now, now.Add(24*time.Hour))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	var games []Game
-	for rows.Next() {
-		var g Game
-		var available, unavailable, roster, subs, withdrawals string
-		if err := rows.Scan(&g.ID, &g.Date, &g.Time, &g.Opponent, &g.League, &g.Division, &g.GameMode, &g.TeamSize, &g.Notes, &available, &unavailable, &roster, &subs, &withdrawals, &g.Reminded); err != nil {
-			continue
-		}
-		g.Available = parseJSONArray(available)
-		g.Unavailable = parseJSONArray(unavailable)
-		g.Roster = parseJSONArray(roster)
-		g.Subs = parseJSONArray(subs)
-		g.Withdrawals = parseJSONArray(withdrawals)
-		games = append(games, g)
-	}
-
-	if games == nil {
-		games = []Game{}
-	}
 
 	writeJSON(w, http.StatusOK, games)
 }
@@ -2387,11 +2482,19 @@ func handleMarkReminded(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	if _, err := updateGame(gameID, map[string]interface{}{"reminded": true}); err != nil {
+	before, _ := store.GetGame(gameID)
+
+	reminded := true
+	updated, err := store.UpdateGame(gameID, GamePatch{Reminded: &reminded}, "", 0)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if updated != nil {
+		recordAudit(updated.TeamID, auditSystemActor, "mark_reminded", auditGame, gameID, before, updated)
+	}
+
 	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
@@ -2399,7 +2502,7 @@ func handleGetUserDiscordID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	playerID := vars["playerId"]
 
-	user, err := getUserByPlayerID(playerID)
+	user, err := store.GetUserByPlayerID(playerID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -2438,18 +2541,28 @@ func securityHeaders(next http.Handler) http.Handler {
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
+	// `server migrate status|up|down` manages the schema without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
 	// Re-read env vars in case they weren't set at init
 	discordClientID = os.Getenv("DISCORD_CLIENT_ID")
 	discordClientSecret = os.Getenv("DISCORD_CLIENT_SECRET")
 	discordBotToken = os.Getenv("DISCORD_BOT_TOKEN")
 	discordGuildID = os.Getenv("DISCORD_GUILD_ID")
 	discordManagerRole = os.Getenv("DISCORD_MANAGER_ROLE")
+	adminDiscordIDs = os.Getenv("ADMIN_DISCORD_IDS")
 	sessionSecret = os.Getenv("SESSION_SECRET")
 	baseURL = os.Getenv("BASE_URL")
 
-	if err := initDB(); err != nil {
-		log.Printf("Warning: %v", err)
+	if err := initStore(); err != nil {
+		log.Fatalf("Storage initialization failed: %v", err)
 	}
+	go runTrashPurge()
 
 	r := mux.NewRouter()
 
@@ -2465,27 +2578,73 @@ func main() {
 	r.HandleFunc("/api/data", handleGetAllData).Methods("GET")
 	r.HandleFunc("/api/members", handleGetMembers).Methods("GET")
 	r.HandleFunc("/api/members", handleAddMember).Methods("POST")
+	r.HandleFunc("/api/members/{id}", handleGetMember).Methods("GET")
 	r.HandleFunc("/api/members/{id}", handleUpdateMember).Methods("PUT")
 	r.HandleFunc("/api/members/{id}", handleDeleteMember).Methods("DELETE")
 	r.HandleFunc("/api/members/order", handleUpdateMemberOrder).Methods("PUT")
+	r.HandleFunc("/api/members/{id}/restore", handleRestoreMember).Methods("POST")
 	r.HandleFunc("/api/games", handleGetGames).Methods("GET")
 	r.HandleFunc("/api/games", handleCreateGame).Methods("POST")
+	r.HandleFunc("/api/games/import", handleImportFixtures).Methods("POST")
+	r.HandleFunc("/api/games/{id}", handleGetGame).Methods("GET")
 	r.HandleFunc("/api/games/{id}", handleUpdateGame).Methods("PUT")
 	r.HandleFunc("/api/games/{id}", handleDeleteGame).Methods("DELETE")
+	r.HandleFunc("/api/games/{id}/restore", handleRestoreGame).Methods("POST")
+	r.HandleFunc("/api/series", handleGetSeriesList).Methods("GET")
+	r.HandleFunc("/api/series", handleCreateSeries).Methods("POST")
+	r.HandleFunc("/api/series/{id}", handleGetSeries).Methods("GET")
+	r.HandleFunc("/api/feeds", handleGetFeeds).Methods("GET")
+	r.HandleFunc("/api/feeds", handleCreateFeed).Methods("POST")
+	r.HandleFunc("/api/feeds/{token}", handleDeleteFeed).Methods("DELETE")
+	r.HandleFunc("/calendar/{token}", handleGetCalendarFeed).Methods("GET")
+	r.HandleFunc("/api/trash", handleGetTrash).Methods("GET")
+	r.HandleFunc("/api/audit", handleGetAudit).Methods("GET")
+	r.HandleFunc("/api/consistency", handleGetConsistency).Methods("GET")
+	r.HandleFunc("/api/consistency/fix", handleFixConsistency).Methods("POST")
 	r.HandleFunc("/api/games/{id}/roster", handleUpdateRoster).Methods("PUT")
 	r.HandleFunc("/api/games/{id}/availability", handleSetAvailability).Methods("POST")
 	r.HandleFunc("/api/games/{id}/withdraw", handleWithdrawFromRoster).Methods("POST")
+	r.HandleFunc("/api/games/{id}/result", handleSetResult).Methods("PUT")
+	r.HandleFunc("/api/games/{id}/result", handleClearResult).Methods("DELETE")
+	r.HandleFunc("/api/games/{id}/stats", handleGetGameStats).Methods("GET")
+	r.HandleFunc("/api/games/{id}/stats", handleSetGameStats).Methods("PUT")
+	r.HandleFunc("/api/games/{id}/stats/import", handleImportGameStats).Methods("POST")
+	r.HandleFunc("/api/standings", handleGetStandings).Methods("GET")
+	r.HandleFunc("/api/stats/players", handleGetPlayerStats).Methods("GET")
+	r.HandleFunc("/api/stats/columns", handleGetStatColumns).Methods("GET")
+	r.HandleFunc("/api/stats/columns/{mode}", handleSetStatColumns).Methods("PUT")
+	r.HandleFunc("/api/stats/leaderboard", handleGetLeaderboard).Methods("GET")
+	r.HandleFunc("/api/export/games", handleExportGames).Methods("GET")
+	r.HandleFunc("/api/export/availability", handleExportAvailability).Methods("GET")
+	r.HandleFunc("/api/conflicts", handleGetConflicts).Methods("GET")
+	r.HandleFunc("/api/durations", handleGetGameDurations).Methods("GET")
+	r.HandleFunc("/api/durations/{mode}", handleSetGameDuration).Methods("PUT")
 	r.HandleFunc("/api/preferences", handleGetPreferences).Methods("GET")
 	r.HandleFunc("/api/preferences/{playerId}", handleSetPreference).Methods("PUT")
 	r.HandleFunc("/api/webhook", handleGetWebhook).Methods("GET")
 	r.HandleFunc("/api/webhook", handleSetWebhook).Methods("PUT")
+	r.HandleFunc("/api/teams", handleGetTeams).Methods("GET")
+	r.HandleFunc("/api/teams", handleCreateTeam).Methods("POST")
+	r.HandleFunc("/api/teams/current", handleGetCurrentTeam).Methods("GET")
+	r.HandleFunc("/api/teams/{id}", handleUpdateTeam).Methods("PUT")
+	r.HandleFunc("/api/seasons", handleGetSeasons).Methods("GET")
+	r.HandleFunc("/api/seasons", handleStartSeason).Methods("POST")
+	r.HandleFunc("/api/seasons/current", handleGetCurrentSeason).Methods("GET")
+	r.HandleFunc("/api/seasons/{id}", handleUpdateSeason).Methods("PUT")
 	r.HandleFunc("/api/leagues", handleGetLeagues).Methods("GET")
-	r.HandleFunc("/api/leagues", handleAddLeague).Methods("POST")
-	r.HandleFunc("/api/leagues/{name}", handleDeleteLeague).Methods("DELETE")
+	r.HandleFunc("/api/leagues", handleCreateLeague).Methods("POST")
+	r.HandleFunc("/api/leagues/{id}", handleUpdateLeague).Methods("PUT")
+	r.HandleFunc("/api/leagues/{id}", handleDeleteLeague).Methods("DELETE")
 	r.HandleFunc("/api/divisions", handleGetDivisions).Methods("GET")
-	r.HandleFunc("/api/divisions", handleAddDivision).Methods("POST")
-	r.HandleFunc("/api/divisions/{name}", handleDeleteDivision).Methods("DELETE")
+	r.HandleFunc("/api/divisions", handleCreateDivision).Methods("POST")
+	r.HandleFunc("/api/divisions/{id}", handleUpdateDivision).Methods("PUT")
+	r.HandleFunc("/api/divisions/{id}", handleDeleteDivision).Methods("DELETE")
+	r.HandleFunc("/api/opponents", handleGetOpponents).Methods("GET")
+	r.HandleFunc("/api/opponents", handleCreateOpponent).Methods("POST")
+	r.HandleFunc("/api/opponents/{id}", handleGetOpponent).Methods("GET")
+	r.HandleFunc("/api/opponents/{id}", handleUpdateOpponent).Methods("PUT")
 	r.HandleFunc("/api/discord/post/{id}", handlePostToDiscord).Methods("POST")
+	r.HandleFunc("/api/discord/result/{id}", handlePostResultToDiscord).Methods("POST")
 	r.HandleFunc("/api/games/{id}/announce", handleAnnounceGame).Methods("POST")
 	r.HandleFunc("/api/users/linked", handleGetLinkedUsers).Methods("GET")
 
@@ -0,0 +1,1450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== MIGRATIONS ====================
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// UpFunc runs after Up and DownFunc before Down, inside the same
+	// transaction, for data migrations that are easier to express in Go.
+	// tx rebinds $N placeholders for its dialect like every other query.
+	UpFunc   func(ctx context.Context, tx sqlTx) error
+	DownFunc func(ctx context.Context, tx sqlTx) error
+	// SQLiteUp and SQLiteDown stand in for Up and Down on SQLite, for the
+	// shared migrations whose SQL can't be written once for both.
+	SQLiteUp   string
+	SQLiteDown string
+}
+
+// script is the SQL m runs on d going up, or down. {{timestamp}} becomes
+// the dialect's timestamp column type.
+func (m migration) script(d *sqlDialect, up bool) string {
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+	if d.name == backendSQLite && up && m.SQLiteUp != "" {
+		script = m.SQLiteUp
+	} else if d.name == backendSQLite && !up && m.SQLiteDown != "" {
+		script = m.SQLiteDown
+	}
+	return strings.ReplaceAll(script, "{{timestamp}}", d.timestampType)
+}
+
+// migrations is the ordered Postgres schema history up to version 9, the
+// last before SQLite. Never edit a migration that has shipped; add a new
+// one to sharedMigrations with the next version number instead.
+//
+// The early migrations use IF NOT EXISTS so they apply cleanly to databases
+// that were created by the old ad-hoc initDB before schema_migrations existed.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create_core_tables",
+		Up: `
+			CREATE TABLE IF NOT EXISTS games (
+				id TEXT PRIMARY KEY,
+				date TEXT NOT NULL,
+				time TEXT NOT NULL,
+				opponent TEXT NOT NULL,
+				notes TEXT DEFAULT '',
+				available TEXT DEFAULT '[]',
+				unavailable TEXT DEFAULT '[]',
+				roster TEXT DEFAULT '[]',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS player_preferences (
+				player_id TEXT PRIMARY KEY,
+				preference TEXT DEFAULT 'starter'
+			);
+			CREATE TABLE IF NOT EXISTS settings (
+				key TEXT PRIMARY KEY,
+				value TEXT
+			);
+			CREATE TABLE IF NOT EXISTS users (
+				discord_id TEXT PRIMARY KEY,
+				username TEXT NOT NULL,
+				display_name TEXT,
+				avatar TEXT,
+				player_id TEXT,
+				is_manager BOOLEAN DEFAULT FALSE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS members (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				year INTEGER DEFAULT 2025,
+				region TEXT,
+				note TEXT,
+				is_vet BOOLEAN DEFAULT FALSE,
+				sort_order INTEGER DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS members;
+			DROP TABLE IF EXISTS users;
+			DROP TABLE IF EXISTS settings;
+			DROP TABLE IF EXISTS player_preferences;
+			DROP TABLE IF EXISTS games;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "add_games_reminded",
+		Up:      `ALTER TABLE games ADD COLUMN IF NOT EXISTS reminded BOOLEAN DEFAULT FALSE`,
+		Down:    `ALTER TABLE games DROP COLUMN IF EXISTS reminded`,
+	},
+	{
+		Version: 3,
+		Name:    "add_games_league_division",
+		Up: `
+			ALTER TABLE games ADD COLUMN IF NOT EXISTS league TEXT DEFAULT '';
+			ALTER TABLE games ADD COLUMN IF NOT EXISTS division TEXT DEFAULT '';
+		`,
+		Down: `
+			ALTER TABLE games DROP COLUMN IF EXISTS division;
+			ALTER TABLE games DROP COLUMN IF EXISTS league;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "add_games_mode_team_size",
+		Up: `
+			ALTER TABLE games ADD COLUMN IF NOT EXISTS game_mode TEXT DEFAULT 'War';
+			ALTER TABLE games ADD COLUMN IF NOT EXISTS team_size INTEGER DEFAULT 10;
+		`,
+		Down: `
+			ALTER TABLE games DROP COLUMN IF EXISTS team_size;
+			ALTER TABLE games DROP COLUMN IF EXISTS game_mode;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "add_games_withdrawals_subs",
+		Up: `
+			ALTER TABLE games ADD COLUMN IF NOT EXISTS withdrawals TEXT DEFAULT '[]';
+			ALTER TABLE games ADD COLUMN IF NOT EXISTS subs TEXT DEFAULT '[]';
+		`,
+		Down: `
+			ALTER TABLE games DROP COLUMN IF EXISTS subs;
+			ALTER TABLE games DROP COLUMN IF EXISTS withdrawals;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "add_users_contact",
+		Up: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT DEFAULT '';
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT DEFAULT '';
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN IF EXISTS phone;
+			ALTER TABLE users DROP COLUMN IF EXISTS email;
+		`,
+	},
+	{
+		// The legacy JSON list columns on games are left in place, untouched,
+		// so nothing is lost if a blob could not be parsed during the copy.
+		Version: 7,
+		Name:    "create_game_participants",
+		Up: `
+			CREATE TABLE game_participants (
+				game_id TEXT NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+				member_id TEXT NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+				status TEXT NOT NULL DEFAULT 'none' CHECK (status IN ('available', 'unavailable', 'none')),
+				role TEXT NOT NULL DEFAULT 'none' CHECK (role IN ('roster', 'sub', 'withdrawn', 'none')),
+				position INTEGER NOT NULL DEFAULT 0,
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_by TEXT,
+				PRIMARY KEY (game_id, member_id)
+			);
+			CREATE INDEX game_participants_member_idx ON game_participants (member_id);
+		`,
+		UpFunc:   copyParticipantsFromJSON,
+		DownFunc: copyParticipantsToJSON,
+		Down:     `DROP TABLE IF EXISTS game_participants`,
+	},
+	{
+		Version: 8,
+		Name:    "add_games_starts_at",
+		Up: `
+			ALTER TABLE games ADD COLUMN starts_at TIMESTAMPTZ;
+			ALTER TABLE games ADD COLUMN time_zone TEXT NOT NULL DEFAULT 'America/New_York';
+		`,
+		UpFunc: convertGameStartsFromEastern,
+		Down: `
+			ALTER TABLE games DROP COLUMN time_zone;
+			ALTER TABLE games DROP COLUMN starts_at;
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "drop_games_date_time",
+		Up: `
+			ALTER TABLE games ALTER COLUMN starts_at SET NOT NULL;
+			CREATE INDEX games_starts_at_idx ON games (starts_at);
+			ALTER TABLE games DROP COLUMN date;
+			ALTER TABLE games DROP COLUMN time;
+		`,
+		DownFunc: restoreGameDateTime,
+		Down: `
+			DROP INDEX IF EXISTS games_starts_at_idx;
+			ALTER TABLE games ALTER COLUMN starts_at DROP NOT NULL;
+		`,
+	},
+}
+
+// sharedMigrations follow both dialects' own histories, which end at
+// version 9. Each is written once, in SQL that Postgres and SQLite both
+// accept: plain TEXT, INTEGER and BOOLEAN columns, {{timestamp}} for
+// timestamps and no IF [NOT] EXISTS on columns. Where that can't be done,
+// SQLiteUp and SQLiteDown carry SQLite's own SQL.
+var sharedMigrations = []migration{
+	{
+		Version: 10,
+		Name:    "add_soft_delete",
+		Up: `
+			ALTER TABLE games ADD COLUMN deleted_at {{timestamp}};
+			ALTER TABLE members ADD COLUMN deleted_at {{timestamp}};
+		`,
+		Down: `
+			DELETE FROM games WHERE deleted_at IS NOT NULL;
+			DELETE FROM members WHERE deleted_at IS NOT NULL;
+			ALTER TABLE members DROP COLUMN deleted_at;
+			ALTER TABLE games DROP COLUMN deleted_at;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "create_audit_events",
+		Up: `
+			CREATE TABLE audit_events (
+				id BIGSERIAL PRIMARY KEY,
+				occurred_at {{timestamp}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				actor TEXT NOT NULL DEFAULT '',
+				action TEXT NOT NULL,
+				entity_type TEXT NOT NULL,
+				entity_id TEXT NOT NULL DEFAULT '',
+				before_data JSONB,
+				after_data JSONB
+			);
+			CREATE INDEX audit_events_occurred_at_idx ON audit_events (occurred_at);
+			CREATE INDEX audit_events_entity_idx ON audit_events (entity_type, entity_id, occurred_at);
+			CREATE INDEX audit_events_actor_idx ON audit_events (actor, occurred_at);
+		`,
+		// SQLite has no BIGSERIAL or JSONB
+		SQLiteUp: `
+			CREATE TABLE audit_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				occurred_at {{timestamp}} NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				actor TEXT NOT NULL DEFAULT '',
+				action TEXT NOT NULL,
+				entity_type TEXT NOT NULL,
+				entity_id TEXT NOT NULL DEFAULT '',
+				before_data TEXT,
+				after_data TEXT
+			);
+			CREATE INDEX audit_events_occurred_at_idx ON audit_events (occurred_at);
+			CREATE INDEX audit_events_entity_idx ON audit_events (entity_type, entity_id, occurred_at);
+			CREATE INDEX audit_events_actor_idx ON audit_events (actor, occurred_at);
+		`,
+		Down: `DROP TABLE IF EXISTS audit_events`,
+	},
+	{
+		Version: 12,
+		Name:    "add_games_version",
+		Up:      `ALTER TABLE games ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		Down:    `ALTER TABLE games DROP COLUMN version`,
+	},
+	{
+		// Everything so far becomes the first season, which also takes over
+		// the league and division lists.
+		Version: 13,
+		Name:    "create_seasons",
+		Up: `
+			CREATE TABLE seasons (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				starts_at {{timestamp}} NOT NULL,
+				ends_at {{timestamp}},
+				active BOOLEAN NOT NULL DEFAULT FALSE,
+				archived_at {{timestamp}},
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE UNIQUE INDEX seasons_active_idx ON seasons (active) WHERE active;
+			INSERT INTO seasons (id, name, starts_at, active)
+				SELECT 'season_1', 'Season 1', COALESCE(MIN(starts_at), CURRENT_TIMESTAMP), TRUE FROM games;
+			ALTER TABLE games ADD COLUMN season_id TEXT REFERENCES seasons(id);
+			UPDATE games SET season_id = 'season_1';
+			CREATE INDEX games_season_idx ON games (season_id, starts_at);
+			UPDATE settings SET key = key || ':season_1' WHERE key IN ('leagues', 'divisions');
+		`,
+		Down: `
+			UPDATE settings SET key = 'leagues' WHERE key = 'leagues:' || (SELECT id FROM seasons WHERE active);
+			UPDATE settings SET key = 'divisions' WHERE key = 'divisions:' || (SELECT id FROM seasons WHERE active);
+			DELETE FROM settings WHERE key LIKE 'leagues:%' OR key LIKE 'divisions:%';
+			DROP INDEX games_season_idx;
+			ALTER TABLE games DROP COLUMN season_id;
+			DROP TABLE seasons;
+		`,
+	},
+	{
+		// The per-season league and division lists in settings, and any names
+		// only games still use, become rows that games point at by ID.
+		Version: 14,
+		Name:    "create_leagues",
+		Up: `
+			CREATE TABLE leagues (
+				id TEXT PRIMARY KEY,
+				season_id TEXT NOT NULL REFERENCES seasons(id),
+				name TEXT NOT NULL,
+				short_code TEXT NOT NULL DEFAULT '',
+				color TEXT NOT NULL DEFAULT '',
+				default_game_mode TEXT NOT NULL DEFAULT '',
+				default_team_size INTEGER NOT NULL DEFAULT 0,
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE UNIQUE INDEX leagues_season_name_idx ON leagues (season_id, name);
+			CREATE TABLE divisions (
+				id TEXT PRIMARY KEY,
+				season_id TEXT NOT NULL REFERENCES seasons(id),
+				league_id TEXT REFERENCES leagues(id),
+				name TEXT NOT NULL,
+				short_code TEXT NOT NULL DEFAULT '',
+				color TEXT NOT NULL DEFAULT '',
+				default_game_mode TEXT NOT NULL DEFAULT '',
+				default_team_size INTEGER NOT NULL DEFAULT 0,
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE UNIQUE INDEX divisions_season_name_idx ON divisions (season_id, name);
+			ALTER TABLE games ADD COLUMN league_id TEXT REFERENCES leagues(id);
+			ALTER TABLE games ADD COLUMN division_id TEXT REFERENCES divisions(id);
+		`,
+		UpFunc:   copyLeaguesFromSettings,
+		DownFunc: copyLeaguesToSettings,
+		Down: `
+			ALTER TABLE games DROP COLUMN division_id;
+			ALTER TABLE games DROP COLUMN league_id;
+			DROP TABLE divisions;
+			DROP TABLE leagues;
+		`,
+	},
+	{
+		Version: 15,
+		Name:    "drop_games_league_names",
+		Up: `
+			ALTER TABLE games DROP COLUMN division;
+			ALTER TABLE games DROP COLUMN league;
+		`,
+		Down: `
+			ALTER TABLE games ADD COLUMN league TEXT DEFAULT '';
+			ALTER TABLE games ADD COLUMN division TEXT DEFAULT '';
+			UPDATE games SET
+				league = COALESCE((SELECT name FROM leagues WHERE leagues.id = games.league_id), ''),
+				division = COALESCE((SELECT name FROM divisions WHERE divisions.id = games.division_id), '');
+		`,
+	},
+	{
+		// Every distinct opponent spelling, grouped by opponentKey, becomes
+		// one opponent named after its most used spelling.
+		Version: 16,
+		Name:    "create_opponents",
+		Up: `
+			CREATE TABLE opponents (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				name_key TEXT NOT NULL UNIQUE,
+				tag TEXT NOT NULL DEFAULT '',
+				region TEXT NOT NULL DEFAULT '',
+				logo TEXT NOT NULL DEFAULT '',
+				discord TEXT NOT NULL DEFAULT '',
+				notes TEXT NOT NULL DEFAULT '',
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE opponent_aliases (
+				alias_key TEXT PRIMARY KEY,
+				opponent_id TEXT NOT NULL REFERENCES opponents(id) ON DELETE CASCADE,
+				alias TEXT NOT NULL
+			);
+			CREATE INDEX opponent_aliases_opponent_idx ON opponent_aliases (opponent_id);
+			ALTER TABLE games ADD COLUMN opponent_id TEXT REFERENCES opponents(id);
+			CREATE INDEX games_opponent_idx ON games (opponent_id, starts_at);
+		`,
+		UpFunc: groupOpponentNames,
+		Down: `
+			DROP INDEX games_opponent_idx;
+			ALTER TABLE games DROP COLUMN opponent_id;
+			DROP TABLE opponent_aliases;
+			DROP TABLE opponents;
+		`,
+	},
+	{
+		// Everything so far becomes the first team's: its seasons, members,
+		// games and webhook, and every user with their player and manager
+		// flag.
+		Version: 17,
+		Name:    "create_teams",
+		Up: `
+			CREATE TABLE teams (
+				id TEXT PRIMARY KEY,
+				slug TEXT NOT NULL UNIQUE,
+				name TEXT NOT NULL,
+				discord_guild_id TEXT,
+				discord_manager_role TEXT,
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			INSERT INTO teams (id, slug, name) VALUES ('team_1', 'pop1', 'Game Over Pop1 War Team');
+			CREATE TABLE team_users (
+				team_id TEXT NOT NULL REFERENCES teams(id),
+				discord_id TEXT NOT NULL REFERENCES users(discord_id),
+				player_id TEXT,
+				is_manager BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (team_id, discord_id)
+			);
+			CREATE INDEX team_users_player_idx ON team_users (player_id);
+			INSERT INTO team_users (team_id, discord_id, player_id, is_manager)
+				SELECT 'team_1', discord_id, NULLIF(player_id, ''), COALESCE(is_manager, FALSE) FROM users;
+			ALTER TABLE seasons ADD COLUMN team_id TEXT REFERENCES teams(id);
+			UPDATE seasons SET team_id = 'team_1';
+			DROP INDEX seasons_active_idx;
+			CREATE UNIQUE INDEX seasons_active_idx ON seasons (team_id) WHERE active;
+			ALTER TABLE members ADD COLUMN team_id TEXT REFERENCES teams(id);
+			UPDATE members SET team_id = 'team_1';
+			CREATE INDEX members_team_idx ON members (team_id);
+			ALTER TABLE games ADD COLUMN team_id TEXT REFERENCES teams(id);
+			UPDATE games SET team_id = 'team_1';
+			CREATE INDEX games_team_idx ON games (team_id, starts_at);
+			UPDATE settings SET key = key || ':team_1' WHERE key = 'discord_webhook';
+		`,
+		Down: `
+			DELETE FROM settings WHERE key LIKE 'discord_webhook:%' AND key <> 'discord_webhook:team_1';
+			UPDATE settings SET key = 'discord_webhook' WHERE key = 'discord_webhook:team_1';
+			DROP INDEX games_team_idx;
+			ALTER TABLE games DROP COLUMN team_id;
+			DROP INDEX members_team_idx;
+			ALTER TABLE members DROP COLUMN team_id;
+			UPDATE seasons SET active = FALSE WHERE team_id <> 'team_1';
+			DROP INDEX seasons_active_idx;
+			ALTER TABLE seasons DROP COLUMN team_id;
+			CREATE UNIQUE INDEX seasons_active_idx ON seasons (active) WHERE active;
+			DROP TABLE team_users;
+			DROP TABLE teams;
+		`,
+	},
+	{
+		// Players and manager flags live in team_users now.
+		Version: 18,
+		Name:    "drop_users_team_columns",
+		Up: `
+			ALTER TABLE users DROP COLUMN is_manager;
+			ALTER TABLE users DROP COLUMN player_id;
+		`,
+		Down: `
+			ALTER TABLE users ADD COLUMN player_id TEXT;
+			ALTER TABLE users ADD COLUMN is_manager BOOLEAN DEFAULT FALSE;
+			UPDATE users SET
+				player_id = (SELECT player_id FROM team_users t WHERE t.discord_id = users.discord_id AND t.team_id = 'team_1'),
+				is_manager = COALESCE((SELECT is_manager FROM team_users t WHERE t.discord_id = users.discord_id AND t.team_id = 'team_1'), FALSE);
+		`,
+	},
+	{
+		// Every member's current name becomes their first alias. Members
+		// keep their old name-based IDs; new ones get generated IDs.
+		Version: 19,
+		Name:    "create_member_aliases",
+		Up: `
+			CREATE TABLE member_aliases (
+				team_id TEXT NOT NULL REFERENCES teams(id),
+				alias_key TEXT NOT NULL,
+				member_id TEXT NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+				alias TEXT NOT NULL,
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (team_id, alias_key)
+			);
+			CREATE INDEX member_aliases_member_idx ON member_aliases (member_id);
+		`,
+		UpFunc: copyMemberNamesToAliases,
+		Down: `
+			DROP TABLE member_aliases;
+		`,
+	},
+	{
+		Version: 20,
+		Name:    "create_game_results",
+		Up: `
+			CREATE TABLE game_results (
+				game_id TEXT PRIMARY KEY REFERENCES games(id) ON DELETE CASCADE,
+				our_score INTEGER NOT NULL,
+				their_score INTEGER NOT NULL,
+				outcome TEXT NOT NULL,
+				forfeit TEXT NOT NULL DEFAULT '',
+				rounds TEXT NOT NULL DEFAULT '[]',
+				recorded_by TEXT,
+				recorded_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+		Down: `
+			DROP TABLE game_results;
+		`,
+	},
+	{
+		// Player stats need when members first answered and when they pulled
+		// out; existing rows take their last change as the best guess.
+		Version: 21,
+		Name:    "add_participant_response_times",
+		Up: `
+			ALTER TABLE game_participants ADD COLUMN responded_at {{timestamp}};
+			ALTER TABLE game_participants ADD COLUMN withdrawn_at {{timestamp}};
+			UPDATE game_participants SET responded_at = updated_at WHERE status <> 'none';
+			UPDATE game_participants SET withdrawn_at = updated_at WHERE role = 'withdrawn';
+		`,
+		Down: `
+			ALTER TABLE game_participants DROP COLUMN withdrawn_at;
+			ALTER TABLE game_participants DROP COLUMN responded_at;
+		`,
+	},
+	{
+		// stats holds a JSON object of the game mode's stat columns
+		Version: 22,
+		Name:    "create_player_game_stats",
+		Up: `
+			CREATE TABLE player_game_stats (
+				game_id TEXT NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+				member_id TEXT NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+				stats TEXT NOT NULL DEFAULT '{}',
+				recorded_by TEXT,
+				recorded_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (game_id, member_id)
+			);
+			CREATE INDEX player_game_stats_member_idx ON player_game_stats (member_id);
+		`,
+		Down: `
+			DROP TABLE player_game_stats;
+		`,
+	},
+	{
+		// Games generated by a series point back at it and remember the
+		// start it gave them, so scoped edits can tell which come after
+		Version: 23,
+		Name:    "create_game_series",
+		Up: `
+			CREATE TABLE game_series (
+				id TEXT PRIMARY KEY,
+				team_id TEXT NOT NULL REFERENCES teams(id),
+				season_id TEXT REFERENCES seasons(id),
+				rrule TEXT NOT NULL,
+				starts_at {{timestamp}} NOT NULL,
+				time_zone TEXT NOT NULL,
+				exceptions TEXT NOT NULL DEFAULT '[]',
+				created_by TEXT,
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX game_series_team_idx ON game_series (team_id);
+			ALTER TABLE games ADD COLUMN series_id TEXT REFERENCES game_series(id);
+			ALTER TABLE games ADD COLUMN occurrence_at {{timestamp}};
+			CREATE INDEX games_series_idx ON games (series_id, occurrence_at);
+		`,
+		Down: `
+			DROP INDEX games_series_idx;
+			ALTER TABLE games DROP COLUMN occurrence_at;
+			ALTER TABLE games DROP COLUMN series_id;
+			DROP TABLE game_series;
+		`,
+	},
+	{
+		// League and member IDs aren't foreign keys: a feed outlives the
+		// league or member it follows and then just comes up empty
+		Version: 24,
+		Name:    "create_calendar_feeds",
+		Up: `
+			CREATE TABLE calendar_feeds (
+				token TEXT PRIMARY KEY,
+				team_id TEXT NOT NULL REFERENCES teams(id),
+				kind TEXT NOT NULL,
+				league_id TEXT,
+				member_id TEXT,
+				created_by TEXT,
+				created_at {{timestamp}} DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX calendar_feeds_team_idx ON calendar_feeds (team_id);
+		`,
+		Down: `
+			DROP TABLE calendar_feeds;
+		`,
+	},
+	{
+		// "" lets the app pick a zone from the user's browser or region
+		Version: 25,
+		Name:    "add_users_time_zone",
+		Up:      `ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone TEXT DEFAULT ''`,
+		Down:    `ALTER TABLE users DROP COLUMN IF EXISTS time_zone`,
+		// SQLite has no IF [NOT] EXISTS on columns
+		SQLiteUp:   `ALTER TABLE users ADD COLUMN time_zone TEXT DEFAULT ''`,
+		SQLiteDown: `ALTER TABLE users DROP COLUMN time_zone`,
+	},
+	{
+		// Events recorded before now get the team of the entity they name
+		// where it still exists; the rest stay NULL and no team lists them
+		Version: 26,
+		Name:    "add_audit_events_team",
+		Up: `
+			ALTER TABLE audit_events ADD COLUMN team_id TEXT;
+			UPDATE audit_events SET team_id = (SELECT team_id FROM games WHERE games.id = audit_events.entity_id)
+				WHERE entity_type = 'game';
+			UPDATE audit_events SET team_id = (SELECT team_id FROM members WHERE members.id = audit_events.entity_id)
+				WHERE entity_type IN ('member', 'preference');
+			UPDATE audit_events SET team_id = (SELECT team_id FROM seasons WHERE seasons.id = audit_events.entity_id)
+				WHERE entity_type = 'season';
+			UPDATE audit_events SET team_id = (SELECT s.team_id FROM leagues l JOIN seasons s ON s.id = l.season_id WHERE l.id = audit_events.entity_id)
+				WHERE entity_type = 'league';
+			UPDATE audit_events SET team_id = (SELECT s.team_id FROM divisions d JOIN seasons s ON s.id = d.season_id WHERE d.id = audit_events.entity_id)
+				WHERE entity_type = 'division';
+			UPDATE audit_events SET team_id = (SELECT team_id FROM game_series WHERE game_series.id = audit_events.entity_id)
+				WHERE entity_type = 'series';
+			UPDATE audit_events SET team_id = (SELECT team_id FROM calendar_feeds WHERE calendar_feeds.token = audit_events.entity_id)
+				WHERE entity_type = 'feed';
+			UPDATE audit_events SET team_id = entity_id WHERE entity_type = 'team';
+			UPDATE audit_events SET team_id = (SELECT MIN(id) FROM teams
+				WHERE audit_events.entity_id LIKE '%:' || teams.id OR audit_events.entity_id LIKE '%:' || teams.id || ':%')
+				WHERE entity_type = 'setting';
+			CREATE INDEX audit_events_team_idx ON audit_events (team_id, occurred_at);
+		`,
+		Down: `
+			DROP INDEX audit_events_team_idx;
+			ALTER TABLE audit_events DROP COLUMN team_id;
+		`,
+	},
+	{
+		// Runs after 26, whose backfill finds feeds by the token. There is
+		// no going back: the tokens are gone, so down leaves the rows alone
+		Version: 27,
+		Name:    "scrub_feed_audit_tokens",
+		UpFunc:  scrubFeedAuditTokens,
+	},
+	{
+		// Teams after the first with no Discord server of their own used to
+		// take the global one's managers. Only whoever created such a team
+		// keeps managing it; down can't tell who was given it, so it doesn't
+		Version: 28,
+		Name:    "revoke_fallback_team_managers",
+		Up: `
+			UPDATE team_users SET is_manager = FALSE
+			WHERE is_manager
+			AND team_id IN (SELECT id FROM teams
+				WHERE COALESCE(discord_guild_id, '') = ''
+				AND id <> (SELECT id FROM teams ORDER BY created_at, id LIMIT 1))
+			AND discord_id NOT IN (SELECT actor FROM audit_events
+				WHERE entity_type = 'team' AND action = 'create' AND entity_id = team_users.team_id);
+		`,
+	},
+}
+
+// withSharedMigrations is a dialect's full history: its own migrations,
+// then the shared ones.
+func withSharedMigrations(own []migration) []migration {
+	all := make([]migration, 0, len(own)+len(sharedMigrations))
+	all = append(all, own...)
+	return append(all, sharedMigrations...)
+}
+
+type migrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+func validateMigrations(migrations []migration) error {
+	seen := make(map[int]bool)
+	for i, m := range migrations {
+		if m.Version <= 0 {
+			return fmt.Errorf("migration %q has invalid version %d", m.Name, m.Version)
+		}
+		if seen[m.Version] {
+			return fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+		if i > 0 && m.Version < migrations[i-1].Version {
+			return fmt.Errorf("migration %d is out of order", m.Version)
+		}
+		seen[m.Version] = true
+	}
+	return nil
+}
+
+// withMigrationLock runs fn on a single connection holding the dialect's
+// migration lock. Postgres advisory locks belong to a session, so everything
+// has to run on the same *sql.Conn rather than the pool.
+func withMigrationLock(db sqlDB, fn func(ctx context.Context, conn *sql.Conn) error) error {
+	if err := validateMigrations(db.dialect.migrations); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	unlock, err := db.dialect.lockMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	defer unlock()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at `+db.dialect.timestampType+` NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	return fn(ctx, conn)
+}
+
+// migrationReader is what appliedMigrations reads from: the locked
+// connection while migrating, or the pool for a read-only status.
+type migrationReader interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func appliedMigrations(ctx context.Context, conn migrationReader) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs one migration in its own transaction together with the
+// schema_migrations bookkeeping, so a failure leaves nothing half-applied.
+func applyMigration(ctx context.Context, conn *sql.Conn, d *sqlDialect, m migration, up bool) error {
+	rawTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rawTx.Rollback()
+	tx := sqlTx{Tx: rawTx, dialect: d}
+
+	if up {
+		if err := execMigrationStep(ctx, tx, m.script(d, true), m.UpFunc); err != nil {
+			return err
+		}
+	} else {
+		if m.DownFunc != nil {
+			if err := m.DownFunc(ctx, tx); err != nil {
+				return err
+			}
+		}
+		if err := execMigrationStep(ctx, tx, m.script(d, false), nil); err != nil {
+			return err
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, d.bind(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`), m.Version, m.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, d.bind(`DELETE FROM schema_migrations WHERE version = $1`), m.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func execMigrationStep(ctx context.Context, tx sqlTx, script string, fn func(context.Context, sqlTx) error) error {
+	if strings.TrimSpace(script) != "" {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return err
+		}
+	}
+	if fn != nil {
+		return fn(ctx, tx)
+	}
+	return nil
+}
+
+// migrateUp applies every pending migration in order and returns how many ran.
+func migrateUp(db sqlDB) (int, error) {
+	count := 0
+	err := withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("failed to read schema_migrations: %v", err)
+		}
+
+		for _, m := range db.dialect.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			log.Printf("Applying migration %d_%s", m.Version, m.Name)
+			if err := applyMigration(ctx, conn, db.dialect, m, true); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %v", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// migrateDown rolls back the most recently applied migrations, newest first.
+func migrateDown(db sqlDB, steps int) (int, error) {
+	count := 0
+	err := withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("failed to read schema_migrations: %v", err)
+		}
+
+		migrations := db.dialect.migrations
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			log.Printf("Reverting migration %d_%s", m.Version, m.Name)
+			if err := applyMigration(ctx, conn, db.dialect, m, false); err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %v", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// getMigrationStatus lists every migration and when it was applied. It only
+// reads, so it takes no lock and doesn't create schema_migrations; a
+// database without the table has nothing applied.
+func getMigrationStatus(db sqlDB) ([]migrationStatus, error) {
+	if err := validateMigrations(db.dialect.migrations); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var exists bool
+	if err := db.QueryRowContext(ctx, db.dialect.bind(db.dialect.tableExists), "schema_migrations").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look for schema_migrations: %v", err)
+	}
+	applied := make(map[int]time.Time)
+	if exists {
+		var err error
+		if applied, err = appliedMigrations(ctx, db.DB); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []migrationStatus
+	known := make(map[int]bool)
+	for _, m := range db.dialect.migrations {
+		known[m.Version] = true
+		s := migrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+
+	// Versions recorded in the database but missing from this binary
+	// usually mean an older build is running against a newer schema.
+	for version, at := range applied {
+		if !known[version] {
+			at := at
+			statuses = append(statuses, migrationStatus{Version: version, Name: "(unknown)", AppliedAt: &at})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// runMigrateCommand implements `server migrate status|up|down [steps]`.
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate status|up|down [steps]")
+	}
+
+	db, err := openSQLDatabase(storageBackend())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := getMigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		applied := 0
+		for _, s := range statuses {
+			if s.AppliedAt != nil {
+				applied++
+			}
+		}
+		if applied == 0 {
+			fmt.Println("No migrations applied")
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-32s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+
+	case "up":
+		count, err := migrateUp(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", count)
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		count, err := migrateDown(db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", count)
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
+
+// ==================== DATA MIGRATIONS ====================
+
+// copyParticipantsFromJSON fills game_participants from the legacy JSON list
+// columns on games. Malformed blobs and IDs that no longer match a member are
+// logged and skipped rather than failing the deploy; the legacy columns are
+// kept so they can still be recovered by hand.
+func copyParticipantsFromJSON(ctx context.Context, tx sqlTx) error {
+	known := make(map[string]bool)
+	memberRows, err := tx.QueryContext(ctx, `SELECT id FROM members`)
+	if err != nil {
+		return err
+	}
+	for memberRows.Next() {
+		var id string
+		if err := memberRows.Scan(&id); err != nil {
+			memberRows.Close()
+			return err
+		}
+		known[id] = true
+	}
+	memberRows.Close()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, COALESCE(available, '[]'), COALESCE(unavailable, '[]'),
+		COALESCE(roster, '[]'), COALESCE(subs, '[]'), COALESCE(withdrawals, '[]') FROM games`)
+	if err != nil {
+		return err
+	}
+	var games []Game
+	for rows.Next() {
+		var g Game
+		var columns [5]string
+		if err := rows.Scan(&g.ID, &columns[0], &columns[1], &columns[2], &columns[3], &columns[4]); err != nil {
+			rows.Close()
+			return err
+		}
+		lists := []*[]string{&g.Available, &g.Unavailable, &g.Roster, &g.Subs, &g.Withdrawals}
+		names := []string{"available", "unavailable", "roster", "subs", "withdrawals"}
+		for i, raw := range columns {
+			if err := json.Unmarshal([]byte(raw), lists[i]); err != nil {
+				log.Printf("Migration: game %s has malformed %s column %q, skipping it: %v", g.ID, names[i], raw, err)
+			}
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, g := range games {
+		for id, p := range participantsFromLists(&g) {
+			if !known[id] {
+				log.Printf("Migration: game %s references unknown member %q, skipping it", g.ID, id)
+				continue
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO game_participants (game_id, member_id, status, role, position)
+				VALUES ($1, $2, $3, $4, $5)`, g.ID, id, p.Status, p.Role, p.Position)
+			if err != nil {
+				return fmt.Errorf("game %s member %s: %v", g.ID, id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyParticipantsToJSON writes game_participants back into the legacy JSON
+// columns so rolling back doesn't lose changes made since the migration.
+func copyParticipantsToJSON(ctx context.Context, tx sqlTx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM games`)
+	if err != nil {
+		return err
+	}
+	var games []Game
+	for rows.Next() {
+		var g Game
+		if err := rows.Scan(&g.ID); err != nil {
+			rows.Close()
+			return err
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+
+	if err := attachParticipants(tx, games); err != nil {
+		return err
+	}
+
+	for _, g := range games {
+		_, err := tx.ExecContext(ctx, `UPDATE games SET available = $1, unavailable = $2, roster = $3, subs = $4, withdrawals = $5
+			WHERE id = $6`, toJSONString(g.Available), toJSONString(g.Unavailable), toJSONString(g.Roster),
+			toJSONString(g.Subs), toJSONString(g.Withdrawals), g.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertGameStartsFromEastern fills starts_at from the legacy date and time
+// TEXT columns, which were always entered as Eastern wall-clock time. Rows
+// whose date can't be parsed fall back to their creation time so the NOT NULL
+// constraint in the next migration can still be applied.
+func convertGameStartsFromEastern(ctx context.Context, tx sqlTx) error {
+	loc, err := time.LoadLocation(defaultGameTimeZone)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, COALESCE(date, ''), COALESCE(time, ''),
+		COALESCE(created_at, CURRENT_TIMESTAMP) FROM games`)
+	if err != nil {
+		return err
+	}
+	type legacyGame struct {
+		id, date, clock string
+		createdAt       time.Time
+	}
+	var games []legacyGame
+	for rows.Next() {
+		var g legacyGame
+		if err := rows.Scan(&g.id, &g.date, &g.clock, &g.createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, g := range games {
+		startsAt, err := parseWallClock(g.date, g.clock, loc)
+		if err != nil {
+			// A missing or odd time still leaves the day usable
+			if day, dayErr := parseWallClock(g.date, "00:00", loc); dayErr == nil {
+				startsAt = day
+			} else {
+				startsAt = g.createdAt
+			}
+			log.Printf("Migration: game %s has unparseable start %q %q (%v), using %s",
+				g.id, g.date, g.clock, err, startsAt.Format(time.RFC3339))
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE games SET starts_at = $1, time_zone = $2 WHERE id = $3`,
+			startsAt.UTC(), defaultGameTimeZone, g.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreGameDateTime recreates the date and time TEXT columns from
+// starts_at, rendered as wall-clock time in each game's own zone.
+func restoreGameDateTime(ctx context.Context, tx sqlTx) error {
+	if _, err := tx.ExecContext(ctx, `
+		ALTER TABLE games ADD COLUMN date TEXT NOT NULL DEFAULT '';
+		ALTER TABLE games ADD COLUMN time TEXT NOT NULL DEFAULT '';
+	`); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, starts_at, time_zone FROM games`)
+	if err != nil {
+		return err
+	}
+	var games []Game
+	for rows.Next() {
+		var g Game
+		if err := rows.Scan(&g.ID, &g.StartsAt, &g.TimeZone); err != nil {
+			rows.Close()
+			return err
+		}
+		localizeGame(&g)
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, g := range games {
+		if _, err := tx.ExecContext(ctx, `UPDATE games SET date = $1, time = $2 WHERE id = $3`, g.Date, g.Time, g.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leagueTables are the tables that replaced the per-season settings lists,
+// which were kept under "<table>:<season ID>", and the games column each
+// one's names came from.
+var leagueTables = []struct {
+	table, column string
+}{
+	{table: "leagues", column: "league"},
+	{table: "divisions", column: "division"},
+}
+
+// copyLeaguesFromSettings turns the per-season league and division lists
+// into rows, adds any name a game uses that isn't in its season's list, and
+// points the games at the new rows. Lists for seasons that no longer exist
+// are logged and dropped.
+func copyLeaguesFromSettings(ctx context.Context, tx sqlTx) error {
+	seasons := make(map[string]bool)
+	seasonRows, err := tx.QueryContext(ctx, `SELECT id FROM seasons`)
+	if err != nil {
+		return err
+	}
+	for seasonRows.Next() {
+		var id string
+		if err := seasonRows.Scan(&id); err != nil {
+			seasonRows.Close()
+			return err
+		}
+		seasons[id] = true
+	}
+	seasonRows.Close()
+
+	for _, t := range leagueTables {
+		// names[season] lists the season's names in the order they were added
+		names := make(map[string][]string)
+		seen := make(map[string]bool)
+		add := func(seasonID, name string) {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[seasonID+"\x00"+name] {
+				return
+			}
+			seen[seasonID+"\x00"+name] = true
+			names[seasonID] = append(names[seasonID], name)
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT key, COALESCE(value, '') FROM settings WHERE key LIKE $1`, t.table+":%")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key, value string
+			if err := rows.Scan(&key, &value); err != nil {
+				rows.Close()
+				return err
+			}
+			seasonID := strings.TrimPrefix(key, t.table+":")
+			var list []string
+			if err := json.Unmarshal([]byte(value), &list); err != nil {
+				log.Printf("Migration: setting %s is malformed, skipping it: %v", key, err)
+				continue
+			}
+			if !seasons[seasonID] {
+				log.Printf("Migration: setting %s belongs to an unknown season, skipping it", key)
+				continue
+			}
+			for _, name := range list {
+				add(seasonID, name)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT DISTINCT season_id, `+t.column+` FROM games
+			WHERE season_id IS NOT NULL AND `+t.column+` IS NOT NULL AND `+t.column+` <> ''`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var seasonID, name string
+			if err := rows.Scan(&seasonID, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			add(seasonID, name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for seasonID, list := range names {
+			for _, name := range list {
+				id := generateID(t.column)
+				if _, err := tx.ExecContext(ctx, `INSERT INTO `+t.table+` (id, season_id, name) VALUES ($1, $2, $3)`,
+					id, seasonID, name); err != nil {
+					return fmt.Errorf("%s %q: %v", t.column, name, err)
+				}
+				if _, err := tx.ExecContext(ctx, `UPDATE games SET `+t.column+`_id = $1
+					WHERE season_id = $2 AND TRIM(`+t.column+`) = $3`, id, seasonID, name); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM settings WHERE key LIKE $1`, t.table+":%"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyLeaguesToSettings writes every season's leagues and divisions back
+// into the per-season settings lists.
+func copyLeaguesToSettings(ctx context.Context, tx sqlTx) error {
+	for _, t := range leagueTables {
+		rows, err := tx.QueryContext(ctx, `SELECT season_id, name FROM `+t.table+` ORDER BY season_id, name`)
+		if err != nil {
+			return err
+		}
+		names := make(map[string][]string)
+		for rows.Next() {
+			var seasonID, name string
+			if err := rows.Scan(&seasonID, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			names[seasonID] = append(names[seasonID], name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for seasonID, list := range names {
+			_, err := tx.ExecContext(ctx, `INSERT INTO settings (key, value) VALUES ($1, $2)
+				ON CONFLICT (key) DO UPDATE SET value = $2`, t.table+":"+seasonID, toJSONString(list))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// groupOpponentNames creates an opponent for every group of games whose
+// opponent strings share an opponentKey, named after the group's most used
+// spelling (the earliest one on a tie), and links the games to it. Games
+// keep the spelling they were entered with, so rolling back loses nothing.
+// Games whose opponent has no letters or digits are left unlinked.
+func groupOpponentNames(ctx context.Context, tx sqlTx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, opponent FROM games ORDER BY starts_at, id`)
+	if err != nil {
+		return err
+	}
+	type group struct {
+		counts   map[string]int
+		spelling []string
+		gameIDs  []string
+	}
+	groups := make(map[string]*group)
+	var keys []string
+	for rows.Next() {
+		var id, opponent string
+		if err := rows.Scan(&id, &opponent); err != nil {
+			rows.Close()
+			return err
+		}
+		key := opponentKey(opponent)
+		if key == "" {
+			log.Printf("Migration: game %s has no usable opponent name %q, leaving it unlinked", id, opponent)
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{counts: make(map[string]int)}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		name := strings.TrimSpace(opponent)
+		if g.counts[name] == 0 {
+			g.spelling = append(g.spelling, name)
+		}
+		g.counts[name]++
+		g.gameIDs = append(g.gameIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		g := groups[key]
+		name := g.spelling[0]
+		for _, spelling := range g.spelling[1:] {
+			if g.counts[spelling] > g.counts[name] {
+				name = spelling
+			}
+		}
+
+		id := generateID("opponent")
+		if _, err := tx.ExecContext(ctx, `INSERT INTO opponents (id, name, name_key) VALUES ($1, $2, $3)`, id, name, key); err != nil {
+			return fmt.Errorf("opponent %q: %v", name, err)
+		}
+		for _, gameID := range g.gameIDs {
+			if _, err := tx.ExecContext(ctx, `UPDATE games SET opponent_id = $1 WHERE id = $2`, id, gameID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyMemberNamesToAliases gives every member their current name as an
+// alias. Names matching an earlier member's by memberKey are logged and
+// skipped; renaming that member will record them.
+func copyMemberNamesToAliases(ctx context.Context, tx sqlTx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, team_id, name FROM members ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	type member struct{ id, teamID, name string }
+	var members []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.id, &m.teamID, &m.name); err != nil {
+			rows.Close()
+			return err
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	taken := make(map[string]string)
+	for _, m := range members {
+		key := m.teamID + "\x00" + memberKey(m.name)
+		if other, ok := taken[key]; ok {
+			log.Printf("Migration: member %s has the same name as %s, not adding %q as an alias", m.id, other, m.name)
+			continue
+		}
+		taken[key] = m.id
+		if _, err := tx.ExecContext(ctx, `INSERT INTO member_aliases (team_id, alias_key, member_id, alias) VALUES ($1, $2, $3, $4)`,
+			m.teamID, memberKey(m.name), m.id, strings.TrimSpace(m.name)); err != nil {
+			return fmt.Errorf("member %s: %v", m.id, err)
+		}
+	}
+	return nil
+}
+
+// scrubFeedAuditTokens replaces the feed tokens that feed audit events used
+// to record, as their entity ID and in their snapshots, with feedAuditID
+// and snapshots without the token or URL.
+func scrubFeedAuditTokens(ctx context.Context, tx sqlTx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, entity_id, before_data, after_data FROM audit_events WHERE entity_type = 'feed'`)
+	if err != nil {
+		return err
+	}
+	type event struct {
+		id            int64
+		entityID      string
+		before, after []byte
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.id, &e.entityID, &e.before, &e.after); err != nil {
+			rows.Close()
+			return err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	scrub := func(data []byte) (interface{}, error) {
+		if data == nil {
+			return nil, nil
+		}
+		var snapshot map[string]interface{}
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, err
+		}
+		delete(snapshot, "token")
+		delete(snapshot, "url")
+		scrubbed, err := json.Marshal(snapshot)
+		return string(scrubbed), err
+	}
+	for _, e := range events {
+		before, err := scrub(e.before)
+		if err != nil {
+			return fmt.Errorf("audit event %d: %v", e.id, err)
+		}
+		after, err := scrub(e.after)
+		if err != nil {
+			return fmt.Errorf("audit event %d: %v", e.id, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE audit_events SET entity_id = $1, before_data = $2, after_data = $3 WHERE id = $4`,
+			feedAuditID(e.entityID), before, after, e.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
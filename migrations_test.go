@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+// SQLite's history starts with the schema Postgres reached in migration 9,
+// so from there on the two must apply the same versions under the same
+// names, or a database couldn't be told apart from the other's.
+func TestMigrationHistoriesMatch(t *testing.T) {
+	for _, d := range []*sqlDialect{postgresDialect, sqliteDialect} {
+		if err := validateMigrations(d.migrations); err != nil {
+			t.Errorf("%s: %v", d.name, err)
+		}
+	}
+
+	names := func(list []migration) map[int]string {
+		byVersion := make(map[int]string)
+		for _, m := range list {
+			if m.Version > 9 {
+				byVersion[m.Version] = m.Name
+			}
+		}
+		return byVersion
+	}
+	postgres, sqlite := names(postgresDialect.migrations), names(sqliteDialect.migrations)
+	for version, name := range postgres {
+		if sqlite[version] != name {
+			t.Errorf("migration %d is %q on Postgres but %q on SQLite", version, name, sqlite[version])
+		}
+	}
+	for version, name := range sqlite {
+		if _, ok := postgres[version]; !ok {
+			t.Errorf("migration %d_%s is missing on Postgres", version, name)
+		}
+	}
+
+	if first := sqliteDialect.migrations[0].Version; first != 9 {
+		t.Errorf("SQLite history starts at %d, want 9", first)
+	}
+}
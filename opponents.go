@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== OPPONENTS ====================
+
+// Opponent is a clan we play against. Games point at an opponent by ID and
+// show its name; spellings like "Clan X", "clanx" and "Clan X " all match
+// the same opponent, and Aliases lists any other names it goes by.
+//
+// Opponents aren't a team's: the org's teams meet the same clans, so they
+// share one list and one set of contacts and notes, and any team's manager
+// can edit it. What is a team's, the games against an opponent, stays with
+// the team.
+type Opponent struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+	Tag     string   `json:"tag"`
+	Region  string   `json:"region"`
+	Logo    string   `json:"logo"`
+	// Discord is the handle of the clan's contact for scheduling
+	Discord string `json:"discord"`
+	Notes   string `json:"notes"`
+}
+
+var errInvalidOpponent = errors.New("invalid opponent")
+
+// opponentKey is what opponent names and aliases are matched on: lower
+// case, letters and digits only.
+func opponentKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// opponentKeys returns the keys an opponent can be found by, name first.
+func opponentKeys(o *Opponent) []string {
+	keys := []string{opponentKey(o.Name)}
+	for _, alias := range o.Aliases {
+		keys = append(keys, opponentKey(alias))
+	}
+	return keys
+}
+
+// cleanOpponent trims an opponent's fields, drops aliases that match its name
+// or each other, and returns what is wrong with it, or "" if it is fine.
+func cleanOpponent(o *Opponent) string {
+	o.Name = strings.TrimSpace(o.Name)
+	o.Tag = strings.TrimSpace(o.Tag)
+	o.Region = strings.TrimSpace(o.Region)
+	o.Logo = strings.TrimSpace(o.Logo)
+	o.Discord = strings.TrimSpace(o.Discord)
+	o.Notes = strings.TrimSpace(o.Notes)
+
+	if opponentKey(o.Name) == "" {
+		return "Name must contain a letter or digit"
+	}
+
+	seen := map[string]bool{opponentKey(o.Name): true}
+	aliases := []string{}
+	for _, alias := range o.Aliases {
+		alias = strings.TrimSpace(alias)
+		key := opponentKey(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		aliases = append(aliases, alias)
+	}
+	o.Aliases = aliases
+
+	if o.Logo != "" {
+		u, err := url.Parse(o.Logo)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "Logo must be an http or https URL"
+		}
+	}
+	return ""
+}
+
+// findGameOpponent finds the opponent a game request names, by ID or by
+// name or alias. An unknown name gives no opponent and no error.
+func findGameOpponent(opponentID, name string) (*Opponent, error) {
+	if opponentID == "" {
+		return store.FindOpponent(name)
+	}
+	opponent, err := store.GetOpponent(opponentID)
+	if err == nil && opponent == nil {
+		err = fmt.Errorf("%w: unknown opponent %q", errInvalidOpponent, opponentID)
+	}
+	return opponent, err
+}
+
+// draftGameOpponent is findGameOpponent, except that an unknown name gives
+// an unsaved opponent with no ID, for saveGameOpponent to create once the
+// game is written.
+func draftGameOpponent(opponentID, name string) (*Opponent, error) {
+	opponent, err := findGameOpponent(opponentID, name)
+	if err != nil || opponent != nil {
+		return opponent, err
+	}
+
+	opponent = &Opponent{Name: name}
+	if msg := cleanOpponent(opponent); msg != "" {
+		return nil, fmt.Errorf("%w: %s", errInvalidOpponent, msg)
+	}
+	return opponent, nil
+}
+
+// saveGameOpponent creates an opponent from draftGameOpponent that has no
+// ID yet and audits it as actor's; saved ones are returned as they are.
+func saveGameOpponent(actor, teamID string, opponent *Opponent) (*Opponent, error) {
+	if opponent.ID != "" {
+		return opponent, nil
+	}
+
+	newOpponent := *opponent
+	newOpponent.ID = generateID("opponent")
+	created, err := store.CreateOpponent(newOpponent)
+	if errors.Is(err, errDuplicateName) {
+		// Someone else created it first
+		return store.FindOpponent(opponent.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(teamID, actor, "create", auditOpponent, created.ID, nil, created)
+	return created, nil
+}
+
+// handleGetOpponents lists every opponent, which all teams share.
+func handleGetOpponents(w http.ResponseWriter, r *http.Request) {
+	opponents, err := store.ListOpponents()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, opponents)
+}
+
+// handleGetOpponent returns an opponent with every game the team has
+// scheduled against them, across all seasons.
+func handleGetOpponent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	opponentID := vars["id"]
+
+	opponent, err := store.GetOpponent(opponentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if opponent == nil {
+		writeError(w, http.StatusNotFound, "Opponent not found")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	games, err := store.ListOpponentGames(team.ID, opponentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	now := time.Now()
+	played := 0
+	for _, g := range games {
+		if g.StartsAt.Before(now) {
+			played++
+		}
+	}
+
+	localizeForViewer(r, games)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"opponent": opponent,
+		"games":    games,
+		"played":   played,
+		"upcoming": len(games) - played,
+	})
+}
+
+func handleCreateOpponent(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	var opponent Opponent
+	if err := json.NewDecoder(r.Body).Decode(&opponent); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if msg := cleanOpponent(&opponent); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	opponent.ID = generateID("opponent")
+
+	created, err := store.CreateOpponent(opponent)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "Another opponent already uses that name or alias")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "create", auditOpponent, created.ID, nil, created)
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdateOpponent changes an opponent's details. Renaming it renames
+// it on every game against them, every team's.
+func handleUpdateOpponent(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	opponentID := vars["id"]
+
+	before, err := store.GetOpponent(opponentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Opponent not found")
+		return
+	}
+
+	// Fields missing from the body keep their current values
+	opponent := *before
+	opponent.Aliases = append([]string{}, before.Aliases...)
+	if err := json.NewDecoder(r.Body).Decode(&opponent); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	opponent.ID = before.ID
+	if msg := cleanOpponent(&opponent); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	updated, err := store.UpdateOpponent(opponent)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "Another opponent already uses that name or alias")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditOpponent, opponentID, before, updated)
+
+	writeJSON(w, http.StatusOK, updated)
+}
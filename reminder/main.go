@@ -10,18 +10,29 @@ import (
 	"os"
 	"strings"
 	"time"
+	_ "time/tzdata"
 
 	_ "github.com/lib/pq"
 )
 
 // Game represents a game that needs reminders
 type Game struct {
-	ID       int       `json:"id"`
-	Date     time.Time `json:"date"`
+	ID       string    `json:"id"`
+	StartsAt time.Time `json:"startsAt"`
+	TimeZone string    `json:"timeZone"`
 	Opponent string    `json:"opponent"`
 	Roster   []string  `json:"roster"`
 }
 
+// localStart returns the game's start in its own time zone
+func (g Game) localStart() time.Time {
+	loc, err := time.LoadLocation(g.TimeZone)
+	if err != nil {
+		loc, _ = time.LoadLocation("America/New_York")
+	}
+	return g.StartsAt.In(loc)
+}
+
 // User represents a linked Discord user
 type User struct {
 	DiscordID   string `json:"discord_id"`
@@ -44,6 +55,13 @@ func main() {
 		log.Fatal("DISCORD_BOT_TOKEN environment variable is required")
 	}
 
+	// Tables live in the go_calendar schema created by the web service
+	if strings.Contains(dbURL, "?") {
+		dbURL += "&search_path=go_calendar"
+	} else {
+		dbURL += "?search_path=go_calendar"
+	}
+
 	// Connect to database
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
@@ -72,17 +90,17 @@ func main() {
 
 	// Process each game
 	for _, game := range games {
-		log.Printf("Processing game %d vs %s on %s", game.ID, game.Opponent, game.Date.Format("Jan 2, 2006"))
+		log.Printf("Processing game %s vs %s on %s", game.ID, game.Opponent, game.localStart().Format("Jan 2, 2006"))
 
 		if len(game.Roster) == 0 {
-			log.Printf("Game %d has no roster set, skipping", game.ID)
+			log.Printf("Game %s has no roster set, skipping", game.ID)
 			continue
 		}
 
 		// Get Discord IDs for rostered players
 		discordIDs, err := getDiscordIDsForPlayers(db, game.Roster)
 		if err != nil {
-			log.Printf("Error getting Discord IDs for game %d: %v", game.ID, err)
+			log.Printf("Error getting Discord IDs for game %s: %v", game.ID, err)
 			continue
 		}
 
@@ -97,11 +115,11 @@ func main() {
 			}
 		}
 
-		log.Printf("Sent %d/%d reminders for game %d", sentCount, len(discordIDs), game.ID)
+		log.Printf("Sent %d/%d reminders for game %s", sentCount, len(discordIDs), game.ID)
 
 		// Mark game as reminded
 		if err := markGameReminded(db, game.ID); err != nil {
-			log.Printf("Failed to mark game %d as reminded: %v", game.ID, err)
+			log.Printf("Failed to mark game %s as reminded: %v", game.ID, err)
 		}
 	}
 
@@ -116,12 +134,14 @@ func getPendingGames(db *sql.DB) ([]Game, error) {
 	endWindow := now.Add(24*time.Hour + 30*time.Minute)
 
 	query := `
-		SELECT id, date, opponent, roster
+		SELECT id, starts_at, time_zone, opponent,
+			COALESCE((SELECT string_agg(p.member_id, ',' ORDER BY p.position)
+				FROM game_participants p WHERE p.game_id = games.id AND p.role = 'roster'), '')
 		FROM games
-		WHERE date >= $1 AND date <= $2
+		WHERE starts_at >= $1 AND starts_at <= $2
 		AND reminded = false
-		AND roster IS NOT NULL
-		AND array_length(roster, 1) > 0
+		AND deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM game_participants p WHERE p.game_id = games.id AND p.role = 'roster')
 	`
 
 	rows, err := db.Query(query, startWindow, endWindow)
@@ -133,21 +153,16 @@ func getPendingGames(db *sql.DB) ([]Game, error) {
 	var games []Game
 	for rows.Next() {
 		var game Game
-		var rosterJSON []byte
+		var roster string
 
-		if err := rows.Scan(&game.ID, &game.Date, &game.Opponent, &rosterJSON); err != nil {
+		if err := rows.Scan(&game.ID, &game.StartsAt, &game.TimeZone, &game.Opponent, &roster); err != nil {
 			log.Printf("Error scanning row: %v", err)
 			continue
 		}
 
-		// Parse roster array
-		if len(rosterJSON) > 0 {
-			// PostgreSQL arrays come as {val1,val2,...}
-			rosterStr := string(rosterJSON)
-			rosterStr = strings.Trim(rosterStr, "{}")
-			if rosterStr != "" {
-				game.Roster = strings.Split(rosterStr, ",")
-			}
+		// Rostered member IDs come back comma-joined in roster order
+		if roster != "" {
+			game.Roster = strings.Split(roster, ",")
 		}
 
 		games = append(games, game)
@@ -171,9 +186,8 @@ func getDiscordIDsForPlayers(db *sql.DB, playerIDs []string) ([]string, error) {
 	}
 
 	query := fmt.Sprintf(`
-		SELECT discord_id FROM users
+		SELECT discord_id FROM team_users
 		WHERE player_id IN (%s)
-		AND discord_id IS NOT NULL
 	`, strings.Join(placeholders, ","))
 
 	rows, err := db.Query(query, args...)
@@ -202,13 +216,14 @@ func sendDiscordDM(botToken, userID string, game Game) error {
 		return fmt.Errorf("failed to create DM channel: %w", err)
 	}
 
-	// Format the reminder message
-	gameTime := game.Date.Format("Monday, January 2 at 3:04 PM")
+	// Discord shows the timestamp in each reader's own time zone
+	start := game.StartsAt.Unix()
+	gameTime := fmt.Sprintf("<t:%d:F> (<t:%d:R>)", start, start)
 	message := fmt.Sprintf(
 		"**Game Reminder!**\n\n"+
 			"You're on the roster for tomorrow's game!\n\n"+
 			"**Opponent:** %s\n"+
-			"**When:** %s ET\n\n"+
+			"**When:** %s\n\n"+
 			"Good luck out there!",
 		game.Opponent,
 		gameTime,
@@ -288,7 +303,7 @@ func sendMessage(botToken, channelID, content string) error {
 }
 
 // markGameReminded marks a game as having been reminded
-func markGameReminded(db *sql.DB, gameID int) error {
+func markGameReminded(db *sql.DB, gameID string) error {
 	_, err := db.Exec("UPDATE games SET reminded = true WHERE id = $1", gameID)
 	return err
 }
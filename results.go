@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== RESULTS & STANDINGS ====================
+
+// GameResult is how a played game went. Scores are ours and theirs; a
+// forfeit records which side didn't show.
+type GameResult struct {
+	OurScore   int           `json:"ourScore"`
+	TheirScore int           `json:"theirScore"`
+	Outcome    string        `json:"outcome"`
+	Forfeit    string        `json:"forfeit,omitempty"`
+	Rounds     []ResultRound `json:"rounds,omitempty"`
+	RecordedBy string        `json:"recordedBy,omitempty"`
+	RecordedAt time.Time     `json:"recordedAt"`
+}
+
+// ResultRound is the score of one round or map of a game.
+type ResultRound struct {
+	Name       string `json:"name,omitempty"`
+	OurScore   int    `json:"ourScore"`
+	TheirScore int    `json:"theirScore"`
+}
+
+// Result outcomes, from our side
+const (
+	outcomeWin  = "win"
+	outcomeLoss = "loss"
+	outcomeDraw = "draw"
+)
+
+// Forfeit values: the side that forfeited
+const (
+	forfeitUs   = "us"
+	forfeitThem = "them"
+)
+
+// Standings points per outcome
+const (
+	pointsForWin  = 3
+	pointsForDraw = 1
+	pointsForLoss = 0
+)
+
+// resultInput is the body of a result submission. Scores may be left out
+// when rounds are given, which are then added up, or for a forfeit.
+type resultInput struct {
+	OurScore   *int          `json:"ourScore"`
+	TheirScore *int          `json:"theirScore"`
+	Outcome    string        `json:"outcome"`
+	Forfeit    string        `json:"forfeit"`
+	Rounds     []ResultRound `json:"rounds"`
+}
+
+// buildResult turns a submission into a result and returns what is wrong
+// with it, or "" if it is fine. The outcome follows from the forfeit or the
+// score; one given explicitly must agree, except that a level score can be
+// settled either way by a tiebreak.
+func buildResult(in resultInput) (*GameResult, string) {
+	result := &GameResult{Forfeit: strings.TrimSpace(in.Forfeit), Rounds: []ResultRound{}}
+	switch result.Forfeit {
+	case "", forfeitUs, forfeitThem:
+	default:
+		return nil, `Forfeit must be "us" or "them"`
+	}
+
+	ourTotal, theirTotal := 0, 0
+	for _, round := range in.Rounds {
+		round.Name = strings.TrimSpace(round.Name)
+		if round.OurScore < 0 || round.TheirScore < 0 {
+			return nil, "Round scores can't be negative"
+		}
+		ourTotal += round.OurScore
+		theirTotal += round.TheirScore
+		result.Rounds = append(result.Rounds, round)
+	}
+
+	switch {
+	case in.OurScore != nil && in.TheirScore != nil:
+		result.OurScore, result.TheirScore = *in.OurScore, *in.TheirScore
+	case in.OurScore != nil || in.TheirScore != nil:
+		return nil, "Both ourScore and theirScore are required"
+	case len(in.Rounds) > 0:
+		result.OurScore, result.TheirScore = ourTotal, theirTotal
+	case result.Forfeit == "":
+		return nil, "A score, rounds or a forfeit is required"
+	}
+	if result.OurScore < 0 || result.TheirScore < 0 {
+		return nil, "Scores can't be negative"
+	}
+
+	switch {
+	case result.Forfeit == forfeitThem:
+		result.Outcome = outcomeWin
+	case result.Forfeit == forfeitUs:
+		result.Outcome = outcomeLoss
+	case result.OurScore > result.TheirScore:
+		result.Outcome = outcomeWin
+	case result.OurScore < result.TheirScore:
+		result.Outcome = outcomeLoss
+	default:
+		result.Outcome = outcomeDraw
+	}
+
+	outcome := strings.ToLower(strings.TrimSpace(in.Outcome))
+	switch outcome {
+	case "":
+	case outcomeWin, outcomeLoss, outcomeDraw:
+		if outcome != result.Outcome {
+			if result.Outcome != outcomeDraw || result.Forfeit != "" {
+				return nil, fmt.Sprintf("Outcome %q doesn't match the result", outcome)
+			}
+			result.Outcome = outcome
+		}
+	default:
+		return nil, `Outcome must be "win", "loss" or "draw"`
+	}
+	return result, ""
+}
+
+// Standing is a team's record over a set of played games.
+type Standing struct {
+	LeagueID     string `json:"leagueId,omitempty"`
+	League       string `json:"league,omitempty"`
+	DivisionID   string `json:"divisionId,omitempty"`
+	Division     string `json:"division,omitempty"`
+	Played       int    `json:"played"`
+	Wins         int    `json:"wins"`
+	Losses       int    `json:"losses"`
+	Draws        int    `json:"draws"`
+	Forfeits     int    `json:"forfeits"`
+	Points       int    `json:"points"`
+	ScoreFor     int    `json:"scoreFor"`
+	ScoreAgainst int    `json:"scoreAgainst"`
+	Differential int    `json:"differential"`
+}
+
+func (s *Standing) add(r *GameResult) {
+	s.Played++
+	switch r.Outcome {
+	case outcomeWin:
+		s.Wins++
+		s.Points += pointsForWin
+	case outcomeLoss:
+		s.Losses++
+		s.Points += pointsForLoss
+	case outcomeDraw:
+		s.Draws++
+		s.Points += pointsForDraw
+	}
+	if r.Forfeit != "" {
+		s.Forfeits++
+	}
+	s.ScoreFor += r.OurScore
+	s.ScoreAgainst += r.TheirScore
+	s.Differential = s.ScoreFor - s.ScoreAgainst
+}
+
+// Standings is a record overall and broken down by league and division.
+type Standings struct {
+	Overall   Standing   `json:"overall"`
+	Leagues   []Standing `json:"leagues"`
+	Divisions []Standing `json:"divisions"`
+}
+
+// computeStandings adds up the results of games; games without one are
+// skipped. Leagues and divisions are listed by points, then differential,
+// then name.
+func computeStandings(games []Game) Standings {
+	standings := Standings{Leagues: []Standing{}, Divisions: []Standing{}}
+	leagues := make(map[string]*Standing)
+	divisions := make(map[string]*Standing)
+	var leagueOrder, divisionOrder []string
+
+	for i := range games {
+		g := &games[i]
+		if g.Result == nil {
+			continue
+		}
+		standings.Overall.add(g.Result)
+		if g.LeagueID != "" {
+			s, ok := leagues[g.LeagueID]
+			if !ok {
+				s = &Standing{LeagueID: g.LeagueID, League: g.League}
+				leagues[g.LeagueID] = s
+				leagueOrder = append(leagueOrder, g.LeagueID)
+			}
+			s.add(g.Result)
+		}
+		if g.DivisionID != "" {
+			s, ok := divisions[g.DivisionID]
+			if !ok {
+				s = &Standing{LeagueID: g.LeagueID, League: g.League, DivisionID: g.DivisionID, Division: g.Division}
+				divisions[g.DivisionID] = s
+				divisionOrder = append(divisionOrder, g.DivisionID)
+			}
+			s.add(g.Result)
+		}
+	}
+
+	for _, id := range leagueOrder {
+		standings.Leagues = append(standings.Leagues, *leagues[id])
+	}
+	for _, id := range divisionOrder {
+		standings.Divisions = append(standings.Divisions, *divisions[id])
+	}
+	rank := func(list []Standing, name func(s *Standing) string) {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := &list[i], &list[j]
+			if a.Points != b.Points {
+				return a.Points > b.Points
+			}
+			if a.Differential != b.Differential {
+				return a.Differential > b.Differential
+			}
+			return name(a) < name(b)
+		})
+	}
+	rank(standings.Leagues, func(s *Standing) string { return s.League })
+	rank(standings.Divisions, func(s *Standing) string { return s.Division })
+	return standings
+}
+
+// handleSetResult records or replaces a game's result.
+func handleSetResult(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input resultInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	result, msg := buildResult(input)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	game, err := getTeamGame(team.ID, gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	if game.StartsAt.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, "Results can only be recorded once the game has started")
+		return
+	}
+
+	result.RecordedBy = session.DiscordID
+	result.RecordedAt = time.Now().UTC()
+	updated, err := store.SetGameResult(gameID, result, version)
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, gameID)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if updated == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "set_result", auditGame, gameID, game, updated)
+
+	writeGame(w, r, http.StatusOK, updated)
+}
+
+// handleClearResult removes a result recorded by mistake.
+func handleClearResult(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	game, err := getTeamGame(team.ID, gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	if game.Result == nil {
+		writeGame(w, r, http.StatusOK, game)
+		return
+	}
+
+	updated, err := store.SetGameResult(gameID, nil, version)
+	if errors.Is(err, errVersionConflict) {
+		writeVersionConflict(w, gameID)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if updated == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "clear_result", auditGame, gameID, game, updated)
+
+	writeGame(w, r, http.StatusOK, updated)
+}
+
+// handleGetStandings adds up the results of the season's games. It takes
+// the same filters as /api/games, so standings can be narrowed to a date
+// range, a game mode or an opponent.
+func handleGetStandings(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+
+	filter, err := parseGameFilter(r, team.ID, seasonID, 0)
+	if err != nil {
+		writeGameFilterError(w, err)
+		return
+	}
+	filter.Limit = 0
+	games, _, err := store.FindGames(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, computeStandings(games))
+}
+
+// outcomeLabels and outcomeColors style the result embed
+var outcomeLabels = map[string]string{outcomeWin: "🏆 Victory", outcomeLoss: "💀 Defeat", outcomeDraw: "🤝 Draw"}
+var outcomeColors = map[string]int{outcomeWin: 0x2ecc71, outcomeLoss: 0xe74c3c, outcomeDraw: 0xf1c40f}
+
+// handlePostResultToDiscord posts a game's result to the team's channel,
+// the follow-up to the roster post from handlePostToDiscord.
+func handlePostResultToDiscord(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	webhook, _ := store.GetSetting(teamSettingKey(team.ID, "discord_webhook"))
+	if webhook == "" {
+		writeError(w, http.StatusBadRequest, "Discord webhook not configured")
+		return
+	}
+
+	game, err := getTeamGame(team.ID, gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	result := game.Result
+	if result == nil {
+		writeError(w, http.StatusBadRequest, "Game has no result yet")
+		return
+	}
+
+	score := fmt.Sprintf("**%d – %d**", result.OurScore, result.TheirScore)
+	switch result.Forfeit {
+	case forfeitThem:
+		score += fmt.Sprintf(" (%s forfeited)", game.Opponent)
+	case forfeitUs:
+		score += " (we forfeited)"
+	}
+
+	fields := []map[string]interface{}{
+		{"name": "⚔️ Opponent", "value": game.Opponent, "inline": true},
+		{"name": "📊 Score", "value": score, "inline": true},
+	}
+	if game.League != "" {
+		competition := game.League
+		if game.Division != "" {
+			competition += " · " + game.Division
+		}
+		fields = append(fields, map[string]interface{}{"name": "🏅 League", "value": competition, "inline": true})
+	}
+	if len(result.Rounds) > 0 {
+		var lines []string
+		for i, round := range result.Rounds {
+			name := round.Name
+			if name == "" {
+				name = fmt.Sprintf("Round %d", i+1)
+			}
+			lines = append(lines, fmt.Sprintf("%s: %d – %d", name, round.OurScore, round.TheirScore))
+		}
+		fields = append(fields, map[string]interface{}{"name": "🗺️ Rounds", "value": strings.Join(lines, "\n"), "inline": false})
+	}
+	if len(game.Roster) > 0 {
+		var names []string
+		for _, pid := range game.Roster {
+			names = append(names, getMemberName(pid))
+		}
+		fields = append(fields, map[string]interface{}{"name": "👥 Played", "value": strings.Join(names, ", "), "inline": false})
+	}
+
+	embed := map[string]interface{}{
+		"title":     fmt.Sprintf("%s vs %s", outcomeLabels[result.Outcome], game.Opponent),
+		"color":     outcomeColors[result.Outcome],
+		"fields":    fields,
+		"url":       teamGameLink(team, gameID),
+		"footer":    map[string]string{"text": fmt.Sprintf("%s · %s", team.Name, formatGameDate(game))},
+		"timestamp": result.RecordedAt.UTC().Format(time.RFC3339),
+	}
+
+	payload := map[string]interface{}{
+		"username": "Game Over Bot",
+		"embeds":   []map[string]interface{}{embed},
+	}
+
+	payloadBytes, _ := json.Marshal(payload)
+	resp, err := http.Post(webhook, "application/json", bytes.NewReader(payloadBytes))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to post to Discord: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		writeError(w, http.StatusInternalServerError, "Discord API error: "+string(body))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== SEASONS ====================
+
+// Season is one run of the league for a team. Each team has exactly one
+// active season at a time; its new games belong to it and list endpoints
+// show it unless asked for another. Starting a new season archives the
+// team's active one.
+type Season struct {
+	ID         string     `json:"id"`
+	TeamID     string     `json:"teamId"`
+	Name       string     `json:"name"`
+	StartsAt   time.Time  `json:"startsAt"`
+	EndsAt     *time.Time `json:"endsAt,omitempty"`
+	Active     bool       `json:"active"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+}
+
+// SeasonUpdate holds the season fields a manager can change; nil fields are
+// left alone.
+type SeasonUpdate struct {
+	Name     *string
+	StartsAt *time.Time
+	EndsAt   *time.Time
+}
+
+var errUnknownSeason = errors.New("season not found")
+
+// allSeasons is the ?season= value that lists every season at once.
+const allSeasons = "all"
+
+// seedSeasonIfEmpty starts a team's first season so there is always an
+// active one for new games to join.
+func seedSeasonIfEmpty(s Store, teamID string) error {
+	seasons, err := s.ListSeasons(teamID)
+	if err != nil {
+		return err
+	}
+	if len(seasons) > 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	_, err = s.StartSeason(Season{
+		ID:       generateID("season"),
+		TeamID:   teamID,
+		Name:     fmt.Sprintf("%d Season", now.Year()),
+		StartsAt: now,
+	})
+	return err
+}
+
+// activeSeasonID returns the team's active season's ID, or "" if there is
+// none.
+func activeSeasonID(teamID string) (string, error) {
+	season, err := store.GetActiveSeason(teamID)
+	if err != nil || season == nil {
+		return "", err
+	}
+	return season.ID, nil
+}
+
+// requestedSeason resolves a list endpoint's ?season= parameter to a season
+// ID of the team: missing means the active season and "all" means "" (every
+// season).
+func requestedSeason(r *http.Request, teamID string) (string, error) {
+	id := r.URL.Query().Get("season")
+	switch id {
+	case "":
+		return activeSeasonID(teamID)
+	case allSeasons:
+		return "", nil
+	}
+
+	season, err := getTeamSeason(teamID, id)
+	if err != nil {
+		return "", err
+	}
+	if season == nil {
+		return "", errUnknownSeason
+	}
+	return season.ID, nil
+}
+
+// writeSeasonError reports a failure from requestedSeason.
+func writeSeasonError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnknownSeason) {
+		writeError(w, http.StatusNotFound, "Season not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// parseSeasonTime accepts an RFC 3339 timestamp or a plain date.
+func parseSeasonTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func handleGetSeasons(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasons, err := store.ListSeasons(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, seasons)
+}
+
+func handleGetCurrentSeason(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	season, err := store.GetActiveSeason(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if season == nil {
+		writeError(w, http.StatusNotFound, "No active season")
+		return
+	}
+	writeJSON(w, http.StatusOK, season)
+}
+
+// handleStartSeason archives the active season and starts a new one, which
+// begins with the previous season's leagues and divisions.
+func handleStartSeason(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	var body struct {
+		Name     string `json:"name"`
+		StartsAt string `json:"startsAt"`
+		EndsAt   string `json:"endsAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	season := Season{ID: generateID("season"), TeamID: team.ID, Name: body.Name, StartsAt: time.Now().UTC()}
+	if body.StartsAt != "" {
+		t, err := parseSeasonTime(body.StartsAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid startsAt")
+			return
+		}
+		season.StartsAt = t
+	}
+	if body.EndsAt != "" {
+		t, err := parseSeasonTime(body.EndsAt)
+		if err != nil || !t.After(season.StartsAt) {
+			writeError(w, http.StatusBadRequest, "endsAt must be a time after startsAt")
+			return
+		}
+		season.EndsAt = &t
+	}
+
+	previous, err := store.GetActiveSeason(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	started, err := store.StartSeason(season)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if previous != nil {
+		archived, _ := store.GetSeason(previous.ID)
+		recordAudit(team.ID, session.DiscordID, "archive", auditSeason, previous.ID, previous, archived)
+
+		if err := copySeasonLeagues(previous.ID, started.ID); err != nil {
+			log.Printf("Error carrying leagues over to season %s: %v", started.ID, err)
+		}
+	}
+	recordAudit(team.ID, session.DiscordID, "create", auditSeason, started.ID, nil, started)
+
+	writeJSON(w, http.StatusCreated, started)
+}
+
+func handleUpdateSeason(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	seasonID := vars["id"]
+
+	var body struct {
+		Name     *string `json:"name"`
+		StartsAt *string `json:"startsAt"`
+		EndsAt   *string `json:"endsAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var update SeasonUpdate
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		if name == "" {
+			writeError(w, http.StatusBadRequest, "Name is required")
+			return
+		}
+		update.Name = &name
+	}
+	if body.StartsAt != nil {
+		t, err := parseSeasonTime(*body.StartsAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid startsAt")
+			return
+		}
+		update.StartsAt = &t
+	}
+	if body.EndsAt != nil {
+		t, err := parseSeasonTime(*body.EndsAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid endsAt")
+			return
+		}
+		update.EndsAt = &t
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	before, err := getTeamSeason(team.ID, seasonID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Season not found")
+		return
+	}
+
+	startsAt, endsAt := before.StartsAt, before.EndsAt
+	if update.StartsAt != nil {
+		startsAt = *update.StartsAt
+	}
+	if update.EndsAt != nil {
+		endsAt = update.EndsAt
+	}
+	if endsAt != nil && !endsAt.After(startsAt) {
+		writeError(w, http.StatusBadRequest, "endsAt must be a time after startsAt")
+		return
+	}
+
+	season, err := store.UpdateSeason(seasonID, update)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditSeason, seasonID, before, season)
+
+	writeJSON(w, http.StatusOK, season)
+}
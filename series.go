@@ -0,0 +1,808 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== RECURRING SERIES ====================
+
+// Series is a set of games generated from an iCalendar RRULE, such as our
+// Tuesday and Thursday wars. Its games point back at it, so they can be
+// changed or deleted together.
+type Series struct {
+	ID       string    `json:"id"`
+	TeamID   string    `json:"teamId"`
+	SeasonID string    `json:"seasonId,omitempty"`
+	RRule    string    `json:"rrule"`
+	StartsAt time.Time `json:"startsAt"`
+	TimeZone string    `json:"timeZone"`
+	// Exceptions are the local dates (YYYY-MM-DD) the rule skips
+	Exceptions []string  `json:"exceptions"`
+	CreatedBy  string    `json:"createdBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Limits on how many games one series makes and how far out they go. Rules
+// without COUNT or UNTIL run to the end of the season, or seriesHorizon if
+// it has none.
+const (
+	maxSeriesOccurrences = 200
+	maxSeriesYears       = 5
+	maxRRuleInterval     = 365
+	seriesHorizon        = 26 * 7 * 24 * time.Hour
+)
+
+var errInvalidSeries = errors.New("invalid series")
+
+func invalidSeries(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errInvalidSeries, fmt.Sprintf(format, args...))
+}
+
+// recurrenceRule is the part of RFC 5545's RRULE we support: DAILY, WEEKLY
+// and MONTHLY rules with INTERVAL, COUNT, UNTIL and, for weekly ones, BYDAY.
+type recurrenceRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []time.Weekday
+}
+
+var rruleWeekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// parseRRule reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+// with or without the "RRULE:" prefix. An UNTIL without a "Z" is
+// wall-clock in loc, and a date alone takes in that whole day.
+func parseRRule(value string, loc *time.Location) (recurrenceRule, error) {
+	rule := recurrenceRule{Interval: 1}
+	value = strings.TrimSpace(value)
+	if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+		value = value[6:]
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(part, "=")
+		name, v = strings.ToUpper(strings.TrimSpace(name)), strings.ToUpper(strings.TrimSpace(v))
+		if !ok || v == "" {
+			return rule, invalidSeries("malformed rule part %q", part)
+		}
+		if seen[name] {
+			return rule, invalidSeries("%s is given twice", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			if v != "DAILY" && v != "WEEKLY" && v != "MONTHLY" {
+				return rule, invalidSeries("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+			rule.Freq = v
+		case "INTERVAL":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxRRuleInterval {
+				return rule, invalidSeries("INTERVAL must be a number from 1 to %d", maxRRuleInterval)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return rule, invalidSeries("COUNT must be a positive number")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRRuleUntil(v, loc)
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(v, ",") {
+				i := indexOf(rruleWeekdays, day)
+				if i < 0 {
+					return rule, invalidSeries("unknown BYDAY day %q", day)
+				}
+				rule.ByDay = append(rule.ByDay, time.Weekday(i))
+			}
+		case "WKST":
+			// Weeks start on Monday, the default; nothing else is supported
+			if v != "MO" {
+				return rule, invalidSeries("only WKST=MO is supported")
+			}
+		default:
+			return rule, invalidSeries("%s is not supported", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, invalidSeries("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return rule, invalidSeries("COUNT and UNTIL can't both be given")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != "WEEKLY" {
+		return rule, invalidSeries("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	return rule, nil
+}
+
+func parseRRuleUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t.UTC(), nil
+	}
+	if d, err := time.Parse("20060102", value); err == nil {
+		return time.Date(d.Year(), d.Month(), d.Day(), 23, 59, 59, 0, loc).UTC(), nil
+	}
+	return time.Time{}, invalidSeries("UNTIL must be a date or date-time such as 20250630 or 20250630T235959Z")
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// String writes the rule back out, with UNTIL in UTC.
+func (rule recurrenceRule) String() string {
+	parts := []string{"FREQ=" + rule.Freq}
+	if rule.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rule.Interval))
+	}
+	if rule.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(rule.Count))
+	}
+	if rule.Until != nil {
+		parts = append(parts, "UNTIL="+rule.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(rule.ByDay) > 0 {
+		days := make([]string, len(rule.ByDay))
+		for i, day := range rule.ByDay {
+			days[i] = rruleWeekdays[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// occurrences lists the starts the rule gives a series starting at start,
+// a wall-clock time in the series' zone, up to and including end if it is
+// set; rules with COUNT or UNTIL end by themselves. Each one is at start's
+// clock time, so games stay put across daylight saving changes. Excepted
+// dates still count towards COUNT, as in RFC 5545. A rule still going
+// maxSeriesYears after start is invalid.
+func (rule recurrenceRule) occurrences(start, end time.Time) ([]time.Time, error) {
+	if rule.Until != nil && (end.IsZero() || rule.Until.Before(end)) {
+		end = *rule.Until
+	}
+
+	byDay := make(map[time.Weekday]bool)
+	for _, day := range rule.ByDay {
+		byDay[day] = true
+	}
+	if len(byDay) == 0 {
+		byDay[start.Weekday()] = true
+	}
+
+	loc := start.Location()
+	first := civilDate(start)
+	// Weeks run Monday to Sunday
+	firstMonday := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+	last := first.AddDate(maxSeriesYears, 0, 0)
+
+	var starts []time.Time
+	for day := first; ; day = day.AddDate(0, 0, 1) {
+		t := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		if (!end.IsZero() && t.After(end)) || (rule.Count > 0 && len(starts) == rule.Count) {
+			return starts, nil
+		}
+		if day.After(last) {
+			return nil, invalidSeries("a series can run for at most %d years", maxSeriesYears)
+		}
+
+		var matches bool
+		switch rule.Freq {
+		case "DAILY":
+			matches = daysBetween(first, day)%rule.Interval == 0
+		case "WEEKLY":
+			matches = byDay[day.Weekday()] && (daysBetween(firstMonday, day)/7)%rule.Interval == 0
+		case "MONTHLY":
+			months := (day.Year()-first.Year())*12 + int(day.Month()) - int(first.Month())
+			matches = day.Day() == first.Day() && months%rule.Interval == 0
+		}
+		if !matches {
+			continue
+		}
+		if len(starts) == maxSeriesOccurrences {
+			return nil, invalidSeries("a series can have at most %d games", maxSeriesOccurrences)
+		}
+		starts = append(starts, t)
+	}
+}
+
+// civilDate is t's calendar date as midnight UTC, for counting days
+// without daylight saving getting in the way.
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween counts the calendar days from one civil date to another.
+// It works off Unix seconds, as a Duration runs out after 292 years.
+func daysBetween(from, to time.Time) int {
+	return int((to.Unix() - from.Unix()) / (24 * 60 * 60))
+}
+
+// cleanExceptions checks the exception dates and returns them sorted,
+// without duplicates.
+func cleanExceptions(dates []string) ([]string, error) {
+	seen := make(map[string]bool)
+	cleaned := []string{}
+	for _, date := range dates {
+		date = strings.TrimSpace(date)
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, invalidSeries("exception %q must be a date such as 2025-03-04", date)
+		}
+		if !seen[date] {
+			seen[date] = true
+			cleaned = append(cleaned, date)
+		}
+	}
+	sort.Strings(cleaned)
+	return cleaned, nil
+}
+
+// occurrenceAt is the start the series gave a game, or its start for games
+// outside a series.
+func occurrenceAt(g *Game) time.Time {
+	if g.OccurrenceAt != nil {
+		return *g.OccurrenceAt
+	}
+	return g.StartsAt
+}
+
+// Scopes of a change to a game in a series, from ?scope=
+const (
+	scopeThis      = "this"
+	scopeFollowing = "following"
+	scopeAll       = "all"
+)
+
+// requestedScope reads ?scope= for a change to g; it defaults to the game
+// alone, the only scope games outside a series take.
+func requestedScope(r *http.Request, g *Game) (string, error) {
+	switch scope := r.URL.Query().Get("scope"); scope {
+	case "", scopeThis:
+		return scopeThis, nil
+	case scopeFollowing, scopeAll:
+		if g.SeriesID == "" {
+			return "", invalidSeries("game is not part of a series")
+		}
+		return scope, nil
+	default:
+		return "", invalidSeries("scope must be this, following or all")
+	}
+}
+
+// scopedSeriesGames returns the other games a change to g in scope takes
+// in: the series' games that haven't started, from g's occurrence on for
+// scopeFollowing. Games already played are left as they were.
+func scopedSeriesGames(g *Game, scope string, now time.Time) ([]Game, error) {
+	games, _, err := store.FindGames(GameFilter{TeamID: g.TeamID, SeriesID: g.SeriesID, Status: gameUpcoming, Now: now})
+	if err != nil {
+		return nil, err
+	}
+	var scoped []Game
+	for i := range games {
+		other := &games[i]
+		if other.ID == g.ID || (scope == scopeFollowing && occurrenceAt(other).Before(occurrenceAt(g))) {
+			continue
+		}
+		scoped = append(scoped, *other)
+	}
+	return scoped, nil
+}
+
+// changedFields narrows patch to the columns it changes on g.
+func changedFields(g *Game, patch GamePatch) GamePatch {
+	if patch.StartsAt != nil && patch.StartsAt.Equal(g.StartsAt) {
+		patch.StartsAt = nil
+	}
+	if patch.TimeZone != nil && *patch.TimeZone == g.TimeZone {
+		patch.TimeZone = nil
+	}
+	if patch.Opponent != nil && patch.OpponentID != nil && *patch.Opponent == g.Opponent && *patch.OpponentID == g.OpponentID {
+		patch.Opponent, patch.OpponentID = nil, nil
+	}
+	if patch.LeagueID != nil && *patch.LeagueID == g.LeagueID {
+		patch.LeagueID = nil
+	}
+	if patch.DivisionID != nil && *patch.DivisionID == g.DivisionID {
+		patch.DivisionID = nil
+	}
+	if patch.GameMode != nil && *patch.GameMode == g.GameMode {
+		patch.GameMode = nil
+	}
+	if patch.TeamSize != nil && *patch.TeamSize == g.TeamSize {
+		patch.TeamSize = nil
+	}
+	if patch.Notes != nil && *patch.Notes == g.Notes {
+		patch.Notes = nil
+	}
+	return patch
+}
+
+// seriesRetime is how a new start for one game of a series moves the rest:
+// by as many days as it moved, to its new clock time and zone, so "every
+// Tuesday at 8" can become "every Wednesday at 9" in one go.
+type seriesRetime struct {
+	days  int
+	moved time.Time // the game's new start, in its new zone
+}
+
+// newSeriesRetime returns how changed moves before's series, or false if
+// it doesn't retime before.
+func newSeriesRetime(before *Game, changed GamePatch) (seriesRetime, bool) {
+	if changed.StartsAt == nil && changed.TimeZone == nil {
+		return seriesRetime{}, false
+	}
+
+	timeZone := before.TimeZone
+	if changed.TimeZone != nil {
+		timeZone = *changed.TimeZone
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	startsAt := before.StartsAt
+	if changed.StartsAt != nil {
+		startsAt = *changed.StartsAt
+	}
+	moved := startsAt.In(loc)
+	return seriesRetime{days: daysBetween(civilDate(gameLocalTime(before)), civilDate(moved)), moved: moved}, true
+}
+
+// apply moves local, a wall-clock time, and returns it in UTC.
+func (m seriesRetime) apply(local time.Time) time.Time {
+	return time.Date(local.Year(), local.Month(), local.Day()+m.days, m.moved.Hour(), m.moved.Minute(), 0, 0, m.moved.Location()).UTC()
+}
+
+// seriesPatch carries a change made to before over to another game of its
+// series, moving it as seriesRetime does.
+func seriesPatch(before *Game, changed GamePatch, other *Game) GamePatch {
+	patch := changed
+	patch.StartsAt, patch.TimeZone = nil, nil
+	retime, ok := newSeriesRetime(before, changed)
+	if !ok {
+		return patch
+	}
+
+	otherStart := retime.apply(gameLocalTime(other))
+	if !otherStart.Equal(other.StartsAt) {
+		patch.StartsAt = &otherStart
+	}
+	if timeZone := retime.moved.Location().String(); timeZone != other.TimeZone {
+		patch.TimeZone = &timeZone
+	}
+	return patch
+}
+
+// retimeSeries moves series' rule along with a retime of before carried
+// over to the rest of the series for scope. Its start, weekdays and
+// exceptions move as seriesRetime moves games, and UNTIL by as long as
+// before's occurrence moved. For scopeFollowing the rule restarts at
+// before, COUNT counting only the games from there on, and the exceptions
+// before it stay put.
+func retimeSeries(series *Series, before *Game, retime seriesRetime, scope string) (Series, error) {
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return Series{}, err
+	}
+	rule, err := parseRRule(series.RRule, loc)
+	if err != nil {
+		return Series{}, err
+	}
+
+	updated := *series
+	from := series.StartsAt
+	if scope == scopeFollowing {
+		from = occurrenceAt(before)
+		if rule.Count > 0 {
+			starts, err := rule.occurrences(series.StartsAt.In(loc), time.Time{})
+			if err != nil {
+				return Series{}, err
+			}
+			remaining := 0
+			for _, start := range starts {
+				if !start.Before(from) {
+					remaining++
+				}
+			}
+			rule.Count = remaining
+		}
+	}
+	updated.StartsAt = retime.apply(from.In(loc))
+	updated.TimeZone = retime.moved.Location().String()
+
+	if rule.Until != nil {
+		until := rule.Until.Add(retime.apply(occurrenceAt(before).In(loc)).Sub(occurrenceAt(before)))
+		rule.Until = &until
+	}
+	for i, day := range rule.ByDay {
+		rule.ByDay[i] = time.Weekday(((int(day)+retime.days)%7 + 7) % 7)
+	}
+	sort.Slice(rule.ByDay, func(i, j int) bool { return rule.ByDay[i] < rule.ByDay[j] })
+	updated.RRule = rule.String()
+
+	firstMoved := from.In(loc).Format("2006-01-02")
+	exceptions := make([]string, len(series.Exceptions))
+	for i, date := range series.Exceptions {
+		exceptions[i] = date
+		if d, err := time.Parse("2006-01-02", date); err == nil && date >= firstMoved {
+			exceptions[i] = d.AddDate(0, 0, retime.days).Format("2006-01-02")
+		}
+	}
+	if updated.Exceptions, err = cleanExceptions(exceptions); err != nil {
+		return Series{}, err
+	}
+	return updated, nil
+}
+
+// patchedSeriesGames returns the other games in scope that patch to before
+// would move or give a new mode, as they would be after it, so they can be
+// checked for conflicts before anything is written.
+func patchedSeriesGames(before *Game, patch GamePatch, scope string) ([]Game, error) {
+	others, err := scopedSeriesGames(before, scope, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	changed := changedFields(before, patch)
+	var patched []Game
+	for i := range others {
+		otherPatch := seriesPatch(before, changed, &others[i])
+		if otherPatch.StartsAt == nil && otherPatch.GameMode == nil {
+			continue
+		}
+		g := others[i]
+		if otherPatch.StartsAt != nil {
+			g.StartsAt = *otherPatch.StartsAt
+		}
+		if otherPatch.TimeZone != nil {
+			g.TimeZone = *otherPatch.TimeZone
+		}
+		if otherPatch.GameMode != nil {
+			g.GameMode = *otherPatch.GameMode
+		}
+		patched = append(patched, g)
+	}
+	return patched, nil
+}
+
+// updateSeriesGame applies patch to before and, for scopeFollowing and
+// scopeAll, whatever it changes to the other games in scope, all as one
+// change. A new start retimes the series too, and the occurrences of the
+// games in scope with it. Only the game itself is checked against version.
+// It audits the series and the other games and returns before as updated,
+// or nil if it is gone.
+func updateSeriesGame(actor string, before *Game, patch GamePatch, scope string, version int) (*Game, error) {
+	series, err := store.GetSeries(before.SeriesID)
+	if err != nil || series == nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	others, err := scopedSeriesGames(before, scope, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []GameChange{{ID: before.ID, Patch: patch, Version: version}}
+	changed := changedFields(before, patch)
+	retime, retimed := newSeriesRetime(before, changed)
+	updatedSeries := *series
+	if retimed {
+		if updatedSeries, err = retimeSeries(series, before, retime, scope); err != nil {
+			return nil, err
+		}
+		occurrence := retime.apply(occurrenceAt(before).In(loc))
+		changes[0].Patch.OccurrenceAt = &occurrence
+	}
+	previous := make(map[string]*Game, len(others))
+	for i := range others {
+		otherPatch := seriesPatch(before, changed, &others[i])
+		if retimed {
+			occurrence := retime.apply(occurrenceAt(&others[i]).In(loc))
+			otherPatch.OccurrenceAt = &occurrence
+		}
+		if otherPatch == (GamePatch{}) {
+			continue
+		}
+		changes = append(changes, GameChange{ID: others[i].ID, Patch: otherPatch})
+		previous[others[i].ID] = &others[i]
+	}
+
+	games, err := store.UpdateSeriesGames(updatedSeries, changes)
+	if err != nil {
+		return nil, err
+	}
+	if retimed {
+		recordAudit(before.TeamID, actor, "update", auditSeries, series.ID, series, updatedSeries)
+	}
+	var updated *Game
+	for i := range games {
+		if games[i].ID == before.ID {
+			updated = &games[i]
+			continue
+		}
+		recordAudit(before.TeamID, actor, "update", auditGame, games[i].ID, previous[games[i].ID], games[i])
+	}
+	return updated, nil
+}
+
+// deleteSeriesGame moves before to the trash and keeps its series from
+// bringing it back: scopeThis adds its date to the exceptions and
+// scopeFollowing ends the rule just before it, trashing the later games
+// too. scopeAll trashes every game that hasn't started. It audits the
+// series and every game, and returns the trashed games' IDs.
+func deleteSeriesGame(actor string, before *Game, scope string, version int) ([]string, error) {
+	series, err := store.GetSeries(before.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+	if series == nil {
+		return nil, fmt.Errorf("series %s not found", before.SeriesID)
+	}
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *series
+	var others []Game
+	switch scope {
+	case scopeThis:
+		updated.Exceptions, err = cleanExceptions(append(append([]string{}, series.Exceptions...), occurrenceAt(before).In(loc).Format("2006-01-02")))
+	case scopeFollowing:
+		var rule recurrenceRule
+		if rule, err = parseRRule(series.RRule, loc); err != nil {
+			return nil, err
+		}
+		until := occurrenceAt(before).Add(-time.Second)
+		rule.Count, rule.Until = 0, &until
+		updated.RRule = rule.String()
+		others, err = scopedSeriesGames(before, scope, time.Now())
+	case scopeAll:
+		others, err = scopedSeriesGames(before, scope, time.Now())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []GameChange{{ID: before.ID, Delete: true, Version: version}}
+	trashed := map[string]*Game{before.ID: before}
+	for i := range others {
+		changes = append(changes, GameChange{ID: others[i].ID, Delete: true})
+		trashed[others[i].ID] = &others[i]
+	}
+	if _, err := store.UpdateSeriesGames(updated, changes); err != nil {
+		return nil, err
+	}
+
+	if updated.RRule != series.RRule || len(updated.Exceptions) != len(series.Exceptions) {
+		recordAudit(before.TeamID, actor, "update", auditSeries, series.ID, series, updated)
+	}
+	ids := make([]string, 0, len(changes))
+	for _, change := range changes {
+		recordAudit(before.TeamID, actor, "delete", auditGame, change.ID, trashed[change.ID], nil)
+		ids = append(ids, change.ID)
+	}
+	return ids, nil
+}
+
+// handleCreateSeries creates a series and all its games from a game body
+// plus an RRULE and optional exception dates. The body's start is the first
+// occurrence. Any game that would conflict fails it, unless ?force=true.
+func handleCreateSeries(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	var body struct {
+		gameInput
+		RRule      string   `json:"rrule"`
+		Exceptions []string `json:"exceptions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if (body.StartsAt == "" && (body.Date == "" || body.Time == "")) || (body.Opponent == "" && body.OpponentID == "") || body.RRule == "" {
+		writeError(w, http.StatusBadRequest, "Missing required fields")
+		return
+	}
+
+	startsAt, timeZone, err := resolveGameStart(body.StartsAt, body.Date, body.Time, body.TimeZone)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	loc, _ := time.LoadLocation(timeZone)
+
+	rule, err := parseRRule(body.RRule, loc)
+	if err == nil {
+		body.Exceptions, err = cleanExceptions(body.Exceptions)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	template, err := draftGame(team.ID, body.gameInput, startsAt, timeZone)
+	if errors.Is(err, errInvalidLeague) || errors.Is(err, errInvalidOpponent) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var end time.Time
+	if rule.Count == 0 && rule.Until == nil {
+		end = startsAt.Add(seriesHorizon)
+		season, err := store.GetSeason(template.SeasonID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if season != nil && season.EndsAt != nil {
+			end = *season.EndsAt
+		}
+	}
+	starts, err := rule.occurrences(startsAt.In(loc), end)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	series := Series{
+		ID:         generateID("series"),
+		TeamID:     team.ID,
+		SeasonID:   template.SeasonID,
+		RRule:      rule.String(),
+		StartsAt:   startsAt,
+		TimeZone:   timeZone,
+		Exceptions: body.Exceptions,
+		CreatedBy:  session.DiscordID,
+	}
+	var games []Game
+	for _, start := range starts {
+		if indexOf(series.Exceptions, start.Format("2006-01-02")) >= 0 {
+			continue
+		}
+		occurrence := start.UTC()
+		game := template
+		game.StartsAt = occurrence
+		game.SeriesID = series.ID
+		game.OccurrenceAt = &occurrence
+		games = append(games, game)
+	}
+	if len(games) == 0 {
+		writeError(w, http.StatusBadRequest, "The rule gives no games")
+		return
+	}
+
+	conflicts, err := findGamesConflicts(games)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(conflicts) > 0 && !forceRequested(r) {
+		writeSchedulingConflict(w, conflicts)
+		return
+	}
+
+	opponent, err := saveGameOpponent(session.DiscordID, team.ID, &Opponent{ID: template.OpponentID, Name: template.Opponent})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i := range games {
+		games[i].ID = generateGameID()
+		games[i].Opponent, games[i].OpponentID = opponent.Name, opponent.ID
+	}
+
+	created, createdGames, err := store.CreateSeries(series, games)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	setConflictGames(conflicts, createdGames)
+
+	recordAudit(team.ID, session.DiscordID, "create", auditSeries, created.ID, nil, created)
+	for i := range createdGames {
+		recordAudit(team.ID, session.DiscordID, "create", auditGame, createdGames[i].ID, nil, createdGames[i])
+	}
+
+	localizeForViewer(r, createdGames)
+	response := map[string]interface{}{
+		"series": created,
+		"games":  createdGames,
+	}
+	if len(conflicts) > 0 {
+		response["conflicts"] = conflicts
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func handleGetSeriesList(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	series, err := store.ListSeries(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, series)
+}
+
+// handleGetSeries returns a series with its live games, oldest first.
+func handleGetSeries(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	series, err := getTeamSeries(team.ID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if series == nil {
+		writeError(w, http.StatusNotFound, "Series not found")
+		return
+	}
+
+	games, _, err := store.FindGames(GameFilter{TeamID: team.ID, SeriesID: series.ID})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	localizeForViewer(r, games)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"series": series,
+		"games":  games,
+	})
+}
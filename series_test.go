@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseRRule(t *testing.T) {
+	loc := newYork(t)
+	tests := []struct {
+		value string
+		want  string // the rule written back out, or "" if it is invalid
+	}{
+		{"RRULE:FREQ=WEEKLY;BYDAY=TU,TH;COUNT=6", "FREQ=WEEKLY;COUNT=6;BYDAY=TU,TH"},
+		{"freq=daily; interval=2", "FREQ=DAILY;INTERVAL=2"},
+		{"FREQ=MONTHLY;INTERVAL=1;WKST=MO", "FREQ=MONTHLY"},
+		// UNTIL without a Z is wall-clock in the series' zone
+		{"FREQ=WEEKLY;UNTIL=20250630T200000", "FREQ=WEEKLY;UNTIL=20250701T000000Z"},
+		{"FREQ=WEEKLY;UNTIL=20250630T200000Z", "FREQ=WEEKLY;UNTIL=20250630T200000Z"},
+		// and a date alone takes in the whole day
+		{"FREQ=DAILY;UNTIL=20250101", "FREQ=DAILY;UNTIL=20250102T045959Z"},
+		{"FREQ=DAILY;INTERVAL=365;COUNT=2", "FREQ=DAILY;INTERVAL=365;COUNT=2"},
+
+		{"", ""},
+		{"COUNT=3", ""},
+		{"FREQ=YEARLY", ""},
+		{"FREQ", ""},
+		{"FREQ=DAILY;FREQ=WEEKLY", ""},
+		{"FREQ=DAILY;INTERVAL=0", ""},
+		{"FREQ=DAILY;INTERVAL=200000;COUNT=2", ""},
+		{"FREQ=DAILY;COUNT=-1", ""},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20250101", ""},
+		{"FREQ=DAILY;BYDAY=MO", ""},
+		{"FREQ=WEEKLY;BYDAY=MO,XX", ""},
+		{"FREQ=WEEKLY;WKST=SU", ""},
+		{"FREQ=WEEKLY;BYMONTH=3", ""},
+		{"FREQ=WEEKLY;UNTIL=tomorrow", ""},
+	}
+	for _, tt := range tests {
+		rule, err := parseRRule(tt.value, loc)
+		if tt.want == "" {
+			if !errors.Is(err, errInvalidSeries) {
+				t.Errorf("parseRRule(%q) = %v, %v; want an invalid series", tt.value, rule, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRRule(%q): %v", tt.value, err)
+		} else if got := rule.String(); got != tt.want {
+			t.Errorf("parseRRule(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	loc := newYork(t)
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		end   string
+		want  []string // local starts, or nil if the rule is invalid
+	}{
+		{
+			// Daylight saving starts on March 9, and the games stay at 20:00
+			name:  "weekly across DST",
+			rule:  "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4",
+			start: "2025-03-04 20:00",
+			want:  []string{"2025-03-04 20:00 EST", "2025-03-06 20:00 EST", "2025-03-11 20:00 EDT", "2025-03-13 20:00 EDT"},
+		},
+		{
+			// Weeks run Monday to Sunday, so the Monday before start is
+			// skipped but that week's Sunday is in
+			name:  "every other week",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU;COUNT=3",
+			start: "2025-03-05 19:00",
+			want:  []string{"2025-03-09 19:00 EDT", "2025-03-17 19:00 EDT", "2025-03-23 19:00 EDT"},
+		},
+		{
+			name:  "daily until a date",
+			rule:  "FREQ=DAILY;INTERVAL=3;UNTIL=20251105",
+			start: "2025-10-30 21:00",
+			want:  []string{"2025-10-30 21:00 EDT", "2025-11-02 21:00 EST", "2025-11-05 21:00 EST"},
+		},
+		{
+			name:  "monthly skips short months",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: "2025-01-31 20:00",
+			want:  []string{"2025-01-31 20:00 EST", "2025-03-31 20:00 EDT", "2025-05-31 20:00 EDT"},
+		},
+		{
+			name:  "up to and including end",
+			rule:  "FREQ=WEEKLY",
+			start: "2025-06-03 20:00",
+			end:   "2025-06-17 20:00",
+			want:  []string{"2025-06-03 20:00 EDT", "2025-06-10 20:00 EDT", "2025-06-17 20:00 EDT"},
+		},
+		{
+			name:  "more games than allowed",
+			rule:  "FREQ=DAILY",
+			start: "2025-06-03 20:00",
+		},
+		{
+			name:  "past the last year allowed",
+			rule:  "FREQ=DAILY;INTERVAL=365;COUNT=7",
+			start: "2025-06-03 20:00",
+		},
+		{
+			name:  "far off until",
+			rule:  "FREQ=MONTHLY;INTERVAL=12;UNTIL=21000101",
+			start: "2025-06-03 20:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseRRule(tt.rule, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, err := time.ParseInLocation("2006-01-02 15:04", tt.start, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var end time.Time
+			if tt.end != "" {
+				if end, err = time.ParseInLocation("2006-01-02 15:04", tt.end, loc); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			starts, err := rule.occurrences(start, end)
+			if tt.want == nil {
+				if !errors.Is(err, errInvalidSeries) {
+					t.Fatalf("got %d starts, %v; want an invalid series", len(starts), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range starts {
+				got = append(got, s.Format("2006-01-02 15:04 MST"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A Duration only reaches 292 years, and daysBetween has to go further
+func TestDaysBetween(t *testing.T) {
+	from := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	for _, to := range []time.Time{from.AddDate(0, 0, 1), from.AddDate(300, 0, 0), from.AddDate(1000, 0, 0)} {
+		if back := from.AddDate(0, 0, daysBetween(from, to)); !back.Equal(to) {
+			t.Errorf("daysBetween(%s, %s) lands on %s", from, to, back)
+		}
+	}
+}
+
+func TestRetimeSeries(t *testing.T) {
+	loc := newYork(t)
+	at := func(value, zone string) time.Time {
+		l, err := time.LoadLocation(zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tm, err := time.ParseInLocation("2006-01-02 15:04", value, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm.UTC()
+	}
+	// Tuesdays and Thursdays at 20:00 from March 4, 2025
+	series := Series{
+		ID:       "series_1",
+		RRule:    "FREQ=WEEKLY;COUNT=6;BYDAY=TU,TH",
+		StartsAt: at("2025-03-04 20:00", "America/New_York"),
+		TimeZone: "America/New_York",
+	}
+
+	tests := []struct {
+		name       string
+		rule       string
+		exceptions []string
+		before     string // the game moved, at its occurrence
+		startsAt   string // where it moves to
+		timeZone   string
+		scope      string
+
+		wantRule       string
+		wantStart      string
+		wantZone       string
+		wantExceptions []string
+	}{
+		{
+			name:           "all, a day later",
+			exceptions:     []string{"2025-03-11"},
+			before:         "2025-03-06 20:00",
+			startsAt:       "2025-03-07 21:30",
+			scope:          scopeAll,
+			wantRule:       "FREQ=WEEKLY;COUNT=6;BYDAY=WE,FR",
+			wantStart:      "2025-03-05 21:30",
+			wantExceptions: []string{"2025-03-12"},
+		},
+		{
+			// Thursday moves into next week's Monday
+			name:           "all, across the week's end",
+			before:         "2025-03-06 20:00",
+			startsAt:       "2025-03-10 20:00",
+			scope:          scopeAll,
+			wantRule:       "FREQ=WEEKLY;COUNT=6;BYDAY=MO,SA",
+			wantStart:      "2025-03-08 20:00",
+			wantExceptions: []string{},
+		},
+		{
+			name:           "all, a day earlier",
+			before:         "2025-03-04 20:00",
+			startsAt:       "2025-03-03 20:00",
+			scope:          scopeAll,
+			wantRule:       "FREQ=WEEKLY;COUNT=6;BYDAY=MO,WE",
+			wantStart:      "2025-03-03 20:00",
+			wantExceptions: []string{},
+		},
+		{
+			// Only the games from March 11 on are left to count, and the
+			// exception before it stays put
+			name:           "following recounts",
+			exceptions:     []string{"2025-03-06", "2025-03-18"},
+			before:         "2025-03-11 20:00",
+			startsAt:       "2025-03-12 19:00",
+			scope:          scopeFollowing,
+			wantRule:       "FREQ=WEEKLY;COUNT=4;BYDAY=WE,FR",
+			wantStart:      "2025-03-12 19:00",
+			wantExceptions: []string{"2025-03-06", "2025-03-19"},
+		},
+		{
+			// UNTIL moves as long as the game did
+			name:           "until",
+			rule:           "FREQ=WEEKLY;UNTIL=20250401T000000Z;BYDAY=TU",
+			before:         "2025-03-11 20:00",
+			startsAt:       "2025-03-12 21:00",
+			scope:          scopeAll,
+			wantRule:       "FREQ=WEEKLY;UNTIL=20250402T010000Z;BYDAY=WE",
+			wantStart:      "2025-03-05 21:00",
+			wantExceptions: []string{},
+		},
+		{
+			name:           "new zone",
+			before:         "2025-03-04 20:00",
+			startsAt:       "2025-03-04 19:00",
+			timeZone:       "Europe/London",
+			scope:          scopeAll,
+			wantRule:       "FREQ=WEEKLY;COUNT=6;BYDAY=TU,TH",
+			wantStart:      "2025-03-04 19:00",
+			wantZone:       "Europe/London",
+			wantExceptions: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := series
+			if tt.rule != "" {
+				s.RRule = tt.rule
+			}
+			s.Exceptions = tt.exceptions
+			occurrence := at(tt.before, s.TimeZone)
+			before := &Game{StartsAt: occurrence, TimeZone: s.TimeZone, OccurrenceAt: &occurrence, SeriesID: s.ID}
+
+			zone := s.TimeZone
+			if tt.timeZone != "" {
+				zone = tt.timeZone
+			}
+			startsAt := at(tt.startsAt, zone)
+			retime, ok := newSeriesRetime(before, GamePatch{StartsAt: &startsAt, TimeZone: &zone})
+			if !ok {
+				t.Fatal("not retimed")
+			}
+
+			got, err := retimeSeries(&s, before, retime, tt.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantZone := s.TimeZone
+			if tt.wantZone != "" {
+				wantZone = tt.wantZone
+			}
+			if got.RRule != tt.wantRule {
+				t.Errorf("rule %s, want %s", got.RRule, tt.wantRule)
+			}
+			if want := at(tt.wantStart, wantZone); !got.StartsAt.Equal(want) || got.TimeZone != wantZone {
+				t.Errorf("starts %s %s, want %s %s", got.StartsAt, got.TimeZone, want, wantZone)
+			}
+			if !reflect.DeepEqual(got.Exceptions, tt.wantExceptions) {
+				t.Errorf("exceptions %v, want %v", got.Exceptions, tt.wantExceptions)
+			}
+
+			// The retimed rule gives the moved games
+			rule, err := parseRRule(got.RRule, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotLoc, _ := time.LoadLocation(got.TimeZone)
+			starts, err := rule.occurrences(got.StartsAt.In(gotLoc), time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !starts[0].Equal(got.StartsAt) {
+				t.Errorf("first game %s, want %s", starts[0], got.StartsAt)
+			}
+		})
+	}
+
+	before := &Game{StartsAt: series.StartsAt, TimeZone: series.TimeZone}
+	if _, ok := newSeriesRetime(before, GamePatch{}); ok {
+		t.Error("a change without a new start retimed the series")
+	}
+}
@@ -0,0 +1,680 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== PLAYER GAME STATS ====================
+
+// StatColumn is one stat captains record per player per game. Flag
+// columns, like MVP, are yes or no and add up to the games they were set in.
+type StatColumn struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Flag  bool   `json:"flag,omitempty"`
+}
+
+// defaultStatColumns are used by game modes without columns of their own.
+var defaultStatColumns = []StatColumn{
+	{Key: "kills", Label: "Kills"},
+	{Key: "deaths", Label: "Deaths"},
+	{Key: "objectives", Label: "Objectives"},
+	{Key: "mvp", Label: "MVP", Flag: true},
+}
+
+const maxStatColumns = 20
+
+var statKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// StatLine is one member's stats in one game, by column key.
+type StatLine struct {
+	GameID     string             `json:"gameId"`
+	MemberID   string             `json:"memberId"`
+	Stats      map[string]float64 `json:"stats"`
+	RecordedBy string             `json:"recordedBy,omitempty"`
+	RecordedAt time.Time          `json:"recordedAt"`
+}
+
+// StatColumnConfig is a team's stat columns: the defaults and the game
+// modes that replace them.
+type StatColumnConfig struct {
+	Default []StatColumn            `json:"default"`
+	Modes   map[string][]StatColumn `json:"modes"`
+}
+
+// statColumnsSetting is the team setting holding the per-mode columns as a
+// JSON object.
+const statColumnsSetting = "stat_columns"
+
+// loadStatColumns returns the team's per-mode stat columns. A malformed
+// setting is logged and treated as unset so stats keep working.
+func loadStatColumns(teamID string) (map[string][]StatColumn, error) {
+	modes := make(map[string][]StatColumn)
+	key := teamSettingKey(teamID, statColumnsSetting)
+	value, err := store.GetSetting(key)
+	if err != nil || value == "" {
+		return modes, err
+	}
+	if err := json.Unmarshal([]byte(value), &modes); err != nil {
+		log.Printf("Setting %s is malformed, using the default stat columns: %v", key, err)
+		return make(map[string][]StatColumn), nil
+	}
+	return modes, nil
+}
+
+// statColumnsFor returns the columns recorded for games of a mode.
+func statColumnsFor(teamID, gameMode string) ([]StatColumn, error) {
+	modes, err := loadStatColumns(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if columns, ok := modes[gameMode]; ok {
+		return columns, nil
+	}
+	return defaultStatColumns, nil
+}
+
+// cleanStatColumns trims and checks a manager's column list, returning a
+// message for the first problem.
+func cleanStatColumns(columns []StatColumn) ([]StatColumn, string) {
+	if len(columns) > maxStatColumns {
+		return nil, fmt.Sprintf("At most %d stat columns are allowed", maxStatColumns)
+	}
+	seen := make(map[string]bool)
+	cleaned := make([]StatColumn, 0, len(columns))
+	for _, c := range columns {
+		c.Key = strings.ToLower(strings.TrimSpace(c.Key))
+		c.Label = strings.TrimSpace(c.Label)
+		if !statKeyPattern.MatchString(c.Key) {
+			return nil, fmt.Sprintf("Stat key %q must start with a letter and use only a-z, 0-9 and _", c.Key)
+		}
+		if seen[c.Key] {
+			return nil, fmt.Sprintf("Stat key %q is used twice", c.Key)
+		}
+		seen[c.Key] = true
+		if c.Label == "" {
+			c.Label = c.Key
+		}
+		if len(c.Label) > 40 {
+			return nil, fmt.Sprintf("Stat label for %q is too long", c.Key)
+		}
+		cleaned = append(cleaned, c)
+	}
+	return cleaned, ""
+}
+
+// statInput is one player's submitted stats. Player is a member ID or any
+// name they have gone by.
+type statInput struct {
+	Player string             `json:"player"`
+	Stats  map[string]float64 `json:"stats"`
+}
+
+// statFlagValues are the ways a CSV can say yes or no to a flag column
+var statFlagValues = map[string]float64{
+	"1": 1, "yes": 1, "y": 1, "true": 1, "x": 1,
+	"0": 0, "no": 0, "n": 0, "false": 0,
+}
+
+// maxStatSheetBytes caps the size of an uploaded stat sheet
+const maxStatSheetBytes = 1 << 20
+
+var errInvalidStatSheet = errors.New("invalid stat sheet")
+
+// parseStatCSV reads a stat sheet: a header row with a player column
+// ("player", "member" or "name") and stat columns named by key or label,
+// then one row per player. Empty cells are left out.
+func parseStatCSV(body io.Reader, columns []StatColumn) ([]statInput, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", errInvalidStatSheet, fmt.Sprintf(format, args...))
+	}
+
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, invalid("the sheet is empty")
+	}
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+
+	byName := make(map[string]*StatColumn)
+	for i := range columns {
+		byName[strings.ToLower(columns[i].Key)] = &columns[i]
+		byName[strings.ToLower(columns[i].Label)] = &columns[i]
+	}
+	playerField := -1
+	fields := make([]*StatColumn, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch {
+		case name == "player" || name == "member" || name == "name":
+			playerField = i
+		case byName[name] != nil:
+			fields[i] = byName[name]
+		case name == "":
+		default:
+			return nil, invalid("unknown stat column %q", header[i])
+		}
+	}
+	if playerField < 0 {
+		return nil, invalid("no player column")
+	}
+
+	var inputs []statInput
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalid("%v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if playerField >= len(record) || strings.TrimSpace(record[playerField]) == "" {
+			if strings.TrimSpace(strings.Join(record, "")) == "" {
+				continue
+			}
+			return nil, invalid("line %d has no player", line)
+		}
+
+		in := statInput{Player: strings.TrimSpace(record[playerField]), Stats: make(map[string]float64)}
+		for i, cell := range record {
+			var column *StatColumn
+			if i < len(fields) {
+				column = fields[i]
+			}
+			cell = strings.TrimSpace(cell)
+			if column == nil || cell == "" {
+				continue
+			}
+			if column.Flag {
+				value, ok := statFlagValues[strings.ToLower(cell)]
+				if !ok {
+					return nil, invalid("line %d: %s must be yes or no, not %q", line, column.Label, cell)
+				}
+				in.Stats[column.Key] = value
+				continue
+			}
+			value, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return nil, invalid("line %d: %s must be a number, not %q", line, column.Label, cell)
+			}
+			in.Stats[column.Key] = value
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+// buildStatLines checks submitted stats against the game and its columns.
+// Every player must have been on the game's roster or subs, once.
+func buildStatLines(game *Game, columns []StatColumn, inputs []statInput) ([]StatLine, error) {
+	invalid := func(format string, args ...interface{}) ([]StatLine, error) {
+		return nil, fmt.Errorf("%w: %s", errInvalidStatSheet, fmt.Sprintf(format, args...))
+	}
+
+	played := make(map[string]bool)
+	for _, id := range append(append([]string{}, game.Roster...), game.Subs...) {
+		played[id] = true
+	}
+	byKey := make(map[string]StatColumn, len(columns))
+	for _, c := range columns {
+		byKey[c.Key] = c
+	}
+
+	seen := make(map[string]bool)
+	lines := make([]StatLine, 0, len(inputs))
+	for _, in := range inputs {
+		member, err := getTeamMember(game.TeamID, strings.TrimSpace(in.Player))
+		if err != nil {
+			return nil, err
+		}
+		if member == nil {
+			return invalid("unknown player %q", in.Player)
+		}
+		if !played[member.ID] {
+			return invalid("%s wasn't on the roster or subs", member.Name)
+		}
+		if seen[member.ID] {
+			return invalid("%s is listed twice", member.Name)
+		}
+		seen[member.ID] = true
+
+		stats := make(map[string]float64, len(in.Stats))
+		for key, value := range in.Stats {
+			column, ok := byKey[key]
+			switch {
+			case !ok:
+				return invalid("unknown stat %q for %s games", key, game.GameMode)
+			case math.IsNaN(value) || math.IsInf(value, 0) || value < 0:
+				return invalid("%s for %s must be zero or more", column.Label, member.Name)
+			case column.Flag && value != 0 && value != 1:
+				return invalid("%s for %s must be 0 or 1", column.Label, member.Name)
+			}
+			stats[key] = value
+		}
+		lines = append(lines, StatLine{GameID: game.ID, MemberID: member.ID, Stats: stats})
+	}
+	return lines, nil
+}
+
+// GameStats is a game's stat sheet with the columns it is recorded in.
+type GameStats struct {
+	Columns []StatColumn `json:"columns"`
+	Lines   []StatLine   `json:"lines"`
+}
+
+// handleGetStatColumns lists the team's stat columns.
+func handleGetStatColumns(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	modes, err := loadStatColumns(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, StatColumnConfig{Default: defaultStatColumns, Modes: modes})
+}
+
+// handleSetStatColumns sets the columns recorded for one game mode; an
+// empty list goes back to the defaults. Stat lines already recorded keep
+// their values, even for columns that are dropped.
+func handleSetStatColumns(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	mode := strings.TrimSpace(mux.Vars(r)["mode"])
+	if mode == "" {
+		writeError(w, http.StatusBadRequest, "Game mode is required")
+		return
+	}
+
+	var body struct {
+		Columns []StatColumn `json:"columns"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	columns, msg := cleanStatColumns(body.Columns)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	modes, err := loadStatColumns(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	before := modes[mode]
+	if len(columns) == 0 {
+		delete(modes, mode)
+	} else {
+		modes[mode] = columns
+	}
+
+	key := teamSettingKey(team.ID, statColumnsSetting)
+	value, err := json.Marshal(modes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := store.SetSetting(key, string(value)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditSetting, key+":"+mode, before, columns)
+
+	writeJSON(w, http.StatusOK, StatColumnConfig{Default: defaultStatColumns, Modes: modes})
+}
+
+// gameStats loads a game's stat sheet.
+func gameStats(game *Game) (*GameStats, error) {
+	columns, err := statColumnsFor(game.TeamID, game.GameMode)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := store.ListStatLines([]string{game.ID})
+	if err != nil {
+		return nil, err
+	}
+	return &GameStats{Columns: columns, Lines: lines}, nil
+}
+
+func handleGetGameStats(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	game, err := getTeamGame(team.ID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	stats, err := gameStats(game)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleSetGameStats replaces a game's stat sheet with a JSON list of
+// {player, stats} lines.
+func handleSetGameStats(w http.ResponseWriter, r *http.Request) {
+	saveGameStats(w, r, func(columns []StatColumn) ([]statInput, error) {
+		var body struct {
+			Lines []statInput `json:"lines"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON", errInvalidStatSheet)
+		}
+		return body.Lines, nil
+	})
+}
+
+// handleImportGameStats replaces a game's stat sheet with an uploaded CSV,
+// as exported from the game's scoreboard; see parseStatCSV.
+func handleImportGameStats(w http.ResponseWriter, r *http.Request) {
+	saveGameStats(w, r, func(columns []StatColumn) ([]statInput, error) {
+		return parseStatCSV(http.MaxBytesReader(w, r.Body, maxStatSheetBytes), columns)
+	})
+}
+
+// saveGameStats is the shared part of setting and importing a stat sheet:
+// read reads the submitted lines once the game's columns are known.
+func saveGameStats(w http.ResponseWriter, r *http.Request, read func(columns []StatColumn) ([]statInput, error)) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	gameID := mux.Vars(r)["id"]
+	game, err := getTeamGame(team.ID, gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	if game.StartsAt.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, "Stats can only be recorded once the game has started")
+		return
+	}
+
+	before, err := gameStats(game)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	inputs, err := read(before.Columns)
+	if err == nil {
+		var lines []StatLine
+		lines, err = buildStatLines(game, before.Columns, inputs)
+		if err == nil {
+			now := time.Now().UTC()
+			for i := range lines {
+				lines[i].RecordedBy = session.DiscordID
+				lines[i].RecordedAt = now
+			}
+			err = store.SetStatLines(game.ID, lines)
+		}
+	}
+	if errors.Is(err, errInvalidStatSheet) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	after, err := gameStats(game)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "set_stats", auditGame, gameID, before.Lines, after.Lines)
+
+	writeJSON(w, http.StatusOK, after)
+}
+
+// LeaderboardEntry is one member's stats added up over a set of games.
+type LeaderboardEntry struct {
+	MemberID string             `json:"memberId"`
+	Name     string             `json:"name"`
+	Games    int                `json:"games"`
+	Totals   map[string]float64 `json:"totals"`
+	Averages map[string]float64 `json:"averages"`
+}
+
+// Leaderboard ranks members by one stat.
+type Leaderboard struct {
+	Stat    string             `json:"stat"`
+	Columns []StatColumn       `json:"columns"`
+	Players []LeaderboardEntry `json:"players"`
+}
+
+// leaderboardColumns are the columns of one game mode or, with none, every
+// column any mode records, the defaults first.
+func leaderboardColumns(teamID, gameMode string) ([]StatColumn, error) {
+	if gameMode != "" {
+		return statColumnsFor(teamID, gameMode)
+	}
+	modes, err := loadStatColumns(teamID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := append([]StatColumn{}, defaultStatColumns...)
+	seen := make(map[string]bool)
+	for _, c := range columns {
+		seen[c.Key] = true
+	}
+	for _, name := range names {
+		for _, c := range modes[name] {
+			if !seen[c.Key] {
+				seen[c.Key] = true
+				columns = append(columns, c)
+			}
+		}
+	}
+	return columns, nil
+}
+
+// computeLeaderboard adds up stat lines per member and ranks them by stat,
+// highest total first, then fewest games and name. names maps member IDs
+// to their names.
+func computeLeaderboard(stat string, columns []StatColumn, lines []StatLine, names map[string]string) Leaderboard {
+	entries := make(map[string]*LeaderboardEntry)
+	var order []string
+	for _, line := range lines {
+		e, ok := entries[line.MemberID]
+		if !ok {
+			e = &LeaderboardEntry{MemberID: line.MemberID, Name: names[line.MemberID], Totals: make(map[string]float64), Averages: make(map[string]float64)}
+			entries[line.MemberID] = e
+			order = append(order, line.MemberID)
+		}
+		e.Games++
+		for key, value := range line.Stats {
+			e.Totals[key] += value
+		}
+	}
+
+	board := Leaderboard{Stat: stat, Columns: columns, Players: make([]LeaderboardEntry, 0, len(order))}
+	for _, id := range order {
+		e := entries[id]
+		for _, c := range columns {
+			// Every column gets a total, zero if nobody recorded it
+			total := e.Totals[c.Key]
+			e.Totals[c.Key] = total
+			e.Averages[c.Key] = roundTo(total/float64(e.Games), 2)
+		}
+		board.Players = append(board.Players, *e)
+	}
+	sort.SliceStable(board.Players, func(i, j int) bool {
+		a, b := &board.Players[i], &board.Players[j]
+		if a.Totals[stat] != b.Totals[stat] {
+			return a.Totals[stat] > b.Totals[stat]
+		}
+		if a.Games != b.Games {
+			return a.Games < b.Games
+		}
+		return a.Name < b.Name
+	})
+	return board
+}
+
+// handleGetLeaderboard ranks members by a stat (?stat=, the first column
+// by default) over the season's games, taking the game listing's filters.
+// ?top= keeps only the leaders; ?player= picks out one member's entry.
+func handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+
+	filter, err := parseGameFilter(r, team.ID, seasonID, 0)
+	if err != nil {
+		writeGameFilterError(w, err)
+		return
+	}
+	memberID := filter.MemberID
+	filter.MemberID, filter.Participation = "", ""
+	filter.After, filter.Limit = nil, 0
+
+	top := 0
+	if v := r.URL.Query().Get("top"); v != "" {
+		top, err = strconv.Atoi(v)
+		if err != nil || top <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("bad top %q", v))
+			return
+		}
+	}
+
+	columns, err := leaderboardColumns(team.ID, filter.GameMode)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	stat := r.URL.Query().Get("stat")
+	if stat == "" && len(columns) > 0 {
+		stat = columns[0].Key
+	}
+	known := false
+	for _, c := range columns {
+		known = known || c.Key == stat
+	}
+	if !known {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown stat %q", stat))
+		return
+	}
+
+	games, _, err := store.FindGames(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ids := make([]string, len(games))
+	for i := range games {
+		ids[i] = games[i].ID
+	}
+	lines, err := store.ListStatLines(ids)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	names := make(map[string]string)
+	for _, line := range lines {
+		if _, ok := names[line.MemberID]; ok {
+			continue
+		}
+		member, err := store.GetMember(line.MemberID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		names[line.MemberID] = line.MemberID
+		if member != nil {
+			names[line.MemberID] = member.Name
+		}
+	}
+
+	board := computeLeaderboard(stat, columns, lines, names)
+	if memberID != "" {
+		var only []LeaderboardEntry
+		for _, e := range board.Players {
+			if e.MemberID == memberID {
+				only = append(only, e)
+			}
+		}
+		board.Players = append([]LeaderboardEntry{}, only...)
+	}
+	if top > 0 && len(board.Players) > top {
+		board.Players = board.Players[:top]
+	}
+	writeJSON(w, http.StatusOK, board)
+}
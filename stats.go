@@ -0,0 +1,267 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ==================== PLAYER STATS ====================
+
+// Attendance is one member's part in one game, the raw material for player
+// stats. Members who never answered have one with statusNone and roleNone.
+type Attendance struct {
+	GameID   string
+	MemberID string
+	// PostedAt is when the game was created, JoinedAt when the member was
+	PostedAt time.Time
+	JoinedAt time.Time
+	Status   string
+	Role     string
+	// RespondedAt is the member's first answer; WithdrawnAt is when they
+	// last withdrew, kept if they were put back on the roster since
+	RespondedAt *time.Time
+	WithdrawnAt *time.Time
+}
+
+// lateWithdrawalWindow is how close to the start a withdrawal counts as
+// late, leaving too little time to find a sub.
+const lateWithdrawalWindow = 24 * time.Hour
+
+// PlayerStats sums up one member's attendance over a season's games.
+type PlayerStats struct {
+	MemberID string `json:"memberId"`
+	Name     string `json:"name"`
+	SeasonID string `json:"seasonId,omitempty"`
+	Season   string `json:"season,omitempty"`
+
+	// Rostered counts games the member was put on the roster for, including
+	// ones they withdrew from; Played the started ones they stayed on for.
+	Rostered int `json:"rostered"`
+	Played   int `json:"played"`
+
+	// Requests counts games the member was asked about: those posted while
+	// they were on the team, once the game has started or they answered.
+	Requests     int     `json:"requests"`
+	Responses    int     `json:"responses"`
+	ResponseRate float64 `json:"responseRate"`
+	// AvgResponseHours is the mean time from a game being posted, or the
+	// member joining if later, to their first answer
+	AvgResponseHours *float64 `json:"avgResponseHours"`
+
+	Withdrawals     int `json:"withdrawals"`
+	LateWithdrawals int `json:"lateWithdrawals"`
+
+	// Reliability runs from 0 to 100: the average of the response rate and
+	// the share of roster spots kept, where a late withdrawal costs double.
+	// It is null until the member has been asked or rostered.
+	Reliability *float64 `json:"reliability"`
+
+	responseTime time.Duration
+	timed        int
+}
+
+// add counts one game the member was on the team for.
+func (s *PlayerStats) add(g *Game, a *Attendance, now time.Time) {
+	started := !g.StartsAt.After(now)
+	responded := a.RespondedAt != nil || a.Status != statusNone
+	if !responded && a.JoinedAt.After(g.StartsAt) && a.JoinedAt.After(a.PostedAt) {
+		// Joined after the game; nobody asked them. Games posted later
+		// than that were entered after the fact, with the member already
+		// on the team.
+		return
+	}
+
+	if started || responded {
+		s.Requests++
+	}
+	if responded {
+		s.Responses++
+	}
+	if a.RespondedAt != nil {
+		asked := a.PostedAt
+		if a.JoinedAt.After(asked) {
+			asked = a.JoinedAt
+		}
+		if wait := a.RespondedAt.Sub(asked); wait >= 0 {
+			s.responseTime += wait
+			s.timed++
+		}
+	}
+
+	withdrew := a.Role == roleWithdrawn || a.WithdrawnAt != nil
+	if a.Role == roleRoster || withdrew {
+		s.Rostered++
+	}
+	if a.Role == roleRoster && started {
+		s.Played++
+	}
+	if withdrew {
+		s.Withdrawals++
+		if a.WithdrawnAt != nil && g.StartsAt.Sub(*a.WithdrawnAt) < lateWithdrawalWindow {
+			s.LateWithdrawals++
+		}
+	}
+}
+
+// finish works out the rates once every game has been added.
+func (s *PlayerStats) finish() {
+	var scores []float64
+	if s.Requests > 0 {
+		s.ResponseRate = roundTo(float64(s.Responses)/float64(s.Requests), 3)
+		scores = append(scores, float64(s.Responses)/float64(s.Requests))
+	}
+	if s.timed > 0 {
+		hours := roundTo((s.responseTime / time.Duration(s.timed)).Hours(), 1)
+		s.AvgResponseHours = &hours
+	}
+	if s.Rostered > 0 {
+		kept := 1 - float64(s.Withdrawals+s.LateWithdrawals)/float64(s.Rostered)
+		scores = append(scores, math.Max(kept, 0))
+	}
+	if len(scores) > 0 {
+		sum := 0.0
+		for _, score := range scores {
+			sum += score
+		}
+		reliability := roundTo(100*sum/float64(len(scores)), 1)
+		s.Reliability = &reliability
+	}
+}
+
+func roundTo(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
+
+// computePlayerStats adds up attendance per member and season. Members come
+// out in the given order, each with one entry per season that has games, in
+// season order; games without a season share one entry.
+func computePlayerStats(games []Game, attendance []Attendance, members []Member, seasons []Season, now time.Time) []PlayerStats {
+	gamesByID := make(map[string]*Game, len(games))
+	for i := range games {
+		gamesByID[games[i].ID] = &games[i]
+	}
+	seasonIndex := make(map[string]int, len(seasons))
+	seasonNames := make(map[string]string, len(seasons))
+	for i, season := range seasons {
+		seasonIndex[season.ID] = i
+		seasonNames[season.ID] = season.Name
+	}
+
+	// Every member gets an entry for every season with games in the list
+	var seasonIDs []string
+	seen := make(map[string]bool)
+	for i := range games {
+		if id := games[i].SeasonID; !seen[id] {
+			seen[id] = true
+			seasonIDs = append(seasonIDs, id)
+		}
+	}
+	sort.SliceStable(seasonIDs, func(i, j int) bool {
+		a, aok := seasonIndex[seasonIDs[i]]
+		b, bok := seasonIndex[seasonIDs[j]]
+		if aok != bok {
+			return aok
+		}
+		return a < b
+	})
+
+	entries := make(map[string]*PlayerStats)
+	stats := make([]PlayerStats, 0, len(members)*len(seasonIDs))
+	for _, m := range members {
+		for _, seasonID := range seasonIDs {
+			stats = append(stats, PlayerStats{MemberID: m.ID, Name: m.Name, SeasonID: seasonID, Season: seasonNames[seasonID]})
+		}
+	}
+	for i := range stats {
+		entries[stats[i].MemberID+"\x00"+stats[i].SeasonID] = &stats[i]
+	}
+
+	for i := range attendance {
+		a := &attendance[i]
+		g, ok := gamesByID[a.GameID]
+		if !ok {
+			continue
+		}
+		if entry, ok := entries[a.MemberID+"\x00"+g.SeasonID]; ok {
+			entry.add(g, a, now)
+		}
+	}
+	for i := range stats {
+		stats[i].finish()
+	}
+	return stats
+}
+
+// findPlayerStats is the stats service: it gathers the games filter matches
+// and everyone's attendance at them. With a member set in the filter only
+// that member is reported on, across the same games as everyone else.
+func findPlayerStats(filter GameFilter) ([]PlayerStats, error) {
+	memberID := filter.MemberID
+	filter.MemberID, filter.Participation = "", ""
+	filter.After, filter.Limit = nil, 0
+
+	games, _, err := store.FindGames(filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(games))
+	for i := range games {
+		ids[i] = games[i].ID
+	}
+	attendance, err := store.ListAttendance(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := store.ListMembers(filter.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	if memberID != "" {
+		var only []Member
+		for _, m := range members {
+			if m.ID == memberID {
+				only = append(only, m)
+			}
+		}
+		members = only
+	}
+	seasons, err := store.ListSeasons(filter.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	return computePlayerStats(games, attendance, members, seasons, filter.Now), nil
+}
+
+// handleGetPlayerStats reports attendance and reliability per member and
+// season. It takes the game listing's filters; ?player= picks one member.
+func handleGetPlayerStats(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	seasonID, err := requestedSeason(r, team.ID)
+	if err != nil {
+		writeSeasonError(w, err)
+		return
+	}
+
+	filter, err := parseGameFilter(r, team.ID, seasonID, 0)
+	if err != nil {
+		writeGameFilterError(w, err)
+		return
+	}
+
+	stats, err := findPlayerStats(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
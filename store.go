@@ -0,0 +1,494 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ==================== STORAGE ====================
+
+// Store is everything the handlers need from persistence. sqlStore runs on
+// Postgres in production or on a SQLite file for self-hosting; memoryStore
+// keeps everything in process so the app is fully usable without a database
+// for local work and tests.
+//
+// Lookups of a single record return (nil, nil) when it doesn't exist.
+type Store interface {
+	// Teams, oldest first. Slugs are unique (errDuplicateName).
+	ListTeams() ([]Team, error)
+	GetTeam(id string) (*Team, error)
+	CreateTeam(t Team) (*Team, error)
+	UpdateTeam(t Team) (*Team, error)
+
+	// Games. ListGames lists a team's games in one season; "" for either
+	// means every team or every season.
+	ListGames(teamID, seasonID string) ([]Game, error)
+	// FindGames returns the live games matching f, at most f.Limit of them,
+	// and how many match in all, ignoring f.After and f.Limit.
+	FindGames(f GameFilter) (games []Game, total int, err error)
+	GetGame(id string) (*Game, error)
+	CreateGame(g Game) (*Game, error)
+	// CreateGames creates several games at once, after the new opponents
+	// they play; if any fails, none are.
+	CreateGames(games []Game, opponents []Opponent) ([]Game, []Opponent, error)
+
+	// Every change to a game bumps its version. The methods below that take
+	// a version fail with errVersionConflict unless it matches the game's
+	// current version; 0 skips the check. New participants must be live
+	// members of the game's team, or the change fails with errUnknownMember.
+	//
+	// UpdateGame applies a patch to a game as a single change: either every
+	// field is written or none is. Participant lists are written on behalf
+	// of actor, the Discord ID of whoever made the change.
+	UpdateGame(id string, patch GamePatch, actor string, version int) (*Game, error)
+	// SetAvailability records one member's answer (statusAvailable or
+	// statusUnavailable) as a single-row change, so players answering at the
+	// same time never overwrite each other.
+	SetAvailability(gameID, memberID, status, actor string, version int) (*Game, error)
+	// WithdrawFromRoster moves a rostered member to the withdrawals and
+	// marks them unavailable, failing with errNotOnRoster if they aren't
+	// on the roster.
+	WithdrawFromRoster(gameID, memberID, actor string, version int) (*Game, error)
+	// SetGameResult records or replaces a game's result; nil clears it.
+	SetGameResult(gameID string, result *GameResult, version int) (*Game, error)
+	// DeleteGame moves a game to the trash. Deleted games are left out of
+	// every other game lookup but keep their participants until purged.
+	DeleteGame(id string, version int) error
+	// ListPendingReminders returns unreminded games with a roster that start
+	// in the window (from, to].
+	ListPendingReminders(from, to time.Time) ([]Game, error)
+	// ListAttendance returns a record for every live member of each game's
+	// team, whether they answered or not, ordered by game then member ID.
+	ListAttendance(gameIDs []string) ([]Attendance, error)
+	// Per-player stat lines, ordered by game then member ID. SetStatLines
+	// replaces a game's whole stat sheet.
+	ListStatLines(gameIDs []string) ([]StatLine, error)
+	SetStatLines(gameID string, lines []StatLine) error
+
+	// Recurring series. CreateSeries saves a series together with the games
+	// it generated, all or nothing.
+	CreateSeries(series Series, games []Game) (*Series, []Game, error)
+	GetSeries(id string) (*Series, error)
+	// ListSeries lists a team's series, oldest first.
+	ListSeries(teamID string) ([]Series, error)
+	// UpdateSeriesGames writes the series' rule, start, time zone and
+	// exceptions and applies changes to its games as a single change,
+	// returning the games left live. Patches may not carry participant lists.
+	UpdateSeriesGames(series Series, changes []GameChange) ([]Game, error)
+
+	// Calendar feeds, by token. ListCalendarFeeds lists a team's feeds,
+	// oldest first; deleting one revokes its URL.
+	ListCalendarFeeds(teamID string) ([]CalendarFeed, error)
+	GetCalendarFeed(token string) (*CalendarFeed, error)
+	CreateCalendarFeed(f CalendarFeed) (*CalendarFeed, error)
+	DeleteCalendarFeed(token string) error
+
+	// A team's members, ordered active first, then by sort order and name
+	ListMembers(teamID string) ([]Member, error)
+	// GetMember also finds members in the trash, so rosters that still
+	// reference them can show their names.
+	GetMember(id string) (*Member, error)
+	// FindMember returns the team's member, trashed ones included, whose
+	// current or former name matches name by memberKey.
+	FindMember(teamID, name string) (*Member, error)
+	// CreateMember adds a member at the end of their group's sort order in
+	// their team. It fails with errDuplicateMember if the ID is taken or
+	// another member of the team has gone by that name.
+	CreateMember(m Member) error
+	// UpdateMember keeps a renamed member's old name as an alias, and fails
+	// with errDuplicateMember if the new name belongs to another member.
+	UpdateMember(id string, update MemberUpdate) error
+	// DeleteMember moves a member to the trash and takes them off every
+	// live game that hasn't started yet, returning those games' IDs. Past
+	// games keep them so they stay readable.
+	DeleteMember(id string) (gameIDs []string, err error)
+	SetMemberOrder(ids []string) error
+
+	// Trash, per team. Restore returns nil when the record isn't in the trash.
+	ListDeletedGames(teamID string) ([]Game, error)
+	RestoreGame(id string) (*Game, error)
+	ListDeletedMembers(teamID string) ([]Member, error)
+	RestoreMember(id string) (*Member, error)
+	// PurgeDeleted permanently removes games and members deleted before
+	// cutoff. Members still on a game's participant list are kept.
+	PurgeDeleted(cutoff time.Time) (games, members int, err error)
+
+	// Consistency. A participant of one of the team's games is dangling
+	// when its member no longer exists, or is in the trash while the game
+	// is still upcoming.
+	ListDanglingParticipants(teamID string, now time.Time) ([]DanglingParticipant, error)
+	// RemoveDanglingParticipants removes what ListDanglingParticipants
+	// would report and returns the removed rows.
+	RemoveDanglingParticipants(teamID string, now time.Time) ([]DanglingParticipant, error)
+
+	// Seasons, newest first. Each team has its own active season.
+	ListSeasons(teamID string) ([]Season, error)
+	GetSeason(id string) (*Season, error)
+	GetActiveSeason(teamID string) (*Season, error)
+	// StartSeason archives the active season of s's team, ending it where
+	// the new one starts unless it already has an end, and makes s the
+	// team's active season.
+	StartSeason(s Season) (*Season, error)
+	UpdateSeason(id string, update SeasonUpdate) (*Season, error)
+
+	// Leagues and divisions, in creation order, narrowed to a team and a
+	// season like ListGames. Names are unique within a season
+	// (errDuplicateName); renaming bumps the version of every game using
+	// them. Deleting one that games still use fails with errInUse unless
+	// replaceWith names another one to move those games to, and returns the
+	// IDs of the live games moved.
+	ListLeagues(teamID, seasonID string) ([]League, error)
+	GetLeague(id string) (*League, error)
+	CreateLeague(l League) (*League, error)
+	UpdateLeague(l League) (*League, error)
+	DeleteLeague(id, replaceWith string) ([]string, error)
+	ListDivisions(teamID, seasonID string) ([]Division, error)
+	GetDivision(id string) (*Division, error)
+	CreateDivision(d Division) (*Division, error)
+	UpdateDivision(d Division) (*Division, error)
+	DeleteDivision(id, replaceWith string) ([]string, error)
+
+	// Opponents, by name. No two opponents share a name or alias once both
+	// go through opponentKey (errDuplicateName); renaming one renames it on
+	// its games and bumps their versions.
+	ListOpponents() ([]Opponent, error)
+	GetOpponent(id string) (*Opponent, error)
+	// FindOpponent returns the opponent whose name or alias matches name.
+	FindOpponent(name string) (*Opponent, error)
+	CreateOpponent(o Opponent) (*Opponent, error)
+	UpdateOpponent(o Opponent) (*Opponent, error)
+	// ListOpponentGames returns a team's live games against an opponent
+	// from every season, oldest first.
+	ListOpponentGames(teamID, opponentID string) ([]Game, error)
+
+	// Users. A user's PlayerID and IsManager are those of one team: GetUser
+	// fills them in for teamID (left empty if the user isn't in the team),
+	// GetUserByPlayerID for the team the player belongs to.
+	GetUser(teamID, discordID string) (*User, error)
+	GetUserByPlayerID(playerID string) (*User, error)
+	// SaveDiscordUser creates the user or refreshes their Discord profile,
+	// leaving their teams and contact details alone.
+	SaveDiscordUser(u User) error
+	// SaveTeamUser adds the user to a team or refreshes their manager flag
+	// there, leaving the linked player alone.
+	SaveTeamUser(teamID, discordID string, isManager bool) error
+	// ListUserTeams returns the teams a user is in, oldest team first.
+	ListUserTeams(discordID string) ([]TeamUser, error)
+	// LinkPlayer links the user to a player, adding them to the team first
+	// if need be.
+	LinkPlayer(teamID, discordID, playerID string) error
+	UpdateUserContact(discordID, email, phone string) error
+	// UpdateUserTimeZone sets the zone the user sees times in; "" means
+	// no preference.
+	UpdateUserTimeZone(discordID, timeZone string) error
+	ListLinkedUsers(teamID string) ([]User, error)
+	ListManagers(teamID string) ([]User, error)
+
+	// Player preferences of a team's members
+	GetPreferences(teamID string) (map[string]string, error)
+	SetPreference(playerID, preference string) error
+
+	// Settings. Team settings use keys from teamSettingKey.
+	GetSetting(key string) (string, error)
+	SetSetting(key, value string) error
+
+	// Audit log
+	RecordAudit(e AuditEvent) error
+	// ListAuditEvents returns matching events, newest first.
+	ListAuditEvents(f AuditFilter) ([]AuditEvent, error)
+}
+
+// MemberUpdate holds the member fields a manager can change; nil fields are left as they are.
+type MemberUpdate struct {
+	Name   *string
+	IsVet  *bool
+	Region *string
+}
+
+var errDuplicateMember = errors.New("member already exists")
+
+var (
+	errDuplicateName = errors.New("name is already taken")
+	errInUse         = errors.New("still used by games")
+)
+
+var (
+	errVersionConflict = errors.New("game was changed by someone else")
+	errNotOnRoster     = errors.New("member is not on the roster")
+)
+
+// Participation values stored in game_participants. Each member has at most
+// one row per game: status is their availability answer, role is what the
+// manager did with them.
+const (
+	statusAvailable   = "available"
+	statusUnavailable = "unavailable"
+	statusNone        = "none"
+
+	roleRoster    = "roster"
+	roleSub       = "sub"
+	roleWithdrawn = "withdrawn"
+	roleNone      = "none"
+)
+
+var errUnknownMember = errors.New("unknown member")
+
+type participant struct {
+	MemberID string
+	Status   string
+	Role     string
+	Position int
+}
+
+// participantsFromLists flattens a game's lists into one row per member.
+// Roster beats sub beats withdrawn when a member appears in several lists.
+func participantsFromLists(g *Game) map[string]*participant {
+	result := make(map[string]*participant)
+	get := func(id string) *participant {
+		p, ok := result[id]
+		if !ok {
+			p = &participant{MemberID: id, Status: statusNone, Role: roleNone}
+			result[id] = p
+		}
+		return p
+	}
+
+	for _, id := range g.Available {
+		get(id).Status = statusAvailable
+	}
+	for _, id := range g.Unavailable {
+		get(id).Status = statusUnavailable
+	}
+	for i, id := range g.Withdrawals {
+		p := get(id)
+		p.Role, p.Position = roleWithdrawn, i
+	}
+	for i, id := range g.Subs {
+		p := get(id)
+		p.Role, p.Position = roleSub, i
+	}
+	for i, id := range g.Roster {
+		p := get(id)
+		p.Role, p.Position = roleRoster, i
+	}
+
+	for id, p := range result {
+		if p.Status == statusNone && p.Role == roleNone {
+			delete(result, id)
+		}
+	}
+	return result
+}
+
+// addParticipantToLists appends a participant row to the matching game lists.
+// Rows must be fed in position order so the roster keeps its order.
+func addParticipantToLists(g *Game, p participant) {
+	switch p.Status {
+	case statusAvailable:
+		g.Available = append(g.Available, p.MemberID)
+	case statusUnavailable:
+		g.Unavailable = append(g.Unavailable, p.MemberID)
+	}
+	switch p.Role {
+	case roleRoster:
+		g.Roster = append(g.Roster, p.MemberID)
+	case roleSub:
+		g.Subs = append(g.Subs, p.MemberID)
+	case roleWithdrawn:
+		g.Withdrawals = append(g.Withdrawals, p.MemberID)
+	}
+}
+
+func emptyGameLists(g *Game) {
+	g.Available = []string{}
+	g.Unavailable = []string{}
+	g.Roster = []string{}
+	g.Subs = []string{}
+	g.Withdrawals = []string{}
+}
+
+// GamePatch is a partial update to a game. Nil fields are left alone; a
+// non-nil list replaces the whole list and an empty OpponentID, LeagueID or
+// DivisionID unlinks the game from its opponent, league or division.
+type GamePatch struct {
+	StartsAt   *time.Time
+	TimeZone   *string
+	Opponent   *string
+	OpponentID *string
+	LeagueID   *string
+	DivisionID *string
+	GameMode   *string
+	TeamSize   *int
+	Notes      *string
+	Reminded   *bool
+	// OccurrenceAt only changes when the whole series is retimed
+	OccurrenceAt *time.Time
+
+	Available   *[]string
+	Unavailable *[]string
+	Roster      *[]string
+	Subs        *[]string
+	Withdrawals *[]string
+}
+
+// GameChange is one game's part in a change to several games: a patch, or
+// with Delete set a move to the trash. Version works as in UpdateGame.
+type GameChange struct {
+	ID      string
+	Patch   GamePatch
+	Delete  bool
+	Version int
+}
+
+// applyColumns copies the patched game fields onto g.
+func (p GamePatch) applyColumns(g *Game) {
+	if p.StartsAt != nil {
+		g.StartsAt = p.StartsAt.UTC()
+	}
+	if p.TimeZone != nil {
+		g.TimeZone = *p.TimeZone
+	}
+	if p.Opponent != nil {
+		g.Opponent = *p.Opponent
+	}
+	if p.OpponentID != nil {
+		g.OpponentID = *p.OpponentID
+	}
+	if p.LeagueID != nil {
+		g.LeagueID = *p.LeagueID
+	}
+	if p.DivisionID != nil {
+		g.DivisionID = *p.DivisionID
+	}
+	if p.GameMode != nil {
+		g.GameMode = *p.GameMode
+	}
+	if p.TeamSize != nil {
+		g.TeamSize = *p.TeamSize
+	}
+	if p.Notes != nil {
+		g.Notes = *p.Notes
+	}
+	if p.Reminded != nil {
+		g.Reminded = *p.Reminded
+	}
+	if p.OccurrenceAt != nil {
+		occurrenceAt := p.OccurrenceAt.UTC()
+		g.OccurrenceAt = &occurrenceAt
+	}
+}
+
+// applyLists replaces the patched participant lists on g, reporting whether
+// there were any.
+func (p GamePatch) applyLists(g *Game) bool {
+	changed := false
+	set := func(dst *[]string, src *[]string) {
+		if src == nil {
+			return
+		}
+		list := []string{}
+		if *src != nil {
+			list = *src
+		}
+		*dst = list
+		changed = true
+	}
+	set(&g.Available, p.Available)
+	set(&g.Unavailable, p.Unavailable)
+	set(&g.Roster, p.Roster)
+	set(&g.Subs, p.Subs)
+	set(&g.Withdrawals, p.Withdrawals)
+	return changed
+}
+
+var store Store
+
+// Storage backends accepted in STORAGE_BACKEND.
+const (
+	backendPostgres = "postgres"
+	backendSQLite   = "sqlite"
+	backendMemory   = "memory"
+)
+
+// storageBackend reads STORAGE_BACKEND. Left unset, Postgres is used when
+// DATABASE_URL is set and the in-memory store otherwise.
+func storageBackend() string {
+	if backend := os.Getenv("STORAGE_BACKEND"); backend != "" {
+		return strings.ToLower(backend)
+	}
+	if os.Getenv("DATABASE_URL") != "" {
+		return backendPostgres
+	}
+	return backendMemory
+}
+
+// initStore opens the configured storage backend, makes sure there is a
+// team with an active season, and seeds the roster into the first team if
+// it has no members.
+func initStore() error {
+	switch backend := storageBackend(); backend {
+	case backendMemory:
+		log.Println("Using in-memory store (data is lost on restart)")
+		store = newMemoryStore()
+	case backendPostgres, backendSQLite:
+		s, err := openSQLStore(backend)
+		if err != nil {
+			return err
+		}
+		store = s
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q (want postgres, sqlite or memory)", backend)
+	}
+
+	team, err := seedTeamIfEmpty(store)
+	if err != nil {
+		return err
+	}
+	if err := seedMembersIfEmpty(store, team.ID); err != nil {
+		return err
+	}
+
+	teams, err := store.ListTeams()
+	if err != nil {
+		return err
+	}
+	for _, t := range teams {
+		if err := seedSeasonIfEmpty(store, t.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func seedMembersIfEmpty(s Store, teamID string) error {
+	members, err := s.ListMembers(teamID)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 {
+		return nil
+	}
+
+	log.Println("Seeding members table with initial data...")
+
+	// Members sitting in the trash keep their IDs, so skip those
+	for _, m := range ActiveMembers {
+		m.TeamID, m.IsVet = teamID, false
+		if err := s.CreateMember(m); err != nil && !errors.Is(err, errDuplicateMember) {
+			return err
+		}
+	}
+	for _, m := range SubMembers {
+		m.TeamID, m.IsVet = teamID, true
+		if err := s.CreateMember(m); err != nil && !errors.Is(err, errDuplicateMember) {
+			return err
+		}
+	}
+
+	log.Printf("Seeded %d active members and %d subs", len(ActiveMembers), len(SubMembers))
+	return nil
+}
@@ -0,0 +1,1780 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==================== MEMORY STORE ====================
+
+// memoryStore keeps everything in maps behind one lock. It mirrors the
+// Postgres store's behaviour (ordering, participant rows, errors) so code
+// developed against it behaves the same in production.
+type memoryStore struct {
+	mu           sync.RWMutex
+	teams        []*Team // oldest first
+	games        map[string]*Game
+	participants map[string]map[string]*memoryParticipant
+	posted       map[string]time.Time  // when each game was created
+	statLines    map[string][]StatLine // by game, ordered by member ID
+	members      map[string]*memoryMember
+	users        map[string]*User
+	teamUsers    map[string]map[string]*TeamUser // by team, then Discord ID
+	preferences  map[string]string
+	settings     map[string]string
+	seasons      map[string]*Season
+	leagues      map[string]*League
+	divisions    map[string]*Division
+	opponents    map[string]*Opponent
+	series       map[string]*Series
+	feeds        map[string]*CalendarFeed
+	audit        []AuditEvent
+}
+
+type memoryParticipant struct {
+	participant
+	UpdatedAt   time.Time
+	UpdatedBy   string
+	RespondedAt *time.Time
+	WithdrawnAt *time.Time
+}
+
+// touch fills in the first-answer and withdrawal times the row's current
+// status and role call for, as of now. A withdrawal time stays once set, so
+// the withdrawal still counts if the member is put back on the roster.
+func (p *memoryParticipant) touch(now time.Time) {
+	if p.RespondedAt == nil && p.Status != statusNone {
+		p.RespondedAt = &now
+	}
+	if p.Role == roleWithdrawn && p.WithdrawnAt == nil {
+		p.WithdrawnAt = &now
+	}
+}
+
+type memoryMember struct {
+	Member
+	SortOrder int
+	CreatedAt time.Time
+	// names holds every name the member has gone by, oldest first
+	names []string
+}
+
+// copy returns the member with their former names filled in.
+func (m *memoryMember) copy() Member {
+	member := m.Member
+	member.Aliases = nil
+	for _, name := range m.names {
+		if memberKey(name) != memberKey(m.Name) {
+			member.Aliases = append(member.Aliases, name)
+		}
+	}
+	return member
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		games:        make(map[string]*Game),
+		participants: make(map[string]map[string]*memoryParticipant),
+		posted:       make(map[string]time.Time),
+		statLines:    make(map[string][]StatLine),
+		members:      make(map[string]*memoryMember),
+		users:        make(map[string]*User),
+		teamUsers:    make(map[string]map[string]*TeamUser),
+		preferences:  make(map[string]string),
+		settings:     make(map[string]string),
+		seasons:      make(map[string]*Season),
+		leagues:      make(map[string]*League),
+		divisions:    make(map[string]*Division),
+		opponents:    make(map[string]*Opponent),
+		series:       make(map[string]*Series),
+		feeds:        make(map[string]*CalendarFeed),
+	}
+}
+
+// ---------- Teams ----------
+
+func (s *memoryStore) team(id string) *Team {
+	for _, t := range s.teams {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// slugTaken reports whether a team other than id already uses slug.
+func (s *memoryStore) slugTaken(id, slug string) bool {
+	for _, t := range s.teams {
+		if t.ID != id && t.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) ListTeams() ([]Team, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	teams := make([]Team, len(s.teams))
+	for i, t := range s.teams {
+		teams[i] = *t
+	}
+	return teams, nil
+}
+
+func (s *memoryStore) GetTeam(id string) (*Team, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t := s.team(id)
+	if t == nil {
+		return nil, nil
+	}
+	found := *t
+	return &found, nil
+}
+
+func (s *memoryStore) CreateTeam(t Team) (*Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.team(t.ID) != nil {
+		return nil, fmt.Errorf("team %s already exists", t.ID)
+	}
+	if s.slugTaken(t.ID, t.Slug) {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, t.Slug)
+	}
+	stored := t
+	s.teams = append(s.teams, &stored)
+
+	created := stored
+	return &created, nil
+}
+
+func (s *memoryStore) UpdateTeam(t Team) (*Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.team(t.ID)
+	if stored == nil {
+		return nil, nil
+	}
+	if s.slugTaken(t.ID, t.Slug) {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, t.Slug)
+	}
+	*stored = t
+
+	updated := *stored
+	return &updated, nil
+}
+
+// ---------- Games ----------
+
+// gameCopy returns a detached copy of a stored game with its participant
+// lists built the same way the Postgres store orders them.
+func (s *memoryStore) gameCopy(id string) Game {
+	g := *s.games[id]
+	emptyGameLists(&g)
+	g.League, g.Division = "", ""
+	if l, ok := s.leagues[g.LeagueID]; ok {
+		g.League = l.Name
+	}
+	if d, ok := s.divisions[g.DivisionID]; ok {
+		g.Division = d.Name
+	}
+
+	var rows []*memoryParticipant
+	for _, p := range s.participants[id] {
+		rows = append(rows, p)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Position != rows[j].Position {
+			return rows[i].Position < rows[j].Position
+		}
+		if !rows[i].UpdatedAt.Equal(rows[j].UpdatedAt) {
+			return rows[i].UpdatedAt.Before(rows[j].UpdatedAt)
+		}
+		return rows[i].MemberID < rows[j].MemberID
+	})
+	for _, p := range rows {
+		addParticipantToLists(&g, p.participant)
+	}
+
+	if g.Result != nil {
+		result := *g.Result
+		result.Rounds = append([]ResultRound{}, result.Rounds...)
+		g.Result = &result
+	}
+
+	localizeGame(&g)
+	return g
+}
+
+func (s *memoryStore) sortedGames(keep func(g *Game) bool) []Game {
+	games := []Game{}
+	for id, g := range s.games {
+		if keep == nil || keep(g) {
+			games = append(games, s.gameCopy(id))
+		}
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if !games[i].StartsAt.Equal(games[j].StartsAt) {
+			return games[i].StartsAt.Before(games[j].StartsAt)
+		}
+		return games[i].ID < games[j].ID
+	})
+	return games
+}
+
+func (s *memoryStore) ListGames(teamID, seasonID string) ([]Game, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedGames(func(g *Game) bool {
+		return g.DeletedAt == nil && (teamID == "" || g.TeamID == teamID) && (seasonID == "" || g.SeasonID == seasonID)
+	}), nil
+}
+
+func (s *memoryStore) FindGames(f GameFilter) ([]Game, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := s.sortedGames(func(g *Game) bool {
+		switch {
+		case g.DeletedAt != nil,
+			f.TeamID != "" && g.TeamID != f.TeamID,
+			f.SeasonID != "" && g.SeasonID != f.SeasonID,
+			!f.From.IsZero() && g.StartsAt.Before(f.From),
+			!f.Until.IsZero() && !g.StartsAt.Before(f.Until),
+			f.LeagueID != "" && g.LeagueID != f.LeagueID,
+			f.DivisionID != "" && g.DivisionID != f.DivisionID,
+			f.GameMode != "" && g.GameMode != f.GameMode,
+			f.OpponentID != "" && g.OpponentID != f.OpponentID,
+			f.SeriesID != "" && g.SeriesID != f.SeriesID,
+			f.Status == gameUpcoming && !g.StartsAt.After(f.Now),
+			f.Status == gamePast && g.StartsAt.After(f.Now):
+			return false
+		}
+		if f.MemberID != "" {
+			p, ok := s.participants[g.ID][f.MemberID]
+			return ok && matchesParticipation(p.participant, f.Participation)
+		}
+		return true
+	})
+
+	games := []Game{}
+	for _, g := range matched {
+		if f.After != nil && (g.StartsAt.Before(f.After.StartsAt) || g.StartsAt.Equal(f.After.StartsAt) && g.ID <= f.After.ID) {
+			continue
+		}
+		if f.Limit > 0 && len(games) == f.Limit {
+			break
+		}
+		games = append(games, g)
+	}
+	return games, len(matched), nil
+}
+
+// liveGame returns a stored game unless it is missing or in the trash.
+func (s *memoryStore) liveGame(id string) *Game {
+	g, ok := s.games[id]
+	if !ok || g.DeletedAt != nil {
+		return nil
+	}
+	return g
+}
+
+// claimGame returns a live game for a change at the given version, or
+// errVersionConflict if version is set and stale. Callers bump the version
+// once the change has been applied.
+func (s *memoryStore) claimGame(id string, version int) (*Game, error) {
+	g := s.liveGame(id)
+	if g == nil {
+		return nil, nil
+	}
+	if version > 0 && g.Version != version {
+		return nil, errVersionConflict
+	}
+	return g, nil
+}
+
+// checkNewParticipant fails with errUnknownMember unless memberID already has
+// a row on the game or is a live member of the game's team who can be given
+// one.
+func (s *memoryStore) checkNewParticipant(gameID, memberID string) error {
+	if _, ok := s.participants[gameID][memberID]; ok {
+		return nil
+	}
+	if m, ok := s.members[memberID]; !ok || m.DeletedAt != nil || m.TeamID != s.games[gameID].TeamID {
+		return fmt.Errorf("%w: %s", errUnknownMember, memberID)
+	}
+	return nil
+}
+
+func (s *memoryStore) GetGame(id string) (*Game, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.liveGame(id) == nil {
+		return nil, nil
+	}
+	g := s.gameCopy(id)
+	return &g, nil
+}
+
+func (s *memoryStore) CreateGame(g Game) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.games[g.ID]; ok {
+		return nil, fmt.Errorf("game %s already exists", g.ID)
+	}
+	s.addGame(g)
+
+	created := s.gameCopy(g.ID)
+	return &created, nil
+}
+
+func (s *memoryStore) CreateGames(games []Game, opponents []Opponent) ([]Game, []Opponent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, g := range games {
+		if _, ok := s.games[g.ID]; ok {
+			return nil, nil, fmt.Errorf("game %s already exists", g.ID)
+		}
+	}
+	for _, o := range opponents {
+		if _, ok := s.opponents[o.ID]; ok {
+			return nil, nil, fmt.Errorf("opponent %s already exists", o.ID)
+		}
+	}
+
+	// Each opponent is checked against the ones added before it, so a clash
+	// takes those back out
+	createdOpponents := make([]Opponent, len(opponents))
+	for i := range opponents {
+		if err := s.checkOpponentKeys(&opponents[i]); err != nil {
+			for _, o := range opponents[:i] {
+				delete(s.opponents, o.ID)
+			}
+			return nil, nil, err
+		}
+		createdOpponents[i] = s.addOpponent(opponents[i])
+	}
+
+	created := make([]Game, len(games))
+	for i, g := range games {
+		s.addGame(g)
+		created[i] = s.gameCopy(g.ID)
+	}
+	return created, createdOpponents, nil
+}
+
+// addGame stores a new game, unreminded at version 1 with no participants.
+func (s *memoryStore) addGame(g Game) {
+	stored := g
+	stored.StartsAt = g.StartsAt.UTC()
+	stored.Reminded = false
+	stored.DeletedAt = nil
+	stored.Version = 1
+	emptyGameLists(&stored)
+	s.games[g.ID] = &stored
+	s.participants[g.ID] = make(map[string]*memoryParticipant)
+	s.posted[g.ID] = time.Now().UTC()
+}
+
+func (s *memoryStore) UpdateGame(id string, patch GamePatch, actor string, version int) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.claimGame(id, version)
+	if stored == nil {
+		return nil, err
+	}
+
+	// Work on copies so a failed update leaves nothing half-applied
+	updatedGame := *stored
+	patch.applyColumns(&updatedGame)
+	withLists := s.gameCopy(id)
+
+	if patch.applyLists(&withLists) {
+		desired := participantsFromLists(&withLists)
+		for memberID := range desired {
+			if err := s.checkNewParticipant(id, memberID); err != nil {
+				return nil, err
+			}
+		}
+
+		now := time.Now().UTC()
+		rows := s.participants[id]
+		for memberID, p := range desired {
+			old, ok := rows[memberID]
+			if ok && old.participant == *p {
+				continue
+			}
+			row := &memoryParticipant{participant: *p, UpdatedAt: now, UpdatedBy: actor}
+			if ok {
+				row.RespondedAt, row.WithdrawnAt = old.RespondedAt, old.WithdrawnAt
+			}
+			row.touch(now)
+			rows[memberID] = row
+		}
+		for memberID := range rows {
+			if _, ok := desired[memberID]; !ok {
+				delete(rows, memberID)
+			}
+		}
+	}
+
+	updatedGame.Version++
+	*stored = updatedGame
+	g := s.gameCopy(id)
+	return &g, nil
+}
+
+func (s *memoryStore) SetAvailability(gameID, memberID, status, actor string, version int) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.claimGame(gameID, version)
+	if stored == nil {
+		return nil, err
+	}
+	if err := s.checkNewParticipant(gameID, memberID); err != nil {
+		return nil, err
+	}
+
+	rows := s.participants[gameID]
+	p, ok := rows[memberID]
+	if !ok {
+		p = &memoryParticipant{participant: participant{MemberID: memberID, Role: roleNone}}
+		rows[memberID] = p
+	}
+	p.Status = status
+	p.UpdatedAt = time.Now().UTC()
+	p.UpdatedBy = actor
+	p.touch(p.UpdatedAt)
+
+	stored.Version++
+	g := s.gameCopy(gameID)
+	return &g, nil
+}
+
+func (s *memoryStore) SetGameResult(gameID string, result *GameResult, version int) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.claimGame(gameID, version)
+	if stored == nil {
+		return nil, err
+	}
+
+	stored.Result = nil
+	if result != nil {
+		r := *result
+		r.Rounds = append([]ResultRound{}, result.Rounds...)
+		r.RecordedAt = r.RecordedAt.UTC()
+		stored.Result = &r
+	}
+
+	stored.Version++
+	g := s.gameCopy(gameID)
+	return &g, nil
+}
+
+func (s *memoryStore) WithdrawFromRoster(gameID, memberID, actor string, version int) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.claimGame(gameID, version)
+	if stored == nil {
+		return nil, err
+	}
+
+	rows := s.participants[gameID]
+	p, ok := rows[memberID]
+	if !ok || p.Role != roleRoster {
+		return nil, errNotOnRoster
+	}
+
+	// The withdrawal goes to the end of the withdrawals list
+	position := 0
+	for _, other := range rows {
+		if other.Role == roleWithdrawn && other.Position >= position {
+			position = other.Position + 1
+		}
+	}
+	p.Role = roleWithdrawn
+	p.Status = statusUnavailable
+	p.Position = position
+	p.UpdatedAt = time.Now().UTC()
+	p.UpdatedBy = actor
+	p.WithdrawnAt = nil
+	p.touch(p.UpdatedAt)
+
+	stored.Version++
+	g := s.gameCopy(gameID)
+	return &g, nil
+}
+
+func (s *memoryStore) DeleteGame(id string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.claimGame(id, version)
+	if g == nil {
+		return err
+	}
+	now := time.Now().UTC()
+	g.DeletedAt = &now
+	g.Version++
+	return nil
+}
+
+func (s *memoryStore) ListAttendance(gameIDs []string) ([]Attendance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := append([]string{}, gameIDs...)
+	sort.Strings(ids)
+	memberIDs := make([]string, 0, len(s.members))
+	for id := range s.members {
+		memberIDs = append(memberIDs, id)
+	}
+	sort.Strings(memberIDs)
+
+	attendance := []Attendance{}
+	for _, gameID := range ids {
+		g, ok := s.games[gameID]
+		if !ok {
+			continue
+		}
+		for _, memberID := range memberIDs {
+			m := s.members[memberID]
+			if m.DeletedAt != nil || m.TeamID != g.TeamID {
+				continue
+			}
+			a := Attendance{
+				GameID:   gameID,
+				MemberID: memberID,
+				PostedAt: s.posted[gameID],
+				JoinedAt: m.CreatedAt,
+				Status:   statusNone,
+				Role:     roleNone,
+			}
+			if p, ok := s.participants[gameID][memberID]; ok {
+				a.Status, a.Role = p.Status, p.Role
+				a.RespondedAt, a.WithdrawnAt = p.RespondedAt, p.WithdrawnAt
+			}
+			attendance = append(attendance, a)
+		}
+	}
+	return attendance, nil
+}
+
+// copyStatLine detaches a stat line from the one held by the store.
+func copyStatLine(line StatLine) StatLine {
+	stats := make(map[string]float64, len(line.Stats))
+	for key, value := range line.Stats {
+		stats[key] = value
+	}
+	line.Stats = stats
+	return line
+}
+
+func (s *memoryStore) ListStatLines(gameIDs []string) ([]StatLine, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := append([]string{}, gameIDs...)
+	sort.Strings(ids)
+	lines := []StatLine{}
+	for _, id := range ids {
+		for _, line := range s.statLines[id] {
+			lines = append(lines, copyStatLine(line))
+		}
+	}
+	return lines, nil
+}
+
+func (s *memoryStore) SetStatLines(gameID string, lines []StatLine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := make([]StatLine, len(lines))
+	for i, line := range lines {
+		line.GameID = gameID
+		line.RecordedAt = line.RecordedAt.UTC()
+		stored[i] = copyStatLine(line)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].MemberID < stored[j].MemberID })
+	if len(stored) == 0 {
+		delete(s.statLines, gameID)
+		return nil
+	}
+	s.statLines[gameID] = stored
+	return nil
+}
+
+func (s *memoryStore) ListPendingReminders(from, to time.Time) ([]Game, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedGames(func(g *Game) bool {
+		if g.Reminded || g.DeletedAt != nil || !g.StartsAt.After(from) || g.StartsAt.After(to) {
+			return false
+		}
+		for _, p := range s.participants[g.ID] {
+			if p.Role == roleRoster {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// ---------- Series ----------
+
+func copySeries(series *Series) Series {
+	c := *series
+	c.Exceptions = append([]string{}, series.Exceptions...)
+	return c
+}
+
+func (s *memoryStore) CreateSeries(series Series, games []Game) (*Series, []Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[series.ID]; ok {
+		return nil, nil, fmt.Errorf("series %s already exists", series.ID)
+	}
+	for _, g := range games {
+		if _, ok := s.games[g.ID]; ok {
+			return nil, nil, fmt.Errorf("game %s already exists", g.ID)
+		}
+	}
+
+	stored := copySeries(&series)
+	stored.StartsAt = series.StartsAt.UTC()
+	stored.CreatedAt = time.Now().UTC()
+	s.series[series.ID] = &stored
+
+	created := make([]Game, len(games))
+	for i, g := range games {
+		s.addGame(g)
+		created[i] = s.gameCopy(g.ID)
+	}
+	c := copySeries(&stored)
+	return &c, created, nil
+}
+
+func (s *memoryStore) GetSeries(id string) (*Series, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	series, ok := s.series[id]
+	if !ok {
+		return nil, nil
+	}
+	c := copySeries(series)
+	return &c, nil
+}
+
+func (s *memoryStore) ListSeries(teamID string) ([]Series, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []Series{}
+	for _, series := range s.series {
+		if series.TeamID == teamID {
+			list = append(list, copySeries(series))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *memoryStore) UpdateSeriesGames(series Series, changes []GameChange) ([]Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.series[series.ID]
+	if !ok {
+		return nil, fmt.Errorf("series %s not found", series.ID)
+	}
+
+	// Check every game before changing any; games gone since are skipped
+	claimed := make([]*Game, len(changes))
+	for i, change := range changes {
+		g, err := s.claimGame(change.ID, change.Version)
+		if err != nil {
+			return nil, err
+		}
+		claimed[i] = g
+	}
+
+	stored.RRule = series.RRule
+	stored.StartsAt = series.StartsAt.UTC()
+	stored.TimeZone = series.TimeZone
+	stored.Exceptions = append([]string{}, series.Exceptions...)
+
+	now := time.Now().UTC()
+	games := []Game{}
+	for i, change := range changes {
+		g := claimed[i]
+		if g == nil {
+			continue
+		}
+		g.Version++
+		if change.Delete {
+			deletedAt := now
+			g.DeletedAt = &deletedAt
+			continue
+		}
+		change.Patch.applyColumns(g)
+		games = append(games, s.gameCopy(g.ID))
+	}
+	return games, nil
+}
+
+// ---------- Calendar feeds ----------
+
+func (s *memoryStore) ListCalendarFeeds(teamID string) ([]CalendarFeed, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	feeds := []CalendarFeed{}
+	for _, f := range s.feeds {
+		if f.TeamID == teamID {
+			feeds = append(feeds, *f)
+		}
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		if !feeds[i].CreatedAt.Equal(feeds[j].CreatedAt) {
+			return feeds[i].CreatedAt.Before(feeds[j].CreatedAt)
+		}
+		return feeds[i].Token < feeds[j].Token
+	})
+	return feeds, nil
+}
+
+func (s *memoryStore) GetCalendarFeed(token string) (*CalendarFeed, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.feeds[token]
+	if !ok {
+		return nil, nil
+	}
+	c := *f
+	return &c, nil
+}
+
+func (s *memoryStore) CreateCalendarFeed(f CalendarFeed) (*CalendarFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.feeds[f.Token]; ok {
+		return nil, fmt.Errorf("feed %s already exists", f.Token)
+	}
+	stored := f
+	stored.CreatedAt = time.Now().UTC()
+	s.feeds[f.Token] = &stored
+	c := stored
+	return &c, nil
+}
+
+func (s *memoryStore) DeleteCalendarFeed(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.feeds, token)
+	return nil
+}
+
+// ---------- Members ----------
+
+func (s *memoryStore) ListMembers(teamID string) ([]Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sorted := make([]*memoryMember, 0, len(s.members))
+	for _, m := range s.members {
+		if m.DeletedAt == nil && m.TeamID == teamID {
+			sorted = append(sorted, m)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.IsVet != b.IsVet {
+			return !a.IsVet
+		}
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		return a.Name < b.Name
+	})
+
+	members := make([]Member, len(sorted))
+	for i, m := range sorted {
+		members[i] = m.copy()
+	}
+	return members, nil
+}
+
+func (s *memoryStore) GetMember(id string) (*Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.members[id]
+	if !ok {
+		return nil, nil
+	}
+	member := m.copy()
+	return &member, nil
+}
+
+// memberAliasOwner returns the team's member who has gone by a name with
+// the given key, or nil.
+func (s *memoryStore) memberAliasOwner(teamID, key string) *memoryMember {
+	for _, m := range s.members {
+		if m.TeamID != teamID {
+			continue
+		}
+		for _, name := range m.names {
+			if memberKey(name) == key {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) FindMember(teamID, name string) (*Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := memberKey(name)
+	if key == "" {
+		return nil, nil
+	}
+	m := s.memberAliasOwner(teamID, key)
+	if m == nil {
+		return nil, nil
+	}
+	member := m.copy()
+	return &member, nil
+}
+
+func (s *memoryStore) CreateMember(m Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[m.ID]; ok {
+		return fmt.Errorf("%w: %s", errDuplicateMember, m.ID)
+	}
+	if s.memberAliasOwner(m.TeamID, memberKey(m.Name)) != nil {
+		return fmt.Errorf("%w: %s", errDuplicateMember, m.Name)
+	}
+
+	order := 0
+	for _, existing := range s.members {
+		if existing.TeamID == m.TeamID && existing.IsVet == m.IsVet && existing.SortOrder >= order {
+			order = existing.SortOrder + 1
+		}
+	}
+	m.Aliases = nil
+	s.members[m.ID] = &memoryMember{Member: m, SortOrder: order, CreatedAt: time.Now().UTC(), names: []string{strings.TrimSpace(m.Name)}}
+	return nil
+}
+
+func (s *memoryStore) UpdateMember(id string, update MemberUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[id]
+	if !ok {
+		return nil
+	}
+	if update.Name != nil {
+		owner := s.memberAliasOwner(m.TeamID, memberKey(*update.Name))
+		if owner != nil && owner != m {
+			return fmt.Errorf("%w: %s", errDuplicateMember, *update.Name)
+		}
+		if owner == nil {
+			m.names = append(m.names, strings.TrimSpace(*update.Name))
+		}
+		m.Name = *update.Name
+	}
+	if update.IsVet != nil {
+		m.IsVet = *update.IsVet
+	}
+	if update.Region != nil {
+		m.Region = *update.Region
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteMember(id string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[id]
+	if !ok || m.DeletedAt != nil {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	m.DeletedAt = &now
+
+	gameIDs := []string{}
+	for _, g := range s.sortedGames(func(g *Game) bool { return g.DeletedAt == nil && g.StartsAt.After(now) }) {
+		if _, ok := s.participants[g.ID][id]; ok {
+			delete(s.participants[g.ID], id)
+			s.games[g.ID].Version++
+			gameIDs = append(gameIDs, g.ID)
+		}
+	}
+	return gameIDs, nil
+}
+
+func (s *memoryStore) SetMemberOrder(ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, id := range ids {
+		if m, ok := s.members[id]; ok {
+			m.SortOrder = i
+		}
+	}
+	return nil
+}
+
+// ---------- Seasons ----------
+
+func (s *memoryStore) ListSeasons(teamID string) ([]Season, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seasons := []Season{}
+	for _, season := range s.seasons {
+		if teamID == "" || season.TeamID == teamID {
+			seasons = append(seasons, *season)
+		}
+	}
+	sort.Slice(seasons, func(i, j int) bool {
+		if !seasons[i].StartsAt.Equal(seasons[j].StartsAt) {
+			return seasons[i].StartsAt.After(seasons[j].StartsAt)
+		}
+		return seasons[i].ID > seasons[j].ID
+	})
+	return seasons, nil
+}
+
+func (s *memoryStore) GetSeason(id string) (*Season, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	season, ok := s.seasons[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *season
+	return &found, nil
+}
+
+func (s *memoryStore) GetActiveSeason(teamID string) (*Season, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, season := range s.seasons {
+		if season.Active && season.TeamID == teamID {
+			found := *season
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) StartSeason(season Season) (*Season, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seasons[season.ID]; ok {
+		return nil, fmt.Errorf("season %s already exists", season.ID)
+	}
+
+	now := time.Now().UTC()
+	for _, old := range s.seasons {
+		if !old.Active || old.TeamID != season.TeamID {
+			continue
+		}
+		old.Active = false
+		old.ArchivedAt = &now
+		if old.EndsAt == nil {
+			endsAt := season.StartsAt.UTC()
+			old.EndsAt = &endsAt
+		}
+	}
+
+	stored := season
+	stored.StartsAt = season.StartsAt.UTC()
+	stored.Active = true
+	stored.ArchivedAt = nil
+	s.seasons[season.ID] = &stored
+
+	started := stored
+	return &started, nil
+}
+
+func (s *memoryStore) UpdateSeason(id string, update SeasonUpdate) (*Season, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	season, ok := s.seasons[id]
+	if !ok {
+		return nil, nil
+	}
+	if update.Name != nil {
+		season.Name = *update.Name
+	}
+	if update.StartsAt != nil {
+		season.StartsAt = update.StartsAt.UTC()
+	}
+	if update.EndsAt != nil {
+		endsAt := update.EndsAt.UTC()
+		season.EndsAt = &endsAt
+	}
+	updated := *season
+	return &updated, nil
+}
+
+// ---------- Leagues & divisions ----------
+
+// moveGames is the memory version of the SQL store's moveGames; ref picks
+// the game field holding the league or division ID.
+func (s *memoryStore) moveGames(ref func(g *Game) *string, id, replaceWith string) ([]string, error) {
+	var moved []string
+	for _, g := range s.sortedGames(func(g *Game) bool { return *ref(g) == id }) {
+		if replaceWith == "" {
+			return nil, errInUse
+		}
+		if g.DeletedAt == nil {
+			moved = append(moved, g.ID)
+		}
+		stored := s.games[g.ID]
+		*ref(stored) = replaceWith
+		stored.Version++
+	}
+	return moved, nil
+}
+
+// bumpGamesUsing moves every game whose ref field is id to its next version.
+func (s *memoryStore) bumpGamesUsing(ref func(g *Game) *string, id string) {
+	for _, g := range s.games {
+		if *ref(g) == id {
+			g.Version++
+		}
+	}
+}
+
+func gameLeagueID(g *Game) *string   { return &g.LeagueID }
+func gameDivisionID(g *Game) *string { return &g.DivisionID }
+
+// inTeamSeason reports whether seasonID is one of the team's seasons, or
+// always for a teamID of "".
+func (s *memoryStore) inTeamSeason(teamID, seasonID string) bool {
+	if teamID == "" {
+		return true
+	}
+	season, ok := s.seasons[seasonID]
+	return ok && season.TeamID == teamID
+}
+
+func (s *memoryStore) ListLeagues(teamID, seasonID string) ([]League, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	leagues := []League{}
+	for _, l := range s.leagues {
+		if (seasonID == "" || l.SeasonID == seasonID) && s.inTeamSeason(teamID, l.SeasonID) {
+			leagues = append(leagues, *l)
+		}
+	}
+	sort.Slice(leagues, func(i, j int) bool {
+		if leagues[i].Name != leagues[j].Name {
+			return leagues[i].Name < leagues[j].Name
+		}
+		return leagues[i].ID < leagues[j].ID
+	})
+	return leagues, nil
+}
+
+func (s *memoryStore) GetLeague(id string) (*League, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok := s.leagues[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *l
+	return &found, nil
+}
+
+// leagueNameTaken reports whether another league in the season uses name.
+func (s *memoryStore) leagueNameTaken(id, seasonID, name string) bool {
+	for _, other := range s.leagues {
+		if other.ID != id && other.SeasonID == seasonID && other.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) CreateLeague(l League) (*League, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.leagues[l.ID]; ok || s.leagueNameTaken(l.ID, l.SeasonID, l.Name) {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, l.Name)
+	}
+	stored := l
+	s.leagues[l.ID] = &stored
+	created := stored
+	return &created, nil
+}
+
+func (s *memoryStore) UpdateLeague(l League) (*League, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.leagues[l.ID]
+	if !ok {
+		return nil, nil
+	}
+	if s.leagueNameTaken(l.ID, stored.SeasonID, l.Name) {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, l.Name)
+	}
+	if l.Name != stored.Name {
+		s.bumpGamesUsing(gameLeagueID, l.ID)
+	}
+	l.SeasonID = stored.SeasonID
+	*stored = l
+	updated := *stored
+	return &updated, nil
+}
+
+func (s *memoryStore) DeleteLeague(id, replaceWith string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved, err := s.moveGames(gameLeagueID, id, replaceWith)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range s.divisions {
+		if d.LeagueID == id {
+			d.LeagueID = replaceWith
+		}
+	}
+	delete(s.leagues, id)
+	return moved, nil
+}
+
+func (s *memoryStore) ListDivisions(teamID, seasonID string) ([]Division, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	divisions := []Division{}
+	for _, d := range s.divisions {
+		if (seasonID == "" || d.SeasonID == seasonID) && s.inTeamSeason(teamID, d.SeasonID) {
+			divisions = append(divisions, *d)
+		}
+	}
+	sort.Slice(divisions, func(i, j int) bool {
+		if divisions[i].Name != divisions[j].Name {
+			return divisions[i].Name < divisions[j].Name
+		}
+		return divisions[i].ID < divisions[j].ID
+	})
+	return divisions, nil
+}
+
+func (s *memoryStore) GetDivision(id string) (*Division, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.divisions[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *d
+	return &found, nil
+}
+
+// divisionNameTaken reports whether another division in the season uses name.
+func (s *memoryStore) divisionNameTaken(id, seasonID, name string) bool {
+	for _, other := range s.divisions {
+		if other.ID != id && other.SeasonID == seasonID && other.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) CreateDivision(d Division) (*Division, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.divisions[d.ID]; ok || s.divisionNameTaken(d.ID, d.SeasonID, d.Name) {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, d.Name)
+	}
+	stored := d
+	s.divisions[d.ID] = &stored
+	created := stored
+	return &created, nil
+}
+
+func (s *memoryStore) UpdateDivision(d Division) (*Division, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.divisions[d.ID]
+	if !ok {
+		return nil, nil
+	}
+	if s.divisionNameTaken(d.ID, stored.SeasonID, d.Name) {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, d.Name)
+	}
+	if d.Name != stored.Name {
+		s.bumpGamesUsing(gameDivisionID, d.ID)
+	}
+	d.SeasonID = stored.SeasonID
+	*stored = d
+	updated := *stored
+	return &updated, nil
+}
+
+func (s *memoryStore) DeleteDivision(id, replaceWith string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved, err := s.moveGames(gameDivisionID, id, replaceWith)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.divisions, id)
+	return moved, nil
+}
+
+// ---------- Opponents ----------
+
+func copyOpponent(o *Opponent) Opponent {
+	c := *o
+	c.Aliases = append([]string{}, o.Aliases...)
+	return c
+}
+
+// findOpponentID returns the ID of the opponent whose name or alias has the
+// given key, or "".
+func (s *memoryStore) findOpponentID(key string) string {
+	for id, o := range s.opponents {
+		for _, k := range opponentKeys(o) {
+			if k == key {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// checkOpponentKeys fails with errDuplicateName if the opponent's name or one
+// of its aliases belongs to another opponent.
+func (s *memoryStore) checkOpponentKeys(o *Opponent) error {
+	for _, key := range opponentKeys(o) {
+		if owner := s.findOpponentID(key); owner != "" && owner != o.ID {
+			return fmt.Errorf("%w: %s", errDuplicateName, key)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) ListOpponents() ([]Opponent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	opponents := []Opponent{}
+	for _, o := range s.opponents {
+		opponents = append(opponents, copyOpponent(o))
+	}
+	sort.Slice(opponents, func(i, j int) bool {
+		if opponents[i].Name != opponents[j].Name {
+			return opponents[i].Name < opponents[j].Name
+		}
+		return opponents[i].ID < opponents[j].ID
+	})
+	return opponents, nil
+}
+
+func (s *memoryStore) GetOpponent(id string) (*Opponent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.opponents[id]
+	if !ok {
+		return nil, nil
+	}
+	found := copyOpponent(o)
+	return &found, nil
+}
+
+func (s *memoryStore) FindOpponent(name string) (*Opponent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := opponentKey(name)
+	if key == "" {
+		return nil, nil
+	}
+	id := s.findOpponentID(key)
+	if id == "" {
+		return nil, nil
+	}
+	found := copyOpponent(s.opponents[id])
+	return &found, nil
+}
+
+func (s *memoryStore) CreateOpponent(o Opponent) (*Opponent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.opponents[o.ID]; ok {
+		return nil, fmt.Errorf("opponent %s already exists", o.ID)
+	}
+	if err := s.checkOpponentKeys(&o); err != nil {
+		return nil, err
+	}
+	created := s.addOpponent(o)
+	return &created, nil
+}
+
+// addOpponent stores a new opponent and returns a copy of it as stored.
+func (s *memoryStore) addOpponent(o Opponent) Opponent {
+	stored := copyOpponent(&o)
+	sort.Strings(stored.Aliases)
+	s.opponents[o.ID] = &stored
+	return copyOpponent(&stored)
+}
+
+func (s *memoryStore) UpdateOpponent(o Opponent) (*Opponent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.opponents[o.ID]
+	if !ok {
+		return nil, nil
+	}
+	if err := s.checkOpponentKeys(&o); err != nil {
+		return nil, err
+	}
+	if o.Name != stored.Name {
+		for _, g := range s.games {
+			if g.OpponentID == o.ID {
+				g.Opponent = o.Name
+				g.Version++
+			}
+		}
+	}
+	*stored = copyOpponent(&o)
+	sort.Strings(stored.Aliases)
+
+	updated := copyOpponent(stored)
+	return &updated, nil
+}
+
+func (s *memoryStore) ListOpponentGames(teamID, opponentID string) ([]Game, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedGames(func(g *Game) bool {
+		return g.DeletedAt == nil && g.OpponentID == opponentID && g.TeamID == teamID
+	}), nil
+}
+
+// ---------- Consistency ----------
+
+func (s *memoryStore) danglingParticipants(teamID string, now time.Time) []DanglingParticipant {
+	dangling := []DanglingParticipant{}
+	for _, g := range s.sortedGames(func(g *Game) bool { return g.DeletedAt == nil && g.TeamID == teamID }) {
+		rows := s.participants[g.ID]
+		ids := make([]string, 0, len(rows))
+		for memberID := range rows {
+			ids = append(ids, memberID)
+		}
+		sort.Strings(ids)
+
+		for _, memberID := range ids {
+			reason := ""
+			if m, ok := s.members[memberID]; !ok {
+				reason = danglingMissing
+			} else if m.DeletedAt != nil && g.StartsAt.After(now) {
+				reason = danglingDeleted
+			} else {
+				continue
+			}
+			p := rows[memberID]
+			dangling = append(dangling, DanglingParticipant{
+				GameID:   g.ID,
+				StartsAt: g.StartsAt,
+				MemberID: memberID,
+				Role:     p.Role,
+				Status:   p.Status,
+				Reason:   reason,
+			})
+		}
+	}
+	return dangling
+}
+
+func (s *memoryStore) ListDanglingParticipants(teamID string, now time.Time) ([]DanglingParticipant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.danglingParticipants(teamID, now), nil
+}
+
+func (s *memoryStore) RemoveDanglingParticipants(teamID string, now time.Time) ([]DanglingParticipant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dangling := s.danglingParticipants(teamID, now)
+	bumped := make(map[string]bool)
+	for _, d := range dangling {
+		delete(s.participants[d.GameID], d.MemberID)
+		if !bumped[d.GameID] {
+			s.games[d.GameID].Version++
+			bumped[d.GameID] = true
+		}
+	}
+	return dangling, nil
+}
+
+// ---------- Trash ----------
+
+func (s *memoryStore) ListDeletedGames(teamID string) ([]Game, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	games := s.sortedGames(func(g *Game) bool { return g.DeletedAt != nil && g.TeamID == teamID })
+	sort.SliceStable(games, func(i, j int) bool { return games[i].DeletedAt.After(*games[j].DeletedAt) })
+	return games, nil
+}
+
+func (s *memoryStore) RestoreGame(id string) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.games[id]
+	if !ok || g.DeletedAt == nil {
+		return nil, nil
+	}
+	g.DeletedAt = nil
+	g.Version++
+	restored := s.gameCopy(id)
+	return &restored, nil
+}
+
+func (s *memoryStore) ListDeletedMembers(teamID string) ([]Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var members []Member
+	for _, m := range s.members {
+		if m.DeletedAt != nil && m.TeamID == teamID {
+			members = append(members, m.copy())
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].DeletedAt.After(*members[j].DeletedAt) })
+	return members, nil
+}
+
+func (s *memoryStore) RestoreMember(id string) (*Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[id]
+	if !ok || m.DeletedAt == nil {
+		return nil, nil
+	}
+	m.DeletedAt = nil
+	restored := m.copy()
+	return &restored, nil
+}
+
+func (s *memoryStore) PurgeDeleted(cutoff time.Time) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	games := 0
+	for id, g := range s.games {
+		if g.DeletedAt != nil && g.DeletedAt.Before(cutoff) {
+			delete(s.games, id)
+			delete(s.participants, id)
+			delete(s.posted, id)
+			delete(s.statLines, id)
+			games++
+		}
+	}
+
+	referenced := make(map[string]bool)
+	for _, rows := range s.participants {
+		for memberID := range rows {
+			referenced[memberID] = true
+		}
+	}
+	for _, lines := range s.statLines {
+		for _, line := range lines {
+			referenced[line.MemberID] = true
+		}
+	}
+	members := 0
+	for id, m := range s.members {
+		if m.DeletedAt != nil && m.DeletedAt.Before(cutoff) && !referenced[id] {
+			delete(s.members, id)
+			members++
+		}
+	}
+	return games, members, nil
+}
+
+// ---------- Users ----------
+
+// teamUser returns a copy of the stored user with the team's player and
+// manager flag filled in.
+func (s *memoryStore) teamUser(teamID string, u *User) User {
+	user := *u
+	if tu, ok := s.teamUsers[teamID][u.DiscordID]; ok {
+		user.PlayerID, user.IsManager = tu.PlayerID, tu.IsManager
+	}
+	return user
+}
+
+// listTeamUsers returns the team's users that match, by Discord ID.
+func (s *memoryStore) listTeamUsers(teamID string, match func(tu *TeamUser) bool) []User {
+	var users []User
+	for discordID, tu := range s.teamUsers[teamID] {
+		if u, ok := s.users[discordID]; ok && match(tu) {
+			users = append(users, s.teamUser(teamID, u))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].DiscordID < users[j].DiscordID })
+	return users
+}
+
+// joinTeam returns the user's row in the team, adding one if need be.
+func (s *memoryStore) joinTeam(teamID, discordID string) *TeamUser {
+	if s.teamUsers[teamID] == nil {
+		s.teamUsers[teamID] = make(map[string]*TeamUser)
+	}
+	tu, ok := s.teamUsers[teamID][discordID]
+	if !ok {
+		tu = &TeamUser{TeamID: teamID}
+		s.teamUsers[teamID][discordID] = tu
+	}
+	return tu
+}
+
+func (s *memoryStore) GetUser(teamID, discordID string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[discordID]
+	if !ok {
+		return nil, nil
+	}
+	user := s.teamUser(teamID, u)
+	return &user, nil
+}
+
+func (s *memoryStore) GetUserByPlayerID(playerID string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for teamID, byUser := range s.teamUsers {
+		for discordID, tu := range byUser {
+			if u, ok := s.users[discordID]; ok && tu.PlayerID == playerID {
+				user := s.teamUser(teamID, u)
+				return &user, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) SaveDiscordUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[u.DiscordID]
+	if !ok {
+		user := User{DiscordID: u.DiscordID}
+		existing = &user
+		s.users[u.DiscordID] = existing
+	}
+	existing.Username = u.Username
+	existing.DisplayName = u.DisplayName
+	existing.Avatar = u.Avatar
+	return nil
+}
+
+func (s *memoryStore) SaveTeamUser(teamID, discordID string, isManager bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[discordID]; ok {
+		s.joinTeam(teamID, discordID).IsManager = isManager
+	}
+	return nil
+}
+
+func (s *memoryStore) ListUserTeams(discordID string) ([]TeamUser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	teams := []TeamUser{}
+	for _, t := range s.teams {
+		if tu, ok := s.teamUsers[t.ID][discordID]; ok {
+			teams = append(teams, *tu)
+		}
+	}
+	return teams, nil
+}
+
+func (s *memoryStore) LinkPlayer(teamID, discordID, playerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[discordID]; ok {
+		s.joinTeam(teamID, discordID).PlayerID = playerID
+	}
+	return nil
+}
+
+func (s *memoryStore) UpdateUserContact(discordID, email, phone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[discordID]; ok {
+		u.Email = email
+		u.Phone = phone
+	}
+	return nil
+}
+
+func (s *memoryStore) UpdateUserTimeZone(discordID, timeZone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[discordID]; ok {
+		u.TimeZone = timeZone
+	}
+	return nil
+}
+
+func (s *memoryStore) ListLinkedUsers(teamID string) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listTeamUsers(teamID, func(tu *TeamUser) bool { return tu.PlayerID != "" }), nil
+}
+
+func (s *memoryStore) ListManagers(teamID string) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listTeamUsers(teamID, func(tu *TeamUser) bool { return tu.IsManager }), nil
+}
+
+// ---------- Preferences & settings ----------
+
+func (s *memoryStore) GetPreferences(teamID string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs := make(map[string]string)
+	for k, v := range s.preferences {
+		if m, ok := s.members[k]; ok && m.TeamID == teamID {
+			prefs[k] = v
+		}
+	}
+	return prefs, nil
+}
+
+func (s *memoryStore) SetPreference(playerID, preference string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.preferences[playerID] = preference
+	return nil
+}
+
+func (s *memoryStore) GetSetting(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.settings[key], nil
+}
+
+func (s *memoryStore) SetSetting(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settings[key] = value
+	return nil
+}
+
+// ---------- Audit log ----------
+
+func (s *memoryStore) RecordAudit(e AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = int64(len(s.audit) + 1)
+	e.At = e.At.UTC()
+	s.audit = append(s.audit, e)
+	return nil
+}
+
+func (s *memoryStore) ListAuditEvents(f AuditFilter) ([]AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []AuditEvent{}
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		e := s.audit[i]
+		if (f.TeamID != "" && e.TeamID != f.TeamID) ||
+			(f.EntityType != "" && e.EntityType != f.EntityType) ||
+			(f.EntityID != "" && e.EntityID != f.EntityID) ||
+			(f.Actor != "" && e.Actor != f.Actor) ||
+			(!f.Since.IsZero() && e.At.Before(f.Since)) ||
+			(!f.Until.IsZero() && !e.At.Before(f.Until)) {
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.After(events[j].At) })
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
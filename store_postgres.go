@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// ==================== POSTGRES ====================
+
+// migrationLockID is the Postgres advisory lock key held while migrations run,
+// so two instances starting at the same time can't apply the same migration twice.
+const migrationLockID int64 = 0x60ca1e4da7
+
+var postgresDialect = &sqlDialect{
+	name:           backendPostgres,
+	timestampType:  "TIMESTAMPTZ",
+	lockMigrations: lockPostgresMigrations,
+	tableExists:    `SELECT to_regclass($1) IS NOT NULL`,
+	migrations:     withSharedMigrations(migrations),
+}
+
+// lockPostgresMigrations takes the migration advisory lock. Advisory locks
+// belong to a session, which is why migrations run on a single *sql.Conn.
+func lockPostgresMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}, nil
+}
+
+// openPostgres connects to DATABASE_URL and makes sure the go_calendar schema exists.
+func openPostgres() (*sql.DB, error) {
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		return nil, fmt.Errorf("DATABASE_URL not set")
+	}
+
+	// Add search_path to connection string for go_calendar schema
+	if strings.Contains(dbURL, "?") {
+		dbURL += "&search_path=go_calendar"
+	} else {
+		dbURL += "?search_path=go_calendar"
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	// Create schema for go_calendar to keep tables separate from hopzle
+	_, err = db.Exec(`CREATE SCHEMA IF NOT EXISTS go_calendar`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %v", err)
+	}
+
+	return db, nil
+}
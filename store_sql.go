@@ -0,0 +1,2359 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ==================== SQL STORE ====================
+
+// sqlStore implements Store on top of database/sql. Queries are written in
+// Postgres syntax with $N placeholders; the dialect rewrites them for the
+// database it actually talks to (see store_postgres.go and store_sqlite.go).
+type sqlStore struct {
+	db sqlDB
+}
+
+// querier is satisfied by sqlDB, sqlTx and a plain *sql.Tx.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// sqlDialect describes what differs between the SQL databases we support.
+type sqlDialect struct {
+	name string
+	// rebind rewrites $N placeholders into the dialect's own syntax; nil
+	// means the query is used as written.
+	rebind func(query string) string
+	// timestampType is the column type used for timestamps in tables the
+	// migration runner creates itself and in place of {{timestamp}} in
+	// migrations.
+	timestampType string
+	// lockMigrations holds a lock on conn for the duration of a migration
+	// run and returns the function that releases it.
+	lockMigrations func(ctx context.Context, conn *sql.Conn) (func(), error)
+	// tableExists is a query for whether the table named by $1 exists,
+	// returning one boolean.
+	tableExists string
+	migrations  []migration
+}
+
+func (d *sqlDialect) bind(query string) string {
+	if d.rebind == nil {
+		return query
+	}
+	return d.rebind(query)
+}
+
+// sqlDB wraps a connection pool so every query goes through the dialect.
+type sqlDB struct {
+	*sql.DB
+	dialect *sqlDialect
+}
+
+func (d sqlDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.Exec(d.dialect.bind(query), args...)
+}
+
+func (d sqlDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.Query(d.dialect.bind(query), args...)
+}
+
+func (d sqlDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRow(d.dialect.bind(query), args...)
+}
+
+func (d sqlDB) Begin() (sqlTx, error) {
+	tx, err := d.DB.Begin()
+	return sqlTx{Tx: tx, dialect: d.dialect}, err
+}
+
+// sqlTx is the transaction counterpart of sqlDB.
+type sqlTx struct {
+	*sql.Tx
+	dialect *sqlDialect
+}
+
+func (t sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.bind(query), args...)
+}
+
+func (t sqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.bind(query), args...)
+}
+
+func (t sqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.bind(query), args...)
+}
+
+func (t sqlTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, t.dialect.bind(query), args...)
+}
+
+func (t sqlTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, t.dialect.bind(query), args...)
+}
+
+func (t sqlTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, t.dialect.bind(query), args...)
+}
+
+// openSQLDatabase connects to the database for a SQL storage backend.
+func openSQLDatabase(backend string) (sqlDB, error) {
+	switch backend {
+	case backendPostgres:
+		db, err := openPostgres()
+		if err != nil {
+			return sqlDB{}, err
+		}
+		return sqlDB{DB: db, dialect: postgresDialect}, nil
+	case backendSQLite:
+		db, err := openSQLite(sqlitePath())
+		if err != nil {
+			return sqlDB{}, err
+		}
+		return sqlDB{DB: db, dialect: sqliteDialect}, nil
+	}
+	return sqlDB{}, fmt.Errorf("storage backend %q is not a SQL database", backend)
+}
+
+// openSQLStore connects and brings the schema up to date.
+func openSQLStore(backend string) (*sqlStore, error) {
+	db, err := openSQLDatabase(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := migrateUp(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+	if count > 0 {
+		log.Printf("Applied %d migration(s)", count)
+	}
+
+	log.Printf("Database initialized (%s)", db.dialect.name)
+	return &sqlStore{db: db}, nil
+}
+
+// ---------- Teams ----------
+
+const teamColumns = `id, slug, name, COALESCE(discord_guild_id, ''), COALESCE(discord_manager_role, '')`
+
+func scanTeam(row interface{ Scan(...interface{}) error }, t *Team) error {
+	return row.Scan(&t.ID, &t.Slug, &t.Name, &t.DiscordGuildID, &t.DiscordManagerRole)
+}
+
+// slugTaken reports whether a team other than id already uses slug.
+func slugTaken(q querier, id, slug string) (bool, error) {
+	var taken bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM teams WHERE slug = $1 AND id <> $2)`, slug, id).Scan(&taken)
+	return taken, err
+}
+
+func (s *sqlStore) ListTeams() ([]Team, error) {
+	rows, err := s.db.Query(`SELECT ` + teamColumns + ` FROM teams ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []Team{}
+	for rows.Next() {
+		var t Team
+		if err := scanTeam(rows, &t); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+func (s *sqlStore) GetTeam(id string) (*Team, error) {
+	var t Team
+	err := scanTeam(s.db.QueryRow(`SELECT `+teamColumns+` FROM teams WHERE id = $1`, id), &t)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *sqlStore) CreateTeam(t Team) (*Team, error) {
+	res, err := s.db.Exec(`INSERT INTO teams (id, slug, name, discord_guild_id, discord_manager_role)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`,
+		t.ID, t.Slug, t.Name, nullString(t.DiscordGuildID), nullString(t.DiscordManagerRole))
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, t.Slug)
+	}
+	return s.GetTeam(t.ID)
+}
+
+func (s *sqlStore) UpdateTeam(t Team) (*Team, error) {
+	if taken, err := slugTaken(s.db, t.ID, t.Slug); err != nil || taken {
+		if err == nil {
+			err = fmt.Errorf("%w: %s", errDuplicateName, t.Slug)
+		}
+		return nil, err
+	}
+	_, err := s.db.Exec(`UPDATE teams SET slug = $2, name = $3, discord_guild_id = $4, discord_manager_role = $5 WHERE id = $1`,
+		t.ID, t.Slug, t.Name, nullString(t.DiscordGuildID), nullString(t.DiscordManagerRole))
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTeam(t.ID)
+}
+
+// ---------- Games ----------
+
+// gameColumns looks up league and division names with correlated subqueries,
+// so queries selecting it must not alias the games table.
+const gameColumns = `id, starts_at, time_zone, opponent, COALESCE(opponent_id, ''),
+	COALESCE(league_id, ''), COALESCE(division_id, ''),
+	COALESCE((SELECT name FROM leagues WHERE leagues.id = games.league_id), ''),
+	COALESCE((SELECT name FROM divisions WHERE divisions.id = games.division_id), ''),
+	COALESCE(game_mode, 'War'), COALESCE(team_size, 10), COALESCE(notes, ''), COALESCE(reminded, false), COALESCE(team_id, ''), COALESCE(season_id, ''), deleted_at, version,
+	COALESCE(series_id, ''), occurrence_at`
+
+func scanGame(row interface{ Scan(...interface{}) error }, g *Game) error {
+	if err := row.Scan(&g.ID, &g.StartsAt, &g.TimeZone, &g.Opponent, &g.OpponentID, &g.LeagueID, &g.DivisionID, &g.League, &g.Division, &g.GameMode, &g.TeamSize, &g.Notes, &g.Reminded, &g.TeamID, &g.SeasonID, &g.DeletedAt, &g.Version,
+		&g.SeriesID, &g.OccurrenceAt); err != nil {
+		return err
+	}
+	g.StartsAt = g.StartsAt.UTC()
+	if g.OccurrenceAt != nil {
+		occurrenceAt := g.OccurrenceAt.UTC()
+		g.OccurrenceAt = &occurrenceAt
+	}
+	localizeGame(g)
+	return nil
+}
+
+// queryGames runs a SELECT over games (which must select gameColumns) and
+// returns the games with their participant lists filled in.
+func queryGames(q querier, query string, args ...interface{}) ([]Game, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := []Game{}
+	for rows.Next() {
+		var g Game
+		if err := scanGame(rows, &g); err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := attachParticipants(q, games); err != nil {
+		return nil, err
+	}
+	if err := attachResults(q, games); err != nil {
+		return nil, err
+	}
+	return games, nil
+}
+
+func loadGame(q querier, gameID string) (*Game, error) {
+	games, err := queryGames(q, `SELECT `+gameColumns+` FROM games WHERE id = $1 AND deleted_at IS NULL`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	if len(games) == 0 {
+		return nil, nil
+	}
+	return &games[0], nil
+}
+
+// attachParticipants loads game_participants for the given games and fills
+// in their availability and roster lists.
+func attachParticipants(q querier, games []Game) error {
+	if len(games) == 0 {
+		return nil
+	}
+
+	index := make(map[string]*Game, len(games))
+	placeholders := make([]string, len(games))
+	args := make([]interface{}, len(games))
+	for i := range games {
+		emptyGameLists(&games[i])
+		index[games[i].ID] = &games[i]
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = games[i].ID
+	}
+
+	rows, err := q.Query(fmt.Sprintf(`SELECT game_id, member_id, status, role, position
+		FROM game_participants WHERE game_id IN (%s)
+		ORDER BY position, updated_at, member_id`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gameID string
+		var p participant
+		if err := rows.Scan(&gameID, &p.MemberID, &p.Status, &p.Role, &p.Position); err != nil {
+			return err
+		}
+		if g, ok := index[gameID]; ok {
+			addParticipantToLists(g, p)
+		}
+	}
+	return rows.Err()
+}
+
+// attachResults loads game_results for the given games.
+func attachResults(q querier, games []Game) error {
+	if len(games) == 0 {
+		return nil
+	}
+
+	index := make(map[string]*Game, len(games))
+	placeholders := make([]string, len(games))
+	args := make([]interface{}, len(games))
+	for i := range games {
+		index[games[i].ID] = &games[i]
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = games[i].ID
+	}
+
+	rows, err := q.Query(fmt.Sprintf(`SELECT game_id, our_score, their_score, outcome, forfeit, rounds, COALESCE(recorded_by, ''), recorded_at
+		FROM game_results WHERE game_id IN (%s)`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gameID string
+		var rounds []byte
+		r := &GameResult{}
+		if err := rows.Scan(&gameID, &r.OurScore, &r.TheirScore, &r.Outcome, &r.Forfeit, &rounds, &r.RecordedBy, &r.RecordedAt); err != nil {
+			return err
+		}
+		r.RecordedAt = r.RecordedAt.UTC()
+		if err := json.Unmarshal(rounds, &r.Rounds); err != nil {
+			return fmt.Errorf("game %s result rounds: %v", gameID, err)
+		}
+		if g, ok := index[gameID]; ok {
+			g.Result = r
+		}
+	}
+	return rows.Err()
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// syncParticipants makes the game_participants rows for a game match the
+// lists on g, touching only rows that actually changed so updated_at and
+// updated_by keep pointing at the last real change.
+func syncParticipants(q querier, g *Game, actor string) error {
+	rows, err := q.Query(`SELECT member_id, status, role, position FROM game_participants WHERE game_id = $1`, g.ID)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]participant)
+	for rows.Next() {
+		var p participant
+		if err := rows.Scan(&p.MemberID, &p.Status, &p.Role, &p.Position); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[p.MemberID] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	updatedBy := nullString(actor)
+
+	desired := participantsFromLists(g)
+	for id, p := range desired {
+		old, ok := existing[id]
+		if ok && old == *p {
+			continue
+		}
+		if !ok {
+			var exists bool
+			if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM members WHERE id = $1 AND deleted_at IS NULL
+				AND team_id = (SELECT team_id FROM games WHERE id = $2))`, id, g.ID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%w: %s", errUnknownMember, id)
+			}
+		}
+		_, err := q.Exec(`
+			INSERT INTO game_participants (game_id, member_id, status, role, position, updated_at, updated_by, responded_at, withdrawn_at)
+			VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, $6,
+				CASE WHEN $3 <> 'none' THEN CURRENT_TIMESTAMP END, CASE WHEN $4 = 'withdrawn' THEN CURRENT_TIMESTAMP END)
+			ON CONFLICT (game_id, member_id) DO UPDATE SET
+				status = $3, role = $4, position = $5, updated_at = CURRENT_TIMESTAMP, updated_by = $6,
+				responded_at = COALESCE(game_participants.responded_at, excluded.responded_at),
+				withdrawn_at = COALESCE(game_participants.withdrawn_at, excluded.withdrawn_at)
+		`, g.ID, id, p.Status, p.Role, p.Position, updatedBy)
+		if err != nil {
+			return err
+		}
+	}
+
+	for id := range existing {
+		if _, ok := desired[id]; ok {
+			continue
+		}
+		if _, err := q.Exec(`DELETE FROM game_participants WHERE game_id = $1 AND member_id = $2`, g.ID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bumpGameVersion moves a live game to its next version inside q, which
+// should be a transaction so the bump is undone if the change fails. It
+// reports whether the game exists and returns errVersionConflict when a
+// non-zero version doesn't match.
+func bumpGameVersion(q querier, id string, version int) (bool, error) {
+	return updateGameRow(q, id, GamePatch{}, version)
+}
+
+// updateGameRow writes the patch's game columns and bumps the version in a
+// single UPDATE, reporting like bumpGameVersion. Column names come from the
+// fixed list below, never from the caller.
+func updateGameRow(q querier, id string, patch GamePatch, version int) (bool, error) {
+	sets := []string{"version = version + 1"}
+	args := []interface{}{id}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if patch.StartsAt != nil {
+		set("starts_at", patch.StartsAt.UTC())
+	}
+	if patch.TimeZone != nil {
+		set("time_zone", *patch.TimeZone)
+	}
+	if patch.Opponent != nil {
+		set("opponent", *patch.Opponent)
+	}
+	if patch.OpponentID != nil {
+		set("opponent_id", nullString(*patch.OpponentID))
+	}
+	if patch.LeagueID != nil {
+		set("league_id", nullString(*patch.LeagueID))
+	}
+	if patch.DivisionID != nil {
+		set("division_id", nullString(*patch.DivisionID))
+	}
+	if patch.GameMode != nil {
+		set("game_mode", *patch.GameMode)
+	}
+	if patch.TeamSize != nil {
+		set("team_size", *patch.TeamSize)
+	}
+	if patch.Notes != nil {
+		set("notes", *patch.Notes)
+	}
+	if patch.Reminded != nil {
+		set("reminded", *patch.Reminded)
+	}
+	if patch.OccurrenceAt != nil {
+		set("occurrence_at", patch.OccurrenceAt.UTC())
+	}
+
+	query := `UPDATE games SET ` + strings.Join(sets, ", ") + ` WHERE id = $1 AND deleted_at IS NULL`
+	if version > 0 {
+		args = append(args, version)
+		query += fmt.Sprintf(` AND version = $%d`, len(args))
+	}
+	res, err := q.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	if version == 0 {
+		return false, nil
+	}
+
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM games WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, errVersionConflict
+	}
+	return false, nil
+}
+
+// teamSeasonFilter narrows a WHERE clause to a team and a season, skipping
+// either one that is "". Its placeholders start after the first args.
+func teamSeasonFilter(args []interface{}, teamID, seasonID string) (string, []interface{}) {
+	where := ""
+	if teamID != "" {
+		args = append(args, teamID)
+		where += fmt.Sprintf(" AND team_id = $%d", len(args))
+	}
+	if seasonID != "" {
+		args = append(args, seasonID)
+		where += fmt.Sprintf(" AND season_id = $%d", len(args))
+	}
+	return where, args
+}
+
+func (s *sqlStore) ListGames(teamID, seasonID string) ([]Game, error) {
+	where, args := teamSeasonFilter(nil, teamID, seasonID)
+	return queryGames(s.db, `SELECT `+gameColumns+` FROM games WHERE deleted_at IS NULL`+where+` ORDER BY starts_at`, args...)
+}
+
+// gameParticipationConditions are what a participant row must match for
+// each GameFilter participation.
+var gameParticipationConditions = map[string]string{
+	"":                       "(p.role <> 'none' OR p.status <> 'none')",
+	participationRostered:    "p.role = 'roster'",
+	participationSub:         "p.role = 'sub'",
+	participationWithdrawn:   "p.role = 'withdrawn'",
+	participationAvailable:   "p.status = 'available'",
+	participationUnavailable: "p.status = 'unavailable'",
+}
+
+func (s *sqlStore) FindGames(f GameFilter) ([]Game, int, error) {
+	where, args := teamSeasonFilter(nil, f.TeamID, f.SeasonID)
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		where += " AND " + fmt.Sprintf(condition, len(args))
+	}
+	if !f.From.IsZero() {
+		add("starts_at >= $%d", f.From.UTC())
+	}
+	if !f.Until.IsZero() {
+		add("starts_at < $%d", f.Until.UTC())
+	}
+	if f.LeagueID != "" {
+		add("league_id = $%d", f.LeagueID)
+	}
+	if f.DivisionID != "" {
+		add("division_id = $%d", f.DivisionID)
+	}
+	if f.GameMode != "" {
+		add("COALESCE(game_mode, 'War') = $%d", f.GameMode)
+	}
+	if f.OpponentID != "" {
+		add("opponent_id = $%d", f.OpponentID)
+	}
+	if f.SeriesID != "" {
+		add("series_id = $%d", f.SeriesID)
+	}
+	if f.MemberID != "" {
+		add("EXISTS (SELECT 1 FROM game_participants p WHERE p.game_id = games.id AND p.member_id = $%d AND "+
+			gameParticipationConditions[f.Participation]+")", f.MemberID)
+	}
+	switch f.Status {
+	case gameUpcoming:
+		add("starts_at > $%d", f.Now.UTC())
+	case gamePast:
+		add("starts_at <= $%d", f.Now.UTC())
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM games WHERE deleted_at IS NULL`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if f.After != nil {
+		args = append(args, f.After.StartsAt.UTC(), f.After.ID)
+		where += fmt.Sprintf(" AND (starts_at > $%d OR (starts_at = $%d AND id > $%d))", len(args)-1, len(args)-1, len(args))
+	}
+	query := `SELECT ` + gameColumns + ` FROM games WHERE deleted_at IS NULL` + where + ` ORDER BY starts_at, id`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	games, err := queryGames(s.db, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return games, total, nil
+}
+
+func (s *sqlStore) GetGame(id string) (*Game, error) {
+	return loadGame(s.db, id)
+}
+
+// insertGame writes a new game row; it starts out unreminded at version 1.
+func insertGame(q querier, g *Game) error {
+	var occurrenceAt interface{}
+	if g.OccurrenceAt != nil {
+		occurrenceAt = g.OccurrenceAt.UTC()
+	}
+	_, err := q.Exec(
+		`INSERT INTO games (id, starts_at, time_zone, opponent, opponent_id, league_id, division_id, game_mode, team_size, notes, reminded, team_id, season_id,
+			series_id, occurrence_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, false, $11, $12, $13, $14)`,
+		g.ID, g.StartsAt.UTC(), g.TimeZone, g.Opponent, nullString(g.OpponentID), nullString(g.LeagueID), nullString(g.DivisionID),
+		g.GameMode, g.TeamSize, g.Notes, nullString(g.TeamID), nullString(g.SeasonID), nullString(g.SeriesID), occurrenceAt,
+	)
+	return err
+}
+
+func (s *sqlStore) CreateGame(g Game) (*Game, error) {
+	if err := insertGame(s.db, &g); err != nil {
+		return nil, err
+	}
+
+	return s.GetGame(g.ID)
+}
+
+func (s *sqlStore) CreateGames(games []Game, opponents []Opponent) ([]Game, []Opponent, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	createdOpponents := make([]Opponent, len(opponents))
+	for i := range opponents {
+		o, err := insertOpponent(tx, &opponents[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		createdOpponents[i] = *o
+	}
+	created, err := insertGames(tx, games)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return created, createdOpponents, nil
+}
+
+// insertGames inserts games and loads them back as stored.
+func insertGames(q querier, games []Game) ([]Game, error) {
+	created := make([]Game, len(games))
+	for i := range games {
+		if err := insertGame(q, &games[i]); err != nil {
+			return nil, err
+		}
+		g, err := loadGame(q, games[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		created[i] = *g
+	}
+	return created, nil
+}
+
+func (s *sqlStore) UpdateGame(id string, patch GamePatch, actor string, version int) (*Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	found, err := updateGameRow(tx, id, patch, version)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	game, err := loadGame(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.applyLists(game) {
+		if err := syncParticipants(tx, game, actor); err != nil {
+			return nil, err
+		}
+		if game, err = loadGame(tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func (s *sqlStore) SetAvailability(gameID, memberID, status, actor string, version int) (*Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	found, err := bumpGameVersion(tx, gameID, version)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	res, err := tx.Exec(`UPDATE game_participants SET status = $1, updated_at = CURRENT_TIMESTAMP, updated_by = $2,
+			responded_at = COALESCE(responded_at, CASE WHEN $1 <> 'none' THEN CURRENT_TIMESTAMP END)
+		WHERE game_id = $3 AND member_id = $4`, status, nullString(actor), gameID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM members WHERE id = $1 AND deleted_at IS NULL
+			AND team_id = (SELECT team_id FROM games WHERE id = $2))`, memberID, gameID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", errUnknownMember, memberID)
+		}
+		_, err := tx.Exec(`INSERT INTO game_participants (game_id, member_id, status, updated_at, updated_by, responded_at)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP, $4, CASE WHEN $3 <> 'none' THEN CURRENT_TIMESTAMP END)`, gameID, memberID, status, nullString(actor))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	game, err := loadGame(tx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func (s *sqlStore) SetGameResult(gameID string, result *GameResult, version int) (*Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	found, err := bumpGameVersion(tx, gameID, version)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM game_results WHERE game_id = $1`, gameID); err != nil {
+		return nil, err
+	}
+	if result != nil {
+		rounds, err := json.Marshal(result.Rounds)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`INSERT INTO game_results (game_id, our_score, their_score, outcome, forfeit, rounds, recorded_by, recorded_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			gameID, result.OurScore, result.TheirScore, result.Outcome, result.Forfeit, string(rounds), nullString(result.RecordedBy), result.RecordedAt.UTC())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	game, err := loadGame(tx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func (s *sqlStore) WithdrawFromRoster(gameID, memberID, actor string, version int) (*Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	found, err := bumpGameVersion(tx, gameID, version)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	// The withdrawal goes to the end of the withdrawals list
+	res, err := tx.Exec(`UPDATE game_participants SET role = 'withdrawn', status = 'unavailable',
+			position = (SELECT COALESCE(MAX(w.position), -1) + 1 FROM game_participants w
+				WHERE w.game_id = $1 AND w.role = 'withdrawn'),
+			updated_at = CURRENT_TIMESTAMP, updated_by = $2,
+			responded_at = COALESCE(responded_at, CURRENT_TIMESTAMP), withdrawn_at = CURRENT_TIMESTAMP
+		WHERE game_id = $1 AND member_id = $3 AND role = 'roster'`, gameID, nullString(actor), memberID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, errNotOnRoster
+	}
+
+	game, err := loadGame(tx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func (s *sqlStore) DeleteGame(id string, version int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	found, err := bumpGameVersion(tx, id, version)
+	if err != nil || !found {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE games SET deleted_at = $1 WHERE id = $2", time.Now().UTC(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) ListAttendance(gameIDs []string) ([]Attendance, error) {
+	attendance := []Attendance{}
+	if len(gameIDs) == 0 {
+		return attendance, nil
+	}
+
+	placeholders := make([]string, len(gameIDs))
+	args := make([]interface{}, len(gameIDs))
+	for i, id := range gameIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT g.id, m.id, g.starts_at, g.created_at, m.created_at,
+			COALESCE(p.status, 'none'), COALESCE(p.role, 'none'), p.responded_at, p.withdrawn_at
+		FROM games g
+		JOIN members m ON m.team_id = g.team_id AND m.deleted_at IS NULL
+		LEFT JOIN game_participants p ON p.game_id = g.id AND p.member_id = m.id
+		WHERE g.id IN (%s)
+		ORDER BY g.id, m.id`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attendance
+		var startsAt time.Time
+		var postedAt, joinedAt *time.Time
+		if err := rows.Scan(&a.GameID, &a.MemberID, &startsAt, &postedAt, &joinedAt, &a.Status, &a.Role, &a.RespondedAt, &a.WithdrawnAt); err != nil {
+			return nil, err
+		}
+		// A missing creation time counts as the game's start
+		a.PostedAt, a.JoinedAt = startsAt.UTC(), startsAt.UTC()
+		if postedAt != nil {
+			a.PostedAt = postedAt.UTC()
+		}
+		if joinedAt != nil {
+			a.JoinedAt = joinedAt.UTC()
+		}
+		for _, t := range []*time.Time{a.RespondedAt, a.WithdrawnAt} {
+			if t != nil {
+				*t = t.UTC()
+			}
+		}
+		attendance = append(attendance, a)
+	}
+	return attendance, rows.Err()
+}
+
+func (s *sqlStore) ListStatLines(gameIDs []string) ([]StatLine, error) {
+	lines := []StatLine{}
+	if len(gameIDs) == 0 {
+		return lines, nil
+	}
+
+	placeholders := make([]string, len(gameIDs))
+	args := make([]interface{}, len(gameIDs))
+	for i, id := range gameIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT game_id, member_id, stats, COALESCE(recorded_by, ''), recorded_at
+		FROM player_game_stats WHERE game_id IN (%s) ORDER BY game_id, member_id`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line StatLine
+		var stats []byte
+		if err := rows.Scan(&line.GameID, &line.MemberID, &stats, &line.RecordedBy, &line.RecordedAt); err != nil {
+			return nil, err
+		}
+		line.RecordedAt = line.RecordedAt.UTC()
+		if err := json.Unmarshal(stats, &line.Stats); err != nil {
+			return nil, fmt.Errorf("game %s member %s stats: %v", line.GameID, line.MemberID, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+func (s *sqlStore) SetStatLines(gameID string, lines []StatLine) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM player_game_stats WHERE game_id = $1`, gameID); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		stats, err := json.Marshal(line.Stats)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO player_game_stats (game_id, member_id, stats, recorded_by, recorded_at)
+			VALUES ($1, $2, $3, $4, $5)`, gameID, line.MemberID, string(stats), nullString(line.RecordedBy), line.RecordedAt.UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) ListPendingReminders(from, to time.Time) ([]Game, error) {
+	return queryGames(s.db, `SELECT `+gameColumns+` FROM games
+		WHERE starts_at > $1 AND starts_at <= $2 AND (reminded = false OR reminded IS NULL) AND deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM game_participants p WHERE p.game_id = games.id AND p.role = 'roster')
+		ORDER BY starts_at`, from.UTC(), to.UTC())
+}
+
+// ---------- Series ----------
+
+const seriesColumns = `id, team_id, COALESCE(season_id, ''), rrule, starts_at, time_zone, exceptions, COALESCE(created_by, ''), created_at`
+
+func scanSeries(row interface{ Scan(...interface{}) error }, series *Series) error {
+	var exceptions string
+	if err := row.Scan(&series.ID, &series.TeamID, &series.SeasonID, &series.RRule, &series.StartsAt, &series.TimeZone, &exceptions, &series.CreatedBy, &series.CreatedAt); err != nil {
+		return err
+	}
+	series.StartsAt = series.StartsAt.UTC()
+	series.CreatedAt = series.CreatedAt.UTC()
+	series.Exceptions = []string{}
+	return json.Unmarshal([]byte(exceptions), &series.Exceptions)
+}
+
+func (s *sqlStore) CreateSeries(series Series, games []Game) (*Series, []Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO game_series (id, team_id, season_id, rrule, starts_at, time_zone, exceptions, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		series.ID, series.TeamID, nullString(series.SeasonID), series.RRule, series.StartsAt.UTC(), series.TimeZone,
+		toJSONString(series.Exceptions), nullString(series.CreatedBy))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created, err := insertGames(tx, games)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stored Series
+	if err := scanSeries(tx.QueryRow(`SELECT `+seriesColumns+` FROM game_series WHERE id = $1`, series.ID), &stored); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return &stored, created, nil
+}
+
+func (s *sqlStore) GetSeries(id string) (*Series, error) {
+	var series Series
+	err := scanSeries(s.db.QueryRow(`SELECT `+seriesColumns+` FROM game_series WHERE id = $1`, id), &series)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (s *sqlStore) ListSeries(teamID string) ([]Series, error) {
+	rows, err := s.db.Query(`SELECT `+seriesColumns+` FROM game_series WHERE team_id = $1 ORDER BY created_at, id`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Series{}
+	for rows.Next() {
+		var series Series
+		if err := scanSeries(rows, &series); err != nil {
+			return nil, err
+		}
+		list = append(list, series)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) UpdateSeriesGames(series Series, changes []GameChange) ([]Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE game_series SET rrule = $1, starts_at = $2, time_zone = $3, exceptions = $4 WHERE id = $5`,
+		series.RRule, series.StartsAt.UTC(), series.TimeZone, toJSONString(series.Exceptions), series.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("series %s not found", series.ID)
+	}
+
+	games := []Game{}
+	for _, change := range changes {
+		found, err := updateGameRow(tx, change.ID, change.Patch, change.Version)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		if change.Delete {
+			if _, err := tx.Exec(`UPDATE games SET deleted_at = $1 WHERE id = $2`, time.Now().UTC(), change.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		g, err := loadGame(tx, change.ID)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, *g)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return games, nil
+}
+
+// ---------- Calendar feeds ----------
+
+const feedColumns = `token, team_id, kind, COALESCE(league_id, ''), COALESCE(member_id, ''), COALESCE(created_by, ''), created_at`
+
+func scanCalendarFeed(row interface{ Scan(...interface{}) error }, f *CalendarFeed) error {
+	if err := row.Scan(&f.Token, &f.TeamID, &f.Kind, &f.LeagueID, &f.MemberID, &f.CreatedBy, &f.CreatedAt); err != nil {
+		return err
+	}
+	f.CreatedAt = f.CreatedAt.UTC()
+	return nil
+}
+
+func (s *sqlStore) ListCalendarFeeds(teamID string) ([]CalendarFeed, error) {
+	rows, err := s.db.Query(`SELECT `+feedColumns+` FROM calendar_feeds WHERE team_id = $1 ORDER BY created_at, token`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []CalendarFeed{}
+	for rows.Next() {
+		var f CalendarFeed
+		if err := scanCalendarFeed(rows, &f); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
+func (s *sqlStore) GetCalendarFeed(token string) (*CalendarFeed, error) {
+	var f CalendarFeed
+	err := scanCalendarFeed(s.db.QueryRow(`SELECT `+feedColumns+` FROM calendar_feeds WHERE token = $1`, token), &f)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *sqlStore) CreateCalendarFeed(f CalendarFeed) (*CalendarFeed, error) {
+	_, err := s.db.Exec(`INSERT INTO calendar_feeds (token, team_id, kind, league_id, member_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		f.Token, f.TeamID, f.Kind, nullString(f.LeagueID), nullString(f.MemberID), nullString(f.CreatedBy))
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCalendarFeed(f.Token)
+}
+
+func (s *sqlStore) DeleteCalendarFeed(token string) error {
+	_, err := s.db.Exec(`DELETE FROM calendar_feeds WHERE token = $1`, token)
+	return err
+}
+
+// ---------- Members ----------
+
+const memberColumns = `id, COALESCE(team_id, ''), name, year, COALESCE(region, ''), COALESCE(note, ''), is_vet, deleted_at`
+
+func scanMember(row interface{ Scan(...interface{}) error }, m *Member) error {
+	return row.Scan(&m.ID, &m.TeamID, &m.Name, &m.Year, &m.Region, &m.Note, &m.IsVet, &m.DeletedAt)
+}
+
+func (s *sqlStore) queryMembers(query string, args ...interface{}) ([]Member, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []Member
+	for rows.Next() {
+		var m Member
+		if err := scanMember(rows, &m); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	return members, loadMemberAliases(s.db, members)
+}
+
+// loadMemberAliases fills in each member's former names: every alias
+// except the one matching their current name.
+func loadMemberAliases(q querier, members []Member) error {
+	if len(members) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(members))
+	placeholders := make([]string, len(members))
+	args := make([]interface{}, len(members))
+	for i, m := range members {
+		index[m.ID] = i
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = m.ID
+	}
+
+	rows, err := q.Query(`SELECT member_id, alias_key, alias FROM member_aliases
+		WHERE member_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY created_at, alias`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var memberID, key, alias string
+		if err := rows.Scan(&memberID, &key, &alias); err != nil {
+			return err
+		}
+		m := &members[index[memberID]]
+		if key != memberKey(m.Name) {
+			m.Aliases = append(m.Aliases, alias)
+		}
+	}
+	return rows.Err()
+}
+
+// memberAliasOwner returns the ID of the team's member who has gone by a
+// name with the given key, or "".
+func memberAliasOwner(q querier, teamID, key string) (string, error) {
+	var id string
+	err := q.QueryRow(`SELECT member_id FROM member_aliases WHERE team_id = $1 AND alias_key = $2`, teamID, key).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// addMemberAlias records name as one of the member's names, failing with
+// errDuplicateMember if another member of the team has gone by it.
+func addMemberAlias(q querier, teamID, memberID, name string) error {
+	key := memberKey(name)
+	owner, err := memberAliasOwner(q, teamID, key)
+	if err != nil {
+		return err
+	}
+	if owner == memberID {
+		return nil
+	}
+	if owner != "" {
+		return fmt.Errorf("%w: %s", errDuplicateMember, name)
+	}
+	_, err = q.Exec(`INSERT INTO member_aliases (team_id, alias_key, member_id, alias, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		teamID, key, memberID, strings.TrimSpace(name), time.Now().UTC())
+	return err
+}
+
+func (s *sqlStore) ListMembers(teamID string) ([]Member, error) {
+	return s.queryMembers(`SELECT `+memberColumns+` FROM members WHERE deleted_at IS NULL AND team_id = $1 ORDER BY is_vet, sort_order, name`, teamID)
+}
+
+func (s *sqlStore) GetMember(id string) (*Member, error) {
+	members, err := s.queryMembers(`SELECT `+memberColumns+` FROM members WHERE id = $1`, id)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	return &members[0], nil
+}
+
+func (s *sqlStore) FindMember(teamID, name string) (*Member, error) {
+	key := memberKey(name)
+	if key == "" {
+		return nil, nil
+	}
+	id, err := memberAliasOwner(s.db, teamID, key)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return s.GetMember(id)
+}
+
+func (s *sqlStore) CreateMember(m Member) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO members (id, team_id, name, year, region, note, is_vet, sort_order)
+		SELECT $1, $7, $2, $3, $4, $5, $6, COALESCE(MAX(sort_order), -1) + 1 FROM members WHERE is_vet = $6 AND team_id = $7
+		ON CONFLICT (id) DO NOTHING`,
+		m.ID, m.Name, m.Year, m.Region, m.Note, m.IsVet, m.TeamID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", errDuplicateMember, m.ID)
+	}
+	if err := addMemberAlias(tx, m.TeamID, m.ID, m.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) UpdateMember(id string, update MemberUpdate) error {
+	// Build update query dynamically
+	updates := []string{}
+	args := []interface{}{}
+	argNum := 1
+
+	if update.Name != nil {
+		updates = append(updates, fmt.Sprintf("name = $%d", argNum))
+		args = append(args, *update.Name)
+		argNum++
+	}
+	if update.IsVet != nil {
+		updates = append(updates, fmt.Sprintf("is_vet = $%d", argNum))
+		args = append(args, *update.IsVet)
+		argNum++
+	}
+	if update.Region != nil {
+		updates = append(updates, fmt.Sprintf("region = $%d", argNum))
+		args = append(args, *update.Region)
+		argNum++
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if update.Name != nil {
+		var teamID string
+		err := tx.QueryRow(`SELECT COALESCE(team_id, '') FROM members WHERE id = $1`, id).Scan(&teamID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := addMemberAlias(tx, teamID, id, *update.Name); err != nil {
+			return err
+		}
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE members SET %s WHERE id = $%d", strings.Join(updates, ", "), argNum)
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeleteMember(id string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	res, err := tx.Exec("UPDATE members SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL", now, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`SELECT p.game_id FROM game_participants p JOIN games g ON g.id = p.game_id
+		WHERE p.member_id = $1 AND g.starts_at > $2 AND g.deleted_at IS NULL
+		ORDER BY g.starts_at, g.id`, id, now)
+	if err != nil {
+		return nil, err
+	}
+	gameIDs := []string{}
+	for rows.Next() {
+		var gameID string
+		if err := rows.Scan(&gameID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		gameIDs = append(gameIDs, gameID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, gameID := range gameIDs {
+		if _, err := bumpGameVersion(tx, gameID, 0); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM game_participants WHERE game_id = $1 AND member_id = $2`, gameID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return gameIDs, nil
+}
+
+func (s *sqlStore) SetMemberOrder(ids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, id := range ids {
+		if _, err := tx.Exec("UPDATE members SET sort_order = $1 WHERE id = $2", i, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ---------- Seasons ----------
+
+const seasonColumns = `id, COALESCE(team_id, ''), name, starts_at, ends_at, active, archived_at`
+
+func scanSeason(row interface{ Scan(...interface{}) error }, season *Season) error {
+	if err := row.Scan(&season.ID, &season.TeamID, &season.Name, &season.StartsAt, &season.EndsAt, &season.Active, &season.ArchivedAt); err != nil {
+		return err
+	}
+	season.StartsAt = season.StartsAt.UTC()
+	return nil
+}
+
+func loadSeason(q querier, query string, args ...interface{}) (*Season, error) {
+	var season Season
+	err := scanSeason(q.QueryRow(query, args...), &season)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+func (s *sqlStore) ListSeasons(teamID string) ([]Season, error) {
+	where, args := teamSeasonFilter(nil, teamID, "")
+	rows, err := s.db.Query(`SELECT `+seasonColumns+` FROM seasons WHERE 1 = 1`+where+` ORDER BY starts_at DESC, created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seasons := []Season{}
+	for rows.Next() {
+		var season Season
+		if err := scanSeason(rows, &season); err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, season)
+	}
+	return seasons, rows.Err()
+}
+
+func (s *sqlStore) GetSeason(id string) (*Season, error) {
+	return loadSeason(s.db, `SELECT `+seasonColumns+` FROM seasons WHERE id = $1`, id)
+}
+
+func (s *sqlStore) GetActiveSeason(teamID string) (*Season, error) {
+	return loadSeason(s.db, `SELECT `+seasonColumns+` FROM seasons WHERE active AND team_id = $1`, teamID)
+}
+
+func (s *sqlStore) StartSeason(season Season) (*Season, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE seasons SET active = false, archived_at = $1, ends_at = COALESCE(ends_at, $2) WHERE active AND team_id = $3`,
+		time.Now().UTC(), season.StartsAt.UTC(), season.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var endsAt interface{}
+	if season.EndsAt != nil {
+		endsAt = season.EndsAt.UTC()
+	}
+	_, err = tx.Exec(`INSERT INTO seasons (id, team_id, name, starts_at, ends_at, active) VALUES ($1, $2, $3, $4, $5, true)`,
+		season.ID, season.TeamID, season.Name, season.StartsAt.UTC(), endsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	started, err := loadSeason(tx, `SELECT `+seasonColumns+` FROM seasons WHERE id = $1`, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return started, nil
+}
+
+func (s *sqlStore) UpdateSeason(id string, update SeasonUpdate) (*Season, error) {
+	updates := []string{}
+	args := []interface{}{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		updates = append(updates, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if update.Name != nil {
+		set("name", *update.Name)
+	}
+	if update.StartsAt != nil {
+		set("starts_at", update.StartsAt.UTC())
+	}
+	if update.EndsAt != nil {
+		set("ends_at", update.EndsAt.UTC())
+	}
+
+	if len(updates) > 0 {
+		args = append(args, id)
+		query := fmt.Sprintf("UPDATE seasons SET %s WHERE id = $%d", strings.Join(updates, ", "), len(args))
+		if _, err := s.db.Exec(query, args...); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetSeason(id)
+}
+
+// ---------- Leagues & divisions ----------
+
+const leagueColumns = `id, season_id, name, short_code, color, default_game_mode, default_team_size`
+
+const divisionColumns = `id, season_id, COALESCE(league_id, ''), name, short_code, color, default_game_mode, default_team_size`
+
+func scanLeague(row interface{ Scan(...interface{}) error }, l *League) error {
+	return row.Scan(&l.ID, &l.SeasonID, &l.Name, &l.ShortCode, &l.Color, &l.DefaultGameMode, &l.DefaultTeamSize)
+}
+
+func scanDivision(row interface{ Scan(...interface{}) error }, d *Division) error {
+	return row.Scan(&d.ID, &d.SeasonID, &d.LeagueID, &d.Name, &d.ShortCode, &d.Color, &d.DefaultGameMode, &d.DefaultTeamSize)
+}
+
+// nameTaken reports whether another row of table in the same season as id
+// already uses name.
+func nameTaken(q querier, table, id, name string) (bool, error) {
+	var taken bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE name = $2 AND id <> $1
+		AND season_id = (SELECT season_id FROM `+table+` WHERE id = $1))`, id, name).Scan(&taken)
+	return taken, err
+}
+
+// moveGames points every game using a league or division (column is
+// league_id or division_id) at replaceWith, bumping their versions, and
+// returns the IDs of the live games it moved. With no replacement it fails
+// with errInUse if any game, trashed ones included, still uses it.
+func moveGames(q querier, column, id, replaceWith string) ([]string, error) {
+	if replaceWith == "" {
+		var used bool
+		if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM games WHERE `+column+` = $1)`, id).Scan(&used); err != nil {
+			return nil, err
+		}
+		if used {
+			return nil, errInUse
+		}
+		return nil, nil
+	}
+
+	rows, err := q.Query(`SELECT id FROM games WHERE `+column+` = $1 AND deleted_at IS NULL ORDER BY starts_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	moved := []string{}
+	for rows.Next() {
+		var gameID string
+		if err := rows.Scan(&gameID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		moved = append(moved, gameID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := q.Exec(`UPDATE games SET `+column+` = $2, version = version + 1 WHERE `+column+` = $1`, id, replaceWith); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// seasonTableFilter is teamSeasonFilter for tables that belong to a team
+// through their season_id.
+func seasonTableFilter(teamID, seasonID string) (string, []interface{}) {
+	where, args := teamSeasonFilter(nil, "", seasonID)
+	if teamID != "" {
+		args = append(args, teamID)
+		where += fmt.Sprintf(" AND season_id IN (SELECT id FROM seasons WHERE team_id = $%d)", len(args))
+	}
+	return where, args
+}
+
+func (s *sqlStore) ListLeagues(teamID, seasonID string) ([]League, error) {
+	where, args := seasonTableFilter(teamID, seasonID)
+	rows, err := s.db.Query(`SELECT `+leagueColumns+` FROM leagues WHERE 1 = 1`+where+` ORDER BY name, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leagues := []League{}
+	for rows.Next() {
+		var l League
+		if err := scanLeague(rows, &l); err != nil {
+			return nil, err
+		}
+		leagues = append(leagues, l)
+	}
+	return leagues, rows.Err()
+}
+
+func (s *sqlStore) GetLeague(id string) (*League, error) {
+	var l League
+	err := scanLeague(s.db.QueryRow(`SELECT `+leagueColumns+` FROM leagues WHERE id = $1`, id), &l)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (s *sqlStore) CreateLeague(l League) (*League, error) {
+	res, err := s.db.Exec(`INSERT INTO leagues (id, season_id, name, short_code, color, default_game_mode, default_team_size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`,
+		l.ID, l.SeasonID, l.Name, l.ShortCode, l.Color, l.DefaultGameMode, l.DefaultTeamSize)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, l.Name)
+	}
+	return s.GetLeague(l.ID)
+}
+
+func (s *sqlStore) UpdateLeague(l League) (*League, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRow(`SELECT name FROM leagues WHERE id = $1`, l.ID).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if taken, err := nameTaken(tx, "leagues", l.ID, l.Name); err != nil || taken {
+		if err == nil {
+			err = fmt.Errorf("%w: %s", errDuplicateName, l.Name)
+		}
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE leagues SET name = $2, short_code = $3, color = $4, default_game_mode = $5, default_team_size = $6
+		WHERE id = $1`, l.ID, l.Name, l.ShortCode, l.Color, l.DefaultGameMode, l.DefaultTeamSize)
+	if err != nil {
+		return nil, err
+	}
+	// Games show the league's name, so a rename is a change to each of them
+	if l.Name != oldName {
+		if _, err := tx.Exec(`UPDATE games SET version = version + 1 WHERE league_id = $1`, l.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetLeague(l.ID)
+}
+
+func (s *sqlStore) DeleteLeague(id, replaceWith string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	moved, err := moveGames(tx, "league_id", id, replaceWith)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE divisions SET league_id = $2 WHERE league_id = $1`, id, nullString(replaceWith)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM leagues WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func (s *sqlStore) ListDivisions(teamID, seasonID string) ([]Division, error) {
+	where, args := seasonTableFilter(teamID, seasonID)
+	rows, err := s.db.Query(`SELECT `+divisionColumns+` FROM divisions WHERE 1 = 1`+where+` ORDER BY name, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	divisions := []Division{}
+	for rows.Next() {
+		var d Division
+		if err := scanDivision(rows, &d); err != nil {
+			return nil, err
+		}
+		divisions = append(divisions, d)
+	}
+	return divisions, rows.Err()
+}
+
+func (s *sqlStore) GetDivision(id string) (*Division, error) {
+	var d Division
+	err := scanDivision(s.db.QueryRow(`SELECT `+divisionColumns+` FROM divisions WHERE id = $1`, id), &d)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *sqlStore) CreateDivision(d Division) (*Division, error) {
+	res, err := s.db.Exec(`INSERT INTO divisions (id, season_id, league_id, name, short_code, color, default_game_mode, default_team_size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING`,
+		d.ID, d.SeasonID, nullString(d.LeagueID), d.Name, d.ShortCode, d.Color, d.DefaultGameMode, d.DefaultTeamSize)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: %s", errDuplicateName, d.Name)
+	}
+	return s.GetDivision(d.ID)
+}
+
+func (s *sqlStore) UpdateDivision(d Division) (*Division, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRow(`SELECT name FROM divisions WHERE id = $1`, d.ID).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if taken, err := nameTaken(tx, "divisions", d.ID, d.Name); err != nil || taken {
+		if err == nil {
+			err = fmt.Errorf("%w: %s", errDuplicateName, d.Name)
+		}
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE divisions SET league_id = $2, name = $3, short_code = $4, color = $5, default_game_mode = $6,
+		default_team_size = $7 WHERE id = $1`,
+		d.ID, nullString(d.LeagueID), d.Name, d.ShortCode, d.Color, d.DefaultGameMode, d.DefaultTeamSize)
+	if err != nil {
+		return nil, err
+	}
+	if d.Name != oldName {
+		if _, err := tx.Exec(`UPDATE games SET version = version + 1 WHERE division_id = $1`, d.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetDivision(d.ID)
+}
+
+func (s *sqlStore) DeleteDivision(id, replaceWith string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	moved, err := moveGames(tx, "division_id", id, replaceWith)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM divisions WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// ---------- Opponents ----------
+
+const opponentColumns = `id, name, tag, region, logo, discord, notes`
+
+// queryOpponents runs a SELECT over opponents (which must select
+// opponentColumns) and fills in each one's aliases.
+func queryOpponents(q querier, query string, args ...interface{}) ([]Opponent, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	opponents := []Opponent{}
+	index := make(map[string]int)
+	for rows.Next() {
+		o := Opponent{Aliases: []string{}}
+		if err := rows.Scan(&o.ID, &o.Name, &o.Tag, &o.Region, &o.Logo, &o.Discord, &o.Notes); err != nil {
+			return nil, err
+		}
+		index[o.ID] = len(opponents)
+		opponents = append(opponents, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if len(opponents) == 0 {
+		return opponents, nil
+	}
+
+	aliasRows, err := q.Query(`SELECT opponent_id, alias FROM opponent_aliases ORDER BY alias`)
+	if err != nil {
+		return nil, err
+	}
+	defer aliasRows.Close()
+	for aliasRows.Next() {
+		var opponentID, alias string
+		if err := aliasRows.Scan(&opponentID, &alias); err != nil {
+			return nil, err
+		}
+		if i, ok := index[opponentID]; ok {
+			opponents[i].Aliases = append(opponents[i].Aliases, alias)
+		}
+	}
+	return opponents, aliasRows.Err()
+}
+
+func loadOpponent(q querier, id string) (*Opponent, error) {
+	opponents, err := queryOpponents(q, `SELECT `+opponentColumns+` FROM opponents WHERE id = $1`, id)
+	if err != nil || len(opponents) == 0 {
+		return nil, err
+	}
+	return &opponents[0], nil
+}
+
+// findOpponentID returns the ID of the opponent whose name or alias has the
+// given key, or "".
+func findOpponentID(q querier, key string) (string, error) {
+	var id string
+	err := q.QueryRow(`SELECT id FROM opponents WHERE name_key = $1
+		UNION SELECT opponent_id FROM opponent_aliases WHERE alias_key = $1`, key).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// checkOpponentKeys fails with errDuplicateName if the opponent's name or one
+// of its aliases belongs to another opponent.
+func checkOpponentKeys(q querier, o *Opponent) error {
+	for _, key := range opponentKeys(o) {
+		owner, err := findOpponentID(q, key)
+		if err != nil {
+			return err
+		}
+		if owner != "" && owner != o.ID {
+			return fmt.Errorf("%w: %s", errDuplicateName, key)
+		}
+	}
+	return nil
+}
+
+func replaceOpponentAliases(q querier, o *Opponent) error {
+	if _, err := q.Exec(`DELETE FROM opponent_aliases WHERE opponent_id = $1`, o.ID); err != nil {
+		return err
+	}
+	for _, alias := range o.Aliases {
+		if _, err := q.Exec(`INSERT INTO opponent_aliases (alias_key, opponent_id, alias) VALUES ($1, $2, $3)`,
+			opponentKey(alias), o.ID, alias); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) ListOpponents() ([]Opponent, error) {
+	return queryOpponents(s.db, `SELECT `+opponentColumns+` FROM opponents ORDER BY name, id`)
+}
+
+func (s *sqlStore) GetOpponent(id string) (*Opponent, error) {
+	return loadOpponent(s.db, id)
+}
+
+func (s *sqlStore) FindOpponent(name string) (*Opponent, error) {
+	key := opponentKey(name)
+	if key == "" {
+		return nil, nil
+	}
+	id, err := findOpponentID(s.db, key)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return loadOpponent(s.db, id)
+}
+
+func (s *sqlStore) CreateOpponent(o Opponent) (*Opponent, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := insertOpponent(tx, &o)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// insertOpponent inserts o, unless its name or an alias is taken, and
+// loads it back as stored.
+func insertOpponent(q querier, o *Opponent) (*Opponent, error) {
+	if err := checkOpponentKeys(q, o); err != nil {
+		return nil, err
+	}
+	_, err := q.Exec(`INSERT INTO opponents (id, name, name_key, tag, region, logo, discord, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		o.ID, o.Name, opponentKey(o.Name), o.Tag, o.Region, o.Logo, o.Discord, o.Notes)
+	if err != nil {
+		return nil, err
+	}
+	if err := replaceOpponentAliases(q, o); err != nil {
+		return nil, err
+	}
+	return loadOpponent(q, o.ID)
+}
+
+func (s *sqlStore) UpdateOpponent(o Opponent) (*Opponent, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadOpponent(tx, o.ID)
+	if err != nil || before == nil {
+		return nil, err
+	}
+	if err := checkOpponentKeys(tx, &o); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`UPDATE opponents SET name = $2, name_key = $3, tag = $4, region = $5, logo = $6, discord = $7, notes = $8
+		WHERE id = $1`, o.ID, o.Name, opponentKey(o.Name), o.Tag, o.Region, o.Logo, o.Discord, o.Notes)
+	if err != nil {
+		return nil, err
+	}
+	if err := replaceOpponentAliases(tx, &o); err != nil {
+		return nil, err
+	}
+	if o.Name != before.Name {
+		if _, err := tx.Exec(`UPDATE games SET opponent = $2, version = version + 1 WHERE opponent_id = $1`, o.ID, o.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := loadOpponent(tx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *sqlStore) ListOpponentGames(teamID, opponentID string) ([]Game, error) {
+	return queryGames(s.db, `SELECT `+gameColumns+` FROM games WHERE opponent_id = $1 AND team_id = $2 AND deleted_at IS NULL ORDER BY starts_at`, opponentID, teamID)
+}
+
+// ---------- Consistency ----------
+
+func queryDanglingParticipants(q querier, teamID string, now time.Time) ([]DanglingParticipant, error) {
+	rows, err := q.Query(`SELECT p.game_id, g.starts_at, p.member_id, p.role, p.status,
+			CASE WHEN m.id IS NULL THEN 'missing' ELSE 'deleted' END
+		FROM game_participants p
+		JOIN games g ON g.id = p.game_id
+		LEFT JOIN members m ON m.id = p.member_id
+		WHERE g.team_id = $1 AND g.deleted_at IS NULL
+		AND (m.id IS NULL OR (m.deleted_at IS NOT NULL AND g.starts_at > $2))
+		ORDER BY g.starts_at, p.game_id, p.member_id`, teamID, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dangling := []DanglingParticipant{}
+	for rows.Next() {
+		var d DanglingParticipant
+		if err := rows.Scan(&d.GameID, &d.StartsAt, &d.MemberID, &d.Role, &d.Status, &d.Reason); err != nil {
+			return nil, err
+		}
+		d.StartsAt = d.StartsAt.UTC()
+		dangling = append(dangling, d)
+	}
+	return dangling, rows.Err()
+}
+
+func (s *sqlStore) ListDanglingParticipants(teamID string, now time.Time) ([]DanglingParticipant, error) {
+	return queryDanglingParticipants(s.db, teamID, now)
+}
+
+func (s *sqlStore) RemoveDanglingParticipants(teamID string, now time.Time) ([]DanglingParticipant, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	dangling, err := queryDanglingParticipants(tx, teamID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	bumped := make(map[string]bool)
+	for _, d := range dangling {
+		if !bumped[d.GameID] {
+			if _, err := bumpGameVersion(tx, d.GameID, 0); err != nil {
+				return nil, err
+			}
+			bumped[d.GameID] = true
+		}
+		if _, err := tx.Exec(`DELETE FROM game_participants WHERE game_id = $1 AND member_id = $2`, d.GameID, d.MemberID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return dangling, nil
+}
+
+// ---------- Trash ----------
+
+func (s *sqlStore) ListDeletedGames(teamID string) ([]Game, error) {
+	return queryGames(s.db, `SELECT `+gameColumns+` FROM games WHERE deleted_at IS NOT NULL AND team_id = $1 ORDER BY deleted_at DESC`, teamID)
+}
+
+func (s *sqlStore) RestoreGame(id string) (*Game, error) {
+	res, err := s.db.Exec("UPDATE games SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return s.GetGame(id)
+}
+
+func (s *sqlStore) ListDeletedMembers(teamID string) ([]Member, error) {
+	return s.queryMembers(`SELECT `+memberColumns+` FROM members WHERE deleted_at IS NOT NULL AND team_id = $1 ORDER BY deleted_at DESC`, teamID)
+}
+
+func (s *sqlStore) RestoreMember(id string) (*Member, error) {
+	res, err := s.db.Exec("UPDATE members SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return s.GetMember(id)
+}
+
+func (s *sqlStore) PurgeDeleted(cutoff time.Time) (int, int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Games go first so their participant rows no longer hold on to members
+	res, err := tx.Exec("DELETE FROM games WHERE deleted_at IS NOT NULL AND deleted_at < $1", cutoff.UTC())
+	if err != nil {
+		return 0, 0, err
+	}
+	games, _ := res.RowsAffected()
+
+	res, err = tx.Exec(`DELETE FROM members WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM game_participants p WHERE p.member_id = members.id)
+		AND NOT EXISTS (SELECT 1 FROM player_game_stats ps WHERE ps.member_id = members.id)`, cutoff.UTC())
+	if err != nil {
+		return 0, 0, err
+	}
+	members, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return int(games), int(members), nil
+}
+
+// ---------- Users ----------
+
+// userColumns reads the player and manager flag from team_users, which
+// queries selecting it join as t.
+const userColumns = `users.discord_id, username, COALESCE(display_name, ''), COALESCE(avatar, ''), COALESCE(t.player_id, ''),
+	COALESCE(t.is_manager, false), COALESCE(email, ''), COALESCE(phone, ''), COALESCE(time_zone, '')`
+
+func scanUser(row interface{ Scan(...interface{}) error }, u *User) error {
+	return row.Scan(&u.DiscordID, &u.Username, &u.DisplayName, &u.Avatar, &u.PlayerID, &u.IsManager, &u.Email, &u.Phone, &u.TimeZone)
+}
+
+func (s *sqlStore) queryUser(query string, args ...interface{}) (*User, error) {
+	var u User
+	err := scanUser(s.db.QueryRow(query, args...), &u)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *sqlStore) queryUsers(query string, args ...interface{}) ([]User, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlStore) GetUser(teamID, discordID string) (*User, error) {
+	return s.queryUser(`SELECT `+userColumns+` FROM users
+		LEFT JOIN team_users t ON t.discord_id = users.discord_id AND t.team_id = $1
+		WHERE users.discord_id = $2`, teamID, discordID)
+}
+
+func (s *sqlStore) GetUserByPlayerID(playerID string) (*User, error) {
+	return s.queryUser(`SELECT `+userColumns+` FROM users
+		JOIN team_users t ON t.discord_id = users.discord_id
+		WHERE t.player_id = $1`, playerID)
+}
+
+func (s *sqlStore) SaveDiscordUser(u User) error {
+	_, err := s.db.Exec(`
+		INSERT INTO users (discord_id, username, display_name, avatar)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (discord_id) DO UPDATE SET
+			username = $2,
+			display_name = $3,
+			avatar = $4
+	`, u.DiscordID, u.Username, u.DisplayName, u.Avatar)
+	return err
+}
+
+func (s *sqlStore) SaveTeamUser(teamID, discordID string, isManager bool) error {
+	_, err := s.db.Exec(`
+		INSERT INTO team_users (team_id, discord_id, is_manager)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (team_id, discord_id) DO UPDATE SET is_manager = $3
+	`, teamID, discordID, isManager)
+	return err
+}
+
+func (s *sqlStore) ListUserTeams(discordID string) ([]TeamUser, error) {
+	rows, err := s.db.Query(`SELECT t.team_id, COALESCE(t.player_id, ''), t.is_manager
+		FROM team_users t JOIN teams ON teams.id = t.team_id
+		WHERE t.discord_id = $1 ORDER BY teams.created_at, teams.id`, discordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []TeamUser{}
+	for rows.Next() {
+		var tu TeamUser
+		if err := rows.Scan(&tu.TeamID, &tu.PlayerID, &tu.IsManager); err != nil {
+			return nil, err
+		}
+		teams = append(teams, tu)
+	}
+	return teams, rows.Err()
+}
+
+func (s *sqlStore) LinkPlayer(teamID, discordID, playerID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO team_users (team_id, discord_id, player_id, is_manager)
+		VALUES ($1, $2, $3, false)
+		ON CONFLICT (team_id, discord_id) DO UPDATE SET player_id = $3
+	`, teamID, discordID, playerID)
+	return err
+}
+
+func (s *sqlStore) UpdateUserContact(discordID, email, phone string) error {
+	_, err := s.db.Exec(`UPDATE users SET email = $1, phone = $2 WHERE discord_id = $3`, email, phone, discordID)
+	return err
+}
+
+func (s *sqlStore) UpdateUserTimeZone(discordID, timeZone string) error {
+	_, err := s.db.Exec(`UPDATE users SET time_zone = $1 WHERE discord_id = $2`, timeZone, discordID)
+	return err
+}
+
+func (s *sqlStore) ListLinkedUsers(teamID string) ([]User, error) {
+	return s.queryUsers(`SELECT `+userColumns+` FROM users
+		JOIN team_users t ON t.discord_id = users.discord_id
+		WHERE t.team_id = $1 AND t.player_id IS NOT NULL AND t.player_id != ''`, teamID)
+}
+
+func (s *sqlStore) ListManagers(teamID string) ([]User, error) {
+	return s.queryUsers(`SELECT `+userColumns+` FROM users
+		JOIN team_users t ON t.discord_id = users.discord_id
+		WHERE t.team_id = $1 AND t.is_manager = true`, teamID)
+}
+
+// ---------- Preferences & settings ----------
+
+func (s *sqlStore) GetPreferences(teamID string) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT p.player_id, p.preference FROM player_preferences p
+		JOIN members m ON m.id = p.player_id WHERE m.team_id = $1`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := make(map[string]string)
+	for rows.Next() {
+		var playerID, pref string
+		if err := rows.Scan(&playerID, &pref); err != nil {
+			return nil, err
+		}
+		prefs[playerID] = pref
+	}
+	return prefs, rows.Err()
+}
+
+func (s *sqlStore) SetPreference(playerID, preference string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO player_preferences (player_id, preference)
+		VALUES ($1, $2)
+		ON CONFLICT (player_id) DO UPDATE SET preference = $2
+	`, playerID, preference)
+	return err
+}
+
+func (s *sqlStore) GetSetting(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow("SELECT COALESCE(value, '') FROM settings WHERE key = $1", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *sqlStore) SetSetting(key, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO settings (key, value)
+		VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET value = $2
+	`, key, value)
+	return err
+}
+
+// ---------- Audit log ----------
+
+func (s *sqlStore) RecordAudit(e AuditEvent) error {
+	var before, after interface{}
+	if e.Before != nil {
+		before = string(e.Before)
+	}
+	if e.After != nil {
+		after = string(e.After)
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO audit_events (team_id, occurred_at, actor, action, entity_type, entity_id, before_data, after_data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, nullString(e.TeamID), e.At.UTC(), e.Actor, e.Action, e.EntityType, e.EntityID, before, after)
+	return err
+}
+
+func (s *sqlStore) ListAuditEvents(f AuditFilter) ([]AuditEvent, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if f.TeamID != "" {
+		add("team_id = $%d", f.TeamID)
+	}
+	if f.EntityType != "" {
+		add("entity_type = $%d", f.EntityType)
+	}
+	if f.EntityID != "" {
+		add("entity_id = $%d", f.EntityID)
+	}
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if !f.Since.IsZero() {
+		add("occurred_at >= $%d", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		add("occurred_at < $%d", f.Until.UTC())
+	}
+
+	query := `SELECT id, COALESCE(team_id, ''), occurred_at, actor, action, entity_type, entity_id, before_data, after_data FROM audit_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY occurred_at DESC, id DESC"
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.TeamID, &e.At, &e.Actor, &e.Action, &e.EntityType, &e.EntityID, &before, &after); err != nil {
+			return nil, err
+		}
+		e.At = e.At.UTC()
+		if before != nil {
+			e.Before = json.RawMessage(before)
+		}
+		if after != nil {
+			e.After = json.RawMessage(after)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ==================== SQLITE ====================
+
+var sqliteDialect = &sqlDialect{
+	name:          backendSQLite,
+	rebind:        rebindNumbered,
+	timestampType: "TIMESTAMP",
+	// The database file belongs to a single server process and every
+	// migration takes the write lock for its own transaction, so there is
+	// nothing extra to hold.
+	lockMigrations: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+		return func() {}, nil
+	},
+	tableExists: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = $1)`,
+	migrations:  withSharedMigrations(sqliteMigrations),
+}
+
+var postgresPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// rebindNumbered turns $N placeholders into SQLite's ?N, which binds by
+// number the same way, so a placeholder can be repeated or used out of order.
+func rebindNumbered(query string) string {
+	return postgresPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+// sqlitePath is the database file, SQLITE_PATH or go-calendar.db in the
+// working directory.
+func sqlitePath() string {
+	if path := os.Getenv("SQLITE_PATH"); path != "" {
+		return path
+	}
+	return "go-calendar.db"
+}
+
+// openSQLite opens (creating if needed) the database file at path. Foreign
+// keys are off by default in SQLite, and transactions start IMMEDIATE so a
+// read-then-write transaction waits for the write lock instead of failing
+// halfway through.
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	return db, nil
+}
+
+// sqliteMigrations is the schema history for SQLite databases. They are
+// always created fresh, so the history starts at the schema Postgres reached
+// in migration 9, without the legacy JSON list columns; the rest is in
+// sharedMigrations.
+var sqliteMigrations = []migration{
+	{
+		Version: 9,
+		Name:    "create_schema",
+		Up: `
+			CREATE TABLE games (
+				id TEXT PRIMARY KEY,
+				starts_at TIMESTAMP NOT NULL,
+				time_zone TEXT NOT NULL DEFAULT 'America/New_York',
+				opponent TEXT NOT NULL,
+				notes TEXT DEFAULT '',
+				league TEXT DEFAULT '',
+				division TEXT DEFAULT '',
+				game_mode TEXT DEFAULT 'War',
+				team_size INTEGER DEFAULT 10,
+				reminded BOOLEAN DEFAULT FALSE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX games_starts_at_idx ON games (starts_at);
+			CREATE TABLE player_preferences (
+				player_id TEXT PRIMARY KEY,
+				preference TEXT DEFAULT 'starter'
+			);
+			CREATE TABLE settings (
+				key TEXT PRIMARY KEY,
+				value TEXT
+			);
+			CREATE TABLE users (
+				discord_id TEXT PRIMARY KEY,
+				username TEXT NOT NULL,
+				display_name TEXT,
+				avatar TEXT,
+				player_id TEXT,
+				is_manager BOOLEAN DEFAULT FALSE,
+				email TEXT DEFAULT '',
+				phone TEXT DEFAULT '',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE members (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				year INTEGER DEFAULT 2025,
+				region TEXT,
+				note TEXT,
+				is_vet BOOLEAN DEFAULT FALSE,
+				sort_order INTEGER DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE game_participants (
+				game_id TEXT NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+				member_id TEXT NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+				status TEXT NOT NULL DEFAULT 'none' CHECK (status IN ('available', 'unavailable', 'none')),
+				role TEXT NOT NULL DEFAULT 'none' CHECK (role IN ('roster', 'sub', 'withdrawn', 'none')),
+				position INTEGER NOT NULL DEFAULT 0,
+				updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_by TEXT,
+				PRIMARY KEY (game_id, member_id)
+			);
+			CREATE INDEX game_participants_member_idx ON game_participants (member_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS game_participants;
+			DROP TABLE IF EXISTS members;
+			DROP TABLE IF EXISTS users;
+			DROP TABLE IF EXISTS settings;
+			DROP TABLE IF EXISTS player_preferences;
+			DROP TABLE IF EXISTS games;
+		`,
+	},
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// contractStores returns every backend the store contract runs against:
+// memory, a fresh SQLite file and, when TEST_DATABASE_URL names a
+// throwaway database, Postgres. The migration test rolls that database all
+// the way back, so never point it at one holding data.
+func contractStores(t *testing.T) map[string]Store {
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "contract.db"))
+	sqlite, err := openSQLStore(backendSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.db.Close() })
+	stores := map[string]Store{"memory": newMemoryStore(), "sqlite": sqlite}
+
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		t.Setenv("DATABASE_URL", url)
+		postgres, err := openSQLStore(backendPostgres)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { postgres.db.Close() })
+		stores["postgres"] = postgres
+	}
+	return stores
+}
+
+// contractTeam creates a team of its own with members alice and bob, so
+// runs against a shared database don't see each other's games.
+func contractTeam(t *testing.T, s Store) (teamID string, members []string) {
+	team, err := s.CreateTeam(Team{ID: generateID("team"), Slug: generateID("contract"), Name: "Contract"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		id := generateID(name)
+		if err := s.CreateMember(Member{ID: id, TeamID: team.ID, Name: name}); err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, id)
+	}
+	return team.ID, members
+}
+
+// contractGame saves a game starting at start for team.
+func contractGame(t *testing.T, s Store, teamID string, start time.Time) *Game {
+	g, err := s.CreateGame(Game{
+		ID:       generateGameID(),
+		TeamID:   teamID,
+		StartsAt: start,
+		TimeZone: "America/New_York",
+		Opponent: "Contract FC",
+		GameMode: "War",
+		TeamSize: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestStoreMigrationsRoundTrip(t *testing.T) {
+	for name, s := range contractStores(t) {
+		sql, ok := s.(*sqlStore)
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			all := len(sql.db.dialect.migrations)
+			if count, err := migrateDown(sql.db, all); err != nil || count != all {
+				t.Fatalf("down: reverted %d of %d: %v", count, all, err)
+			}
+			if count, err := migrateUp(sql.db); err != nil || count != all {
+				t.Fatalf("up: applied %d of %d: %v", count, all, err)
+			}
+
+			teamID, _ := contractTeam(t, s)
+			g := contractGame(t, s, teamID, time.Now().Add(time.Hour).Truncate(time.Second))
+			if got, err := s.GetGame(g.ID); err != nil || got == nil {
+				t.Fatalf("game after migrating: %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestStoreVersionConflict(t *testing.T) {
+	for name, s := range contractStores(t) {
+		t.Run(name, func(t *testing.T) {
+			teamID, _ := contractTeam(t, s)
+			g := contractGame(t, s, teamID, time.Now().Add(time.Hour).Truncate(time.Second))
+
+			notes := "first"
+			updated, err := s.UpdateGame(g.ID, GamePatch{Notes: &notes}, "tester", g.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Version <= g.Version {
+				t.Fatalf("version %d after update, was %d", updated.Version, g.Version)
+			}
+
+			stale := "second"
+			if _, err := s.UpdateGame(g.ID, GamePatch{Notes: &stale}, "tester", g.Version); !errors.Is(err, errVersionConflict) {
+				t.Fatalf("stale update: got %v, want errVersionConflict", err)
+			}
+			if got, _ := s.GetGame(g.ID); got.Notes != "first" || got.Version != updated.Version {
+				t.Fatalf("stale update was written: %+v", got)
+			}
+		})
+	}
+}
+
+func TestStoreConcurrentAvailability(t *testing.T) {
+	for name, s := range contractStores(t) {
+		t.Run(name, func(t *testing.T) {
+			teamID, members := contractTeam(t, s)
+			g := contractGame(t, s, teamID, time.Now().Add(time.Hour).Truncate(time.Second))
+
+			// Both members answer over and over at the same time, each
+			// ending on a different answer
+			final := map[string]string{members[0]: statusAvailable, members[1]: statusUnavailable}
+			var wg sync.WaitGroup
+			errs := make(chan error, len(members))
+			for _, member := range members {
+				wg.Add(1)
+				go func(member string) {
+					defer wg.Done()
+					for i := 0; i < 10; i++ {
+						status := statusAvailable
+						if i%2 == 1 {
+							status = statusUnavailable
+						}
+						if i == 9 {
+							status = final[member]
+						}
+						if _, err := s.SetAvailability(g.ID, member, status, member, 0); err != nil {
+							errs <- fmt.Errorf("%s: %v", member, err)
+							return
+						}
+					}
+				}(member)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatal(err)
+			}
+
+			got, err := s.GetGame(g.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Available) != 1 || got.Available[0] != members[0] ||
+				len(got.Unavailable) != 1 || got.Unavailable[0] != members[1] {
+				t.Fatalf("available %v, unavailable %v", got.Available, got.Unavailable)
+			}
+			if got.Version != g.Version+20 {
+				t.Fatalf("version %d after 20 answers to version %d", got.Version, g.Version)
+			}
+		})
+	}
+}
+
+func TestStoreSoftDelete(t *testing.T) {
+	for name, s := range contractStores(t) {
+		t.Run(name, func(t *testing.T) {
+			teamID, _ := contractTeam(t, s)
+			g := contractGame(t, s, teamID, time.Now().Add(time.Hour).Truncate(time.Second))
+
+			if err := s.DeleteGame(g.ID, g.Version); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := s.GetGame(g.ID); err != nil || got != nil {
+				t.Fatalf("deleted game still found: %v, %v", got, err)
+			}
+			deleted, err := s.ListDeletedGames(teamID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deleted) != 1 || deleted[0].ID != g.ID || deleted[0].DeletedAt == nil {
+				t.Fatalf("trash: %+v", deleted)
+			}
+
+			restored, err := s.RestoreGame(g.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored == nil || restored.DeletedAt != nil {
+				t.Fatalf("restored: %+v", restored)
+			}
+			if got, _ := s.GetGame(g.ID); got == nil {
+				t.Fatal("restored game not found")
+			}
+			if deleted, _ := s.ListDeletedGames(teamID); len(deleted) != 0 {
+				t.Fatalf("trash after restore: %+v", deleted)
+			}
+		})
+	}
+}
+
+func TestStoreFindGamesPaging(t *testing.T) {
+	for name, s := range contractStores(t) {
+		t.Run(name, func(t *testing.T) {
+			teamID, _ := contractTeam(t, s)
+			// Two games share a start, so the cursor has to break the tie
+			start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+			var want []string
+			for _, offset := range []time.Duration{0, time.Hour, time.Hour, 2 * time.Hour, 3 * time.Hour} {
+				want = append(want, contractGame(t, s, teamID, start.Add(offset)).ID)
+			}
+
+			var got []string
+			filter := GameFilter{TeamID: teamID, Limit: 2}
+			for page := 0; page < 5; page++ {
+				games, total, err := s.FindGames(filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if total != len(want) {
+					t.Fatalf("total %d, want %d", total, len(want))
+				}
+				for _, g := range games {
+					got = append(got, g.ID)
+				}
+				if len(games) < filter.Limit {
+					break
+				}
+				last := games[len(games)-1]
+				filter.After = &GameCursor{StartsAt: last.StartsAt, ID: last.ID}
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("paged %v, want %d games", got, len(want))
+			}
+			seen := make(map[string]bool)
+			for i, id := range got {
+				if seen[id] {
+					t.Fatalf("%s returned twice: %v", id, got)
+				}
+				seen[id] = true
+				if i == 0 || i == 3 || i == 4 {
+					if id != want[i] {
+						t.Fatalf("page order %v, want %v", got, want)
+					}
+				}
+			}
+		})
+	}
+}
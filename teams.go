@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== TEAMS ====================
+
+// Team is one squad of the org. Seasons (and with them games, leagues and
+// divisions), members, settings and managers all belong to a team, while a
+// Discord user can be in several teams.
+//
+// Managers are the team members who hold DiscordManagerRole in the
+// DiscordGuildID server. The first team, which DISCORD_GUILD_ID and
+// DISCORD_MANAGER_ROLE configured before there were teams, falls back to
+// those; any other team without a server is managed by its creator alone.
+type Team struct {
+	ID                 string `json:"id"`
+	Slug               string `json:"slug"`
+	Name               string `json:"name"`
+	DiscordGuildID     string `json:"discordGuildId"`
+	DiscordManagerRole string `json:"discordManagerRole"`
+}
+
+// TeamUser is a Discord user's place in one team.
+type TeamUser struct {
+	TeamID    string `json:"teamId"`
+	PlayerID  string `json:"playerId,omitempty"`
+	IsManager bool   `json:"isManager"`
+}
+
+var errUnknownTeam = errors.New("team not found")
+
+// teamCookie remembers the team picked on the site, for requests that
+// don't name one with ?team=.
+const teamCookie = "team"
+
+var teamSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// seedTeamIfEmpty creates the first team, which is the one every request
+// goes to until more are added, and returns the oldest team.
+func seedTeamIfEmpty(s Store) (*Team, error) {
+	teams, err := s.ListTeams()
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) > 0 {
+		return &teams[0], nil
+	}
+
+	log.Println("Creating the first team...")
+	return s.CreateTeam(Team{ID: generateID("team"), Slug: "pop1", Name: "Game Over Pop1 War Team"})
+}
+
+// slugify turns a team name into a slug, e.g. "GO Europe" into "go-europe".
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	slug := b.String()
+	if len(slug) > 32 {
+		slug = strings.TrimRight(slug[:32], "-")
+	}
+	return slug
+}
+
+// cleanTeam trims a team's fields and returns what is wrong with it, or ""
+// if it is fine.
+func cleanTeam(t *Team) string {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Slug = strings.ToLower(strings.TrimSpace(t.Slug))
+	t.DiscordGuildID = strings.TrimSpace(t.DiscordGuildID)
+	t.DiscordManagerRole = strings.TrimSpace(t.DiscordManagerRole)
+
+	if t.Name == "" {
+		return "Name is required"
+	}
+	if t.Slug == "" {
+		t.Slug = slugify(t.Name)
+	}
+	if !teamSlugPattern.MatchString(t.Slug) {
+		return "Slug must be up to 32 lowercase letters, digits and dashes"
+	}
+	return ""
+}
+
+// findTeam returns the team with the given ID or slug, or nil.
+func findTeam(ref string) (*Team, error) {
+	if team, err := store.GetTeam(ref); err != nil || team != nil {
+		return team, err
+	}
+
+	teams, err := store.ListTeams()
+	if err != nil {
+		return nil, err
+	}
+	for i := range teams {
+		if strings.EqualFold(teams[i].Slug, ref) {
+			return &teams[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// requestedTeam returns the team a request is for: the ?team= parameter,
+// then the team cookie, then the oldest team.
+func requestedTeam(r *http.Request) (*Team, error) {
+	ref := r.URL.Query().Get("team")
+	if ref == "" {
+		if cookie, err := r.Cookie(teamCookie); err == nil {
+			ref = cookie.Value
+		}
+	}
+
+	if ref == "" {
+		teams, err := store.ListTeams()
+		if err != nil {
+			return nil, err
+		}
+		if len(teams) == 0 {
+			return nil, errUnknownTeam
+		}
+		return &teams[0], nil
+	}
+
+	team, err := findTeam(ref)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, errUnknownTeam
+	}
+	return team, nil
+}
+
+// writeTeamError reports a failure from requestedTeam.
+func writeTeamError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnknownTeam) {
+		writeError(w, http.StatusNotFound, "Team not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// teamSettingKey is the settings key holding one team's value of a setting,
+// e.g. "discord_webhook:team_1".
+func teamSettingKey(teamID, key string) string {
+	return key + ":" + teamID
+}
+
+// teamGuild returns the Discord server and role that decide who manages
+// the team. Only the first team falls back to the global ones, so their
+// managers don't manage every team added after it.
+func teamGuild(t *Team, first bool) (guildID, managerRole string) {
+	if !first {
+		return t.DiscordGuildID, t.DiscordManagerRole
+	}
+	return firstNonEmpty(t.DiscordGuildID, discordGuildID), firstNonEmpty(t.DiscordManagerRole, discordManagerRole)
+}
+
+// isAdmin reports whether the Discord user is one of ADMIN_DISCORD_IDS,
+// who run the site rather than one team.
+func isAdmin(discordID string) bool {
+	for _, id := range strings.Split(adminDiscordIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" && id == discordID {
+			return true
+		}
+	}
+	return false
+}
+
+// isTeamManager reports whether the Discord user manages the team.
+func isTeamManager(teamID, discordID string) bool {
+	user, err := store.GetUser(teamID, discordID)
+	return err == nil && user != nil && user.IsManager
+}
+
+// getTeamGame returns a live game if it belongs to the team, or nil, so a
+// game ID from another team looks the same as a missing one.
+func getTeamGame(teamID, gameID string) (*Game, error) {
+	game, err := store.GetGame(gameID)
+	if err != nil || game == nil || game.TeamID != teamID {
+		return nil, err
+	}
+	return game, nil
+}
+
+// getTeamMember is getTeamGame for members, trashed ones included. A
+// memberID that isn't an ID is looked up as one of the team's current or
+// former member names.
+func getTeamMember(teamID, memberID string) (*Member, error) {
+	member, err := store.GetMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return store.FindMember(teamID, memberID)
+	}
+	if member.TeamID != teamID {
+		return nil, nil
+	}
+	return member, nil
+}
+
+// getTeamSeason is getTeamGame for seasons.
+func getTeamSeason(teamID, seasonID string) (*Season, error) {
+	season, err := store.GetSeason(seasonID)
+	if err != nil || season == nil || season.TeamID != teamID {
+		return nil, err
+	}
+	return season, nil
+}
+
+func getTeamSeries(teamID, seriesID string) (*Series, error) {
+	series, err := store.GetSeries(seriesID)
+	if err != nil || series == nil || series.TeamID != teamID {
+		return nil, err
+	}
+	return series, nil
+}
+
+// siteURL is the site's public address, without a trailing slash.
+func siteURL() string {
+	if baseURL == "" {
+		return "https://go-pop1-calendar.onrender.com"
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// teamGameLink links to a game on the site, in the game's team.
+func teamGameLink(t *Team, gameID string) string {
+	return siteURL() + "/?team=" + t.Slug + "&game=" + gameID
+}
+
+// handleGetTeams lists every team, marking the ones the caller is in and
+// the ones they manage.
+func handleGetTeams(w http.ResponseWriter, r *http.Request) {
+	teams, err := store.ListTeams()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	type TeamWithRole struct {
+		Team
+		Member  bool `json:"member"`
+		Manager bool `json:"manager"`
+	}
+
+	joined := make(map[string]TeamUser)
+	if session := getSessionFromRequest(r); session != nil {
+		memberships, err := store.ListUserTeams(session.DiscordID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, m := range memberships {
+			joined[m.TeamID] = m
+		}
+	}
+
+	result := []TeamWithRole{}
+	for _, t := range teams {
+		m, ok := joined[t.ID]
+		result = append(result, TeamWithRole{Team: t, Member: ok, Manager: m.IsManager})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func handleGetCurrentTeam(w http.ResponseWriter, r *http.Request) {
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, team)
+}
+
+// handleCreateTeam adds a team; only admins can. Its creator becomes its
+// first manager and it starts with an empty roster and a first season.
+func handleCreateTeam(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !isAdmin(session.DiscordID) {
+		writeError(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+
+	var team Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if msg := cleanTeam(&team); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	team.ID = generateID("team")
+
+	created, err := store.CreateTeam(team)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "Another team already uses that slug")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := store.SaveTeamUser(created.ID, session.DiscordID, true); err != nil {
+		log.Printf("Error making %s a manager of team %s: %v", session.DiscordID, created.ID, err)
+	}
+	if err := seedSeasonIfEmpty(store, created.ID); err != nil {
+		log.Printf("Error starting the first season of team %s: %v", created.ID, err)
+	}
+
+	recordAudit(created.ID, session.DiscordID, "create", auditTeam, created.ID, nil, created)
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdateTeam changes a team's name, slug or Discord settings. Only
+// the team's own managers can, whichever team the request is for.
+func handleUpdateTeam(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID := vars["id"]
+
+	session := getSessionFromRequest(r)
+	if session == nil || !isTeamManager(teamID, session.DiscordID) {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	before, err := store.GetTeam(teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Team not found")
+		return
+	}
+
+	// Fields missing from the body keep their current values
+	team := *before
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	team.ID = before.ID
+	if msg := cleanTeam(&team); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	updated, err := store.UpdateTeam(team)
+	if errors.Is(err, errDuplicateName) {
+		writeError(w, http.StatusConflict, "Another team already uses that slug")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "update", auditTeam, teamID, before, updated)
+
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ==================== VIEWER TIME ZONES ====================
+
+// Games keep their start as an instant plus the zone they were scheduled
+// in. Players around the world also want to see it in their own zone, so
+// responses carry the start as the viewer sees it too.
+
+// ViewerTime is a game's start in the viewer's time zone.
+type ViewerTime struct {
+	TimeZone string `json:"timeZone"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+	// Label is ready to show, e.g. "Wed, Mar 5, 2:00 AM CET"
+	Label string `json:"label"`
+}
+
+// regionTimeZones are the zones assumed for members of a region who
+// haven't picked one.
+var regionTimeZones = map[string]string{
+	"NA": defaultGameTimeZone,
+	"EU": "Europe/Berlin",
+}
+
+// loadUserTimeZone loads a zone a user picked; "Local" is the server's
+// zone, which means nothing to them.
+func loadUserTimeZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// viewerLocation returns the zone to show a request's times in: ?tz=,
+// then the user's own choice, then their linked member's region. It is
+// nil when none of those is known.
+func viewerLocation(r *http.Request) *time.Location {
+	if loc, err := loadUserTimeZone(r.URL.Query().Get("tz")); err == nil {
+		return loc
+	}
+
+	session := getSessionFromRequest(r)
+	if session == nil {
+		return nil
+	}
+	if user, err := store.GetUser("", session.DiscordID); err == nil && user != nil {
+		if loc, err := loadUserTimeZone(user.TimeZone); err == nil {
+			return loc
+		}
+	}
+	if session.PlayerID != "" {
+		if member, err := store.GetMember(session.PlayerID); err == nil && member != nil {
+			if loc, err := loadUserTimeZone(regionTimeZones[member.Region]); err == nil {
+				return loc
+			}
+		}
+	}
+	return nil
+}
+
+// setViewerTime fills g.Viewer with its start in loc.
+func setViewerTime(g *Game, loc *time.Location) {
+	local := g.StartsAt.In(loc)
+	g.Viewer = &ViewerTime{
+		TimeZone: loc.String(),
+		Date:     local.Format("2006-01-02"),
+		Time:     local.Format("15:04"),
+		Label:    local.Format("Mon, Jan 2, 3:04 PM MST"),
+	}
+}
+
+// localizeForViewer fills in Viewer on games for whoever made the request.
+func localizeForViewer(r *http.Request, games []Game) {
+	loc := viewerLocation(r)
+	if loc == nil {
+		return
+	}
+	for i := range games {
+		setViewerTime(&games[i], loc)
+	}
+}
+
+// discordTimestamp renders t with Discord's timestamp markup, which each
+// reader sees in their own time zone. Style is one of Discord's format
+// letters, e.g. 'F' for "Tuesday, March 4, 2025 8:00 PM" or 'R' for
+// "in 2 days".
+func discordTimestamp(t time.Time, style byte) string {
+	return fmt.Sprintf("<t:%d:%c>", t.Unix(), style)
+}
+
+// discordGameTime is a game's start for Discord messages: the full date
+// and time, then how long until it.
+func discordGameTime(g *Game) string {
+	return discordTimestamp(g.StartsAt, 'F') + " (" + discordTimestamp(g.StartsAt, 'R') + ")"
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ==================== TRASH ====================
+
+// defaultTrashRetentionDays is how long deleted games and members can be
+// restored before they are purged, unless TRASH_RETENTION_DAYS says otherwise.
+const defaultTrashRetentionDays = 30
+
+// trashPurgeInterval is how often the background purge runs.
+const trashPurgeInterval = time.Hour
+
+func trashRetention() time.Duration {
+	days := defaultTrashRetentionDays
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			days = n
+		} else {
+			log.Printf("Ignoring invalid TRASH_RETENTION_DAYS %q", v)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// purgeTrash permanently removes everything that has been in the trash
+// longer than the retention window.
+func purgeTrash() {
+	games, members, err := store.PurgeDeleted(time.Now().Add(-trashRetention()))
+	if err != nil {
+		log.Printf("Error purging trash: %v", err)
+		return
+	}
+	if games > 0 || members > 0 {
+		log.Printf("Purged %d game(s) and %d member(s) from the trash", games, members)
+		recordAudit("", auditSystemActor, "purge", auditTrash, "", nil, map[string]int{"games": games, "members": members})
+	}
+}
+
+// runTrashPurge purges the trash once at startup and then on every tick.
+func runTrashPurge() {
+	purgeTrash()
+	for range time.Tick(trashPurgeInterval) {
+		purgeTrash()
+	}
+}
+
+// inTeamTrash reports whether a deleted game belongs to the team.
+func inTeamTrash(teamID, gameID string) (bool, error) {
+	games, err := store.ListDeletedGames(teamID)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range games {
+		if g.ID == gameID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+
+	games, err := store.ListDeletedGames(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	members, err := store.ListDeletedMembers(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if members == nil {
+		members = []Member{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"games":         games,
+		"members":       members,
+		"retentionDays": int(trashRetention() / (24 * time.Hour)),
+	})
+}
+
+func handleRestoreGame(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	trashed, err := inTeamTrash(team.ID, gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !trashed {
+		writeError(w, http.StatusNotFound, "Game not found in trash")
+		return
+	}
+
+	game, err := store.RestoreGame(gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if game == nil {
+		writeError(w, http.StatusNotFound, "Game not found in trash")
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "restore", auditGame, gameID, nil, game)
+
+	writeGame(w, r, http.StatusOK, game)
+}
+
+func handleRestoreMember(w http.ResponseWriter, r *http.Request) {
+	session := getSessionFromRequest(r)
+	if session == nil || !session.IsManager {
+		writeError(w, http.StatusForbidden, "Manager access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	memberID := vars["id"]
+
+	team, err := requestedTeam(r)
+	if err != nil {
+		writeTeamError(w, err)
+		return
+	}
+	member, err := getTeamMember(team.ID, memberID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if member == nil {
+		writeError(w, http.StatusNotFound, "Member not found in trash")
+		return
+	}
+
+	memberID = member.ID
+	member, err = store.RestoreMember(memberID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if member == nil {
+		writeError(w, http.StatusNotFound, "Member not found in trash")
+		return
+	}
+
+	recordAudit(team.ID, session.DiscordID, "restore", auditMember, memberID, nil, member)
+
+	writeJSON(w, http.StatusOK, member)
+}